@@ -0,0 +1,39 @@
+package main
+
+// 設定を確認するためのサブコマンド
+// 使い方: tipstar-chat-api config print [-config path]
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/minminseo/tipstar-chat-api/infra/config"
+)
+
+// configサブコマンドを実行し、終了コードを返す
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "使い方: config print [-config path]")
+		return 2
+	}
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "設定ファイル（YAML）のパス")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// デフォルト値・設定ファイル・環境変数を反映した、実際に使われる設定を秘密の値を伏せて表示する
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, err := cfg.RedactedYAML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
@@ -4,7 +4,7 @@ package main
 
 /*
 処理の流れ
-1. 環境変数の取得
+1. 設定の読み込み（infra/configでデフォルト値・YAMLファイル・環境変数をまとめる）
 2. データベース接続プールの初期化（インフラ層の実装を利用）
 3. リポジトリ層、ユースケース層、ハンドラー層のインスタンス化と依存注入
 4. WebSocketのルーム管理のためのHubのインスタンス化と、ルーム管理ループの起動
@@ -14,63 +14,282 @@ package main
 */
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/authz"
+	"github.com/minminseo/tipstar-chat-api/infra/blob"
+	"github.com/minminseo/tipstar-chat-api/infra/config"
 	"github.com/minminseo/tipstar-chat-api/infra/db"
+	"github.com/minminseo/tipstar-chat-api/infra/filter"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+	"github.com/minminseo/tipstar-chat-api/infra/notify"
+	"github.com/minminseo/tipstar-chat-api/infra/tracing"
+	"github.com/minminseo/tipstar-chat-api/infra/webhook"
+	"github.com/minminseo/tipstar-chat-api/presentation/origin"
 	"github.com/minminseo/tipstar-chat-api/presentation/rest"
 	"github.com/minminseo/tipstar-chat-api/presentation/websocket"
 	"github.com/minminseo/tipstar-chat-api/router"
 	"github.com/minminseo/tipstar-chat-api/usecase"
 )
 
+// サーバーのバージョン（WebSocketのwelcomeで返す）
+// ビルド時に -ldflags "-X main.version=v1.2.3" で埋め込む
+var version = "dev"
+
 func main() {
 	// .envファイル読み込み（開発環境用）
 	if err := godotenv.Load(); err != nil {
 		log.Println(".envファイル読み込みエラー")
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URLが設定されていません")
+	// サブコマンド（config print）
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	// 設定の読み込み（デフォルト値 < YAMLファイル < 環境変数）
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "設定ファイル（YAML）のパス")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// ログはJSONで標準出力に出す。log.Printf系の出力もslogのハンドラーを通る
+	logLevel, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(slog.New(logging.NewHandler(os.Stdout, logLevel)))
+
+	// トレースのエクスポーター（none, stdout, otlp）。OTLPの送信先はOTEL_EXPORTER_OTLP_ENDPOINTで指定する
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		log.Fatalf("トレースの初期化失敗: %v", err)
 	}
+	defer shutdownTracing(context.Background())
 
 	// データベース接続プールの作成
-	pool, err := db.NewDB(dbURL)
+	pool, err := db.NewDB(cfg.Database.URL)
 	if err != nil {
 		log.Fatalf("DB接続失敗: %v", err)
 	}
 	defer pool.Close()
+	if err := prometheus.Register(db.NewPoolCollector(pool)); err != nil {
+		log.Fatalf("メトリクス登録失敗: %v", err)
+	}
 
 	// インスタンス化と注入
 	// コンストラクタを起動、外側でインスタンス化したDB接続プール注入、永続化処理のインターフェースのメソッドの具象実装をインスタンス化
 	msgRepo := db.NewPgxMessageRepository(pool)
+	reportRepo := db.NewPgxReportRepository(pool)
+	filterLogRepo := db.NewPgxFilterLogRepository(pool)
+	mentionRepo := db.NewPgxMentionRepository(pool)
+	readCursorRepo := db.NewPgxReadCursorRepository(pool)
+	tipMemberRepo := db.NewPgxTipMemberRepository(pool)
+	pinRepo := db.NewPgxPinRepository(pool)
+	attachmentRepo := db.NewPgxAttachmentRepository(pool)
+	outboxRepo := db.NewPgxOutboxRepository(pool)
+	webhookRepo := db.NewPgxWebhookRepository(pool)
+	webhookDeliveryRepo := db.NewPgxWebhookDeliveryRepository(pool)
+
+	// 添付ファイルの実体の保存先と、形式・サイズの制限
+	blobStore, err := blob.NewLocalBlobStore(cfg.Attachment.Dir)
+	if err != nil {
+		log.Fatalf("BlobStore初期化失敗: %v", err)
+	}
+	attachmentPolicy := domain.AttachmentPolicy{
+		MaxSize:      cfg.Attachment.MaxBytes,
+		AllowedTypes: cfg.Attachment.AllowedTypes,
+	}
+
+	// Roomに接続していないユーザーへのメンション通知（今はログ出力のみ）
+	mentionNotifier := notify.NewLogMentionNotifier()
+
+	// モデレーターのユーザーID
+	moderators := domain.NewModeratorSet(cfg.Moderation.ModeratorUserIDs)
+
+	// Tipの履歴の閲覧、Roomへの参加、書き込みの可否の判定（値は設定の読み込み時に検証済み）
+	var tipAuthorizer domain.TipAuthorizer
+	switch cfg.Access.TipAuthorizer {
+	case config.TipAuthorizerAllowAll:
+		slog.Warn("Tipへのアクセス制御が無効です（access.tip_authorizer=allow_all）。本番では使わないでください")
+		tipAuthorizer = authz.NewAllowAllTipAuthorizer()
+	default:
+		tipAuthorizer = db.NewPgxTipAuthorizer(pool)
+	}
+
+	// 送信・編集時に永続化前に適用するコンテンツフィルター（登録順に適用される）
+	spamFilter := filter.NewRepeatSpamFilter(
+		cfg.Filter.SpamWindow,
+		cfg.Filter.SpamMaxRepeats,
+		cfg.Filter.SpamAction,
+	)
+	filters := usecase.ContentFilterChain{
+		filter.NewWordListFilter(cfg.Filter.BannedWords, cfg.Filter.BannedWordsAction),
+		filter.NewURLFilter(cfg.Filter.URLAllowlist, cfg.Filter.URLDenylist, cfg.Filter.URLAction),
+		spamFilter,
+	}
+	go func() {
+		// 連投検出用にメモリに保持している直近の投稿を定期的に掃除する
+		for range time.Tick(cfg.Filter.SpamSweepInterval) {
+			spamFilter.Sweep()
+		}
+	}()
+
+	// WebSocketのハブ生成（アウトボックスの配信先にするので、ユースケースより先に生成する）
+	hub := websocket.NewHub(websocket.HubConfig{
+		RoomIdleTimeout:  cfg.WebSocket.RoomIdleTimeout,
+		SweepInterval:    cfg.WebSocket.HubSweepInterval,
+		SendBufferSize:   cfg.WebSocket.SendBufferSize,
+		MaxSubscriptions: cfg.WebSocket.MaxSubscriptions,
+		BatchMaxDelay:    cfg.WebSocket.BatchMaxDelay,
+		BatchMaxFrames:   cfg.WebSocket.BatchMaxFrames,
+	})
+	if err := websocket.RegisterHubMetrics(prometheus.DefaultRegisterer, hub); err != nil {
+		log.Fatalf("メトリクス登録失敗: %v", err)
+	}
+
+	// 登録されたWebhookへの配信（失敗したら指数バックオフでリトライし、最大試行回数で諦める）
+	webhookDispatcher := usecase.NewWebhookDispatcher(
+		webhookRepo,
+		webhookDeliveryRepo,
+		webhook.NewHTTPWebhookSender(cfg.Webhook.Timeout),
+		domain.WebhookRetryPolicy{
+			MaxAttempts: cfg.Webhook.MaxAttempts,
+			BaseDelay:   cfg.Webhook.RetryBaseDelay,
+			MaxDelay:    cfg.Webhook.RetryMaxDelay,
+		},
+		cfg.Webhook.PollInterval,
+		cfg.Webhook.ClaimLease,
+	)
+	go webhookDispatcher.Run(context.Background())
+
+	// 送信・編集・削除等のイベントの配信先。RoomへのブロードキャストとWebhookは常に行い、それ以外はoutbox.sinksで選ぶ（値は設定の読み込み時に検証済み）
+	// Roomへのブロードキャストはインスタンスごとに全てのイベントを届け、それ以外は全インスタンスで1回だけ届ける
+	localSinks := []domain.EventSink{websocket.NewHubEventSink(hub)}
+	durableSinks := []domain.EventSink{webhookDispatcher}
+	for _, name := range cfg.Outbox.Sinks {
+		switch name {
+		case config.OutboxSinkLog:
+			durableSinks = append(durableSinks, notify.NewLogEventSink())
+		}
+	}
+	outboxRelay := usecase.NewOutboxRelay(
+		outboxRepo,
+		localSinks,
+		durableSinks,
+		cfg.Outbox.PollInterval,
+		cfg.Outbox.Retention,
+		cfg.Outbox.ClaimLease,
+	)
+	go outboxRelay.Run(context.Background())
 
 	// コンストラクタを起動、外側でインスタンス化した永続化処理を注入、ユースケースのインターフェースのメソッドの具象実装をインスタンス化
-	onlyRestUC := usecase.NewOnlyRestMessageUseCase(msgRepo)
-	onlyWSCUC := usecase.NewOnlyWSMessageUseCase(msgRepo)
+	onlyRestUC := usecase.NewOnlyRestMessageUseCase(msgRepo, mentionRepo, moderators, tipAuthorizer)
+	onlyWSCUC := usecase.NewOnlyWSMessageUseCase(msgRepo, filterLogRepo, filters, moderators, tipAuthorizer, mentionNotifier, outboxRelay)
+	reportUC := usecase.NewReportUseCase(msgRepo, reportRepo, filterLogRepo, outboxRelay, moderators, tipAuthorizer, cfg.Moderation.ReportHideThreshold)
+	readCursorUC := usecase.NewReadCursorUseCase(msgRepo, readCursorRepo, moderators, tipAuthorizer)
+	attachmentUC := usecase.NewAttachmentUseCase(attachmentRepo, tipAuthorizer, blobStore, attachmentPolicy)
+	webhookUC := usecase.NewWebhookUseCase(webhookRepo, webhookDeliveryRepo, moderators)
+	eventStreamUC := usecase.NewEventStreamUseCase(outboxRepo, tipAuthorizer, moderators)
+	pinUC := usecase.NewPinUseCase(msgRepo, pinRepo, tipMemberRepo, moderators, tipAuthorizer, cfg.Moderation.MaxPinsPerTip)
 
 	// コンストラクタを起動、外側でインスタンス化したユースケースを注入、ハンドラーのインターフェースのメソッドの具象実装をインスタンス化
 	restHandler := rest.NewOnlyRestMessageHandler(onlyRestUC)
-	wsHandler := websocket.NewOnlyWSMessageHandler(onlyWSCUC, nil) // hubは後でセットするのでnilを渡す
+	wsHandler := websocket.NewOnlyWSMessageHandler(onlyWSCUC, reportUC, readCursorUC, pinUC, nil, version) // hubは後でセットするのでnilを渡す
 
-	// WebSocketのハブ生成とルーム管理ループの起動
-	hub := websocket.NewHub()
+	// ルーム管理ループの起動
 	wsHandler.SetHub(hub) // wsHandler 内で Hub を利用する場合の setter を実装しておく
 	go hub.Run()
 
+	// 既読系のハンドラーはHTTP経由の変化をWebSocketに流すためにHubを使う（通報による変化はアウトボックスのリレー経由で流れる）
+	reportHandler := rest.NewReportHandler(reportUC)
+	readHandler := rest.NewReadCursorHandler(readCursorUC, hub)
+	pinHandler := rest.NewPinHandler(pinUC)
+	sseHandler := websocket.NewSSEHandler(eventStreamUC, hub, websocket.SSEConfig{
+		HeartbeatInterval: cfg.SSE.HeartbeatInterval,
+		ReplayBatchSize:   cfg.SSE.ReplayBatchSize,
+	})
+	longPollHandler := websocket.NewLongPollHandler(eventStreamUC, hub, websocket.LongPollConfig{
+		Timeout:   cfg.LongPoll.Timeout,
+		MaxEvents: cfg.LongPoll.MaxEvents,
+	})
+	attachmentHandler := rest.NewAttachmentHandler(attachmentUC, attachmentPolicy.MaxSize)
+	webhookHandler := rest.NewWebhookHandler(webhookUC)
+
+	// 準備完了の確認（DBへの疎通とHubのループ）。シャットダウンが始まったら503を返す
+	healthHandler := rest.NewHealthHandler(cfg.Server.ReadinessTimeout)
+	healthHandler.AddCheck("database", pool.Ping)
+	healthHandler.AddCheck("hub", func(ctx context.Context) error {
+		if !hub.IsRunning() {
+			return errors.New("Hubのループが動いていません")
+		}
+		return nil
+	})
+
+	// ブラウザからの接続を許可するOrigin（WebSocketの昇格とREST APIのCORSで共有する）
+	origins, err := origin.NewAllowlist(cfg.Origins.Allowed, cfg.Origins.DevMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if origins.DevMode() {
+		slog.Warn("Originの開発モードが有効です。全てのOriginからの接続を許可します")
+	}
+
+	// WebSocketへの昇格（クライアントが対応していればpermessage-deflateで圧縮する）
+	upgrader := websocket.NewUpgrader(origins, websocket.CompressionConfig{
+		Enabled:   cfg.WebSocket.CompressionEnabled,
+		Level:     cfg.WebSocket.CompressionLevel,
+		Threshold: cfg.WebSocket.CompressionThreshold,
+	})
+
 	// 依存注入済みのハンドラーを渡す
-	r := router.NewRouter(restHandler, wsHandler, hub)
+	r := router.NewRouter(restHandler, reportHandler, readHandler, pinHandler, attachmentHandler, webhookHandler, healthHandler, wsHandler, sseHandler, longPollHandler, hub, upgrader, origins)
 
 	// サーバー起動
-	port := os.Getenv("PORT")
+	addr := ":" + cfg.Server.Port
+	srv := &http.Server{Addr: addr, Handler: r}
+	// SSEのストリームとロングポーリングは長く保留されるリクエストなので、Shutdownの開始時に終わらせる
+	srv.RegisterOnShutdown(sseHandler.Shutdown)
+	srv.RegisterOnShutdown(longPollHandler.Shutdown)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("サーバー起動", "addr", addr)
+		serverErr <- srv.ListenAndServe()
+	}()
 
-	addr := ":" + port
-	log.Printf("サーバー起動: http://localhost:%s", addr)
-	if err := http.ListenAndServe(addr, r); err != nil {
+	// SIGTERM（Kubernetesの停止）かSIGINTを受け取ったら、/readyzを503にしてロードバランサーが振り分けを止めるのを待ってから停止する
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serverErr:
 		log.Fatalf("サーバー起動エラー: %v", err)
+	case <-ctx.Done():
+	}
+	healthHandler.StartDraining()
+	drainDelay := cfg.Server.ShutdownDrainDelay
+	slog.Info("シャットダウン開始。振り分けが止まるのを待機", "drain_delay", drainDelay.String())
+	time.Sleep(drainDelay)
+
+	// 処理中のRESTのリクエストが終わるのを待つ（WebSocketの接続は昇格済みなので待たない。SSEとロングポーリングはRegisterOnShutdownで終わらせる）
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("サーバーの停止に失敗", "error", err)
 	}
+	slog.Info("サーバー停止")
 }
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"time"
+)
+
+type AttachmentID string
+
+var (
+	ErrAttachmentNotFound       = errors.New("添付ファイルが見つかりません")
+	ErrAttachmentTooLarge       = errors.New("添付ファイルのサイズが上限を超えています")
+	ErrAttachmentTypeNotAllowed = errors.New("この形式のファイルは添付できません")
+	ErrAttachmentUnavailable    = errors.New("指定された添付ファイルはこのメッセージに使えません")
+)
+
+// メッセージの添付ファイルのドメインモデル
+// アップロード時点ではどのメッセージにも紐づかず、メッセージ送信時にMessageIDが設定される
+type Attachment struct {
+	ID          AttachmentID
+	TipID       TipID      // アップロード先のTip（同じTipのメッセージにしか添付できない）
+	UploaderID  UserID     // アップロードしたユーザー（本人のメッセージにしか添付できない）
+	MessageID   *MessageID // 添付先のメッセージ。未添付ならnil
+	FileName    string     // アップロード時の元のファイル名（ダウンロード時のファイル名に使う）
+	ContentType string     // 中身から判定したMIMEタイプ
+	Size        int64      // バイト数
+	StorageKey  string     // BlobStore上のキー
+	CreatedAt   time.Time
+	Hidden      bool // 添付先のメッセージが論理削除・非表示になっている場合はtrue（永続化はしない）
+}
+
+// 添付ファイルの形式とサイズの制限
+type AttachmentPolicy struct {
+	MaxSize      int64    // 1ファイルあたりの最大バイト数
+	AllowedTypes []string // 添付できるMIMEタイプ（パラメータなし。例："image/png"）
+}
+
+// MIMEタイプが許可されているかどうか。charset等のパラメータは無視して比較する
+func (p AttachmentPolicy) IsAllowedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range p.AllowedTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// 添付ファイルの実体を保存するストレージのインターフェース
+// 具体的な実装（ローカルファイルシステム、S3等）はインフラ層で行う
+type BlobStore interface {
+	// keyに対応する実体を保存する
+	Put(ctx context.Context, key string, r io.Reader) error
+	// keyに対応する実体を読み出す。呼び出し側でCloseする
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// keyに対応する実体を削除する。存在しなくてもエラーにしない
+	Delete(ctx context.Context, key string) error
+}
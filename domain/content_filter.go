@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 永続化前にメッセージ内容を検査するフィルターのドメインモデル
+// フィルターは順番に適用され、それぞれが拒否・伏せ字化・フラグ付けのいずれかを行える
+
+// フィルターが発動したときの動作
+type FilterAction string
+
+const (
+	FilterActionReject FilterAction = "reject" // メッセージを保存しない
+	FilterActionMask   FilterAction = "mask"   // 該当箇所を伏せ字にして保存する
+	FilterActionFlag   FilterAction = "flag"   // そのまま保存し、モデレーター向けに記録だけ残す
+)
+
+// フィルターの設定値として受け取った文字列が定義済みの動作かどうか
+func (a FilterAction) IsValid() bool {
+	switch a {
+	case FilterActionReject, FilterActionMask, FilterActionFlag:
+		return true
+	}
+	return false
+}
+
+// フィルターが発動した結果
+type FilterResult struct {
+	FilterName string       // 発動したフィルターの名前
+	Action     FilterAction // 実際に行った動作
+	Reason     string       // モデレーター向けの理由（どの語句・URLに引っかかったか等）
+}
+
+// メッセージ内容のフィルターのインターフェース
+// 具体的な実装はインフラ層（infra/filter）で行う
+type ContentFilter interface {
+	// フィルターの名前（記録用）
+	Name() string
+	// メッセージを検査する。発動しなければnilを返す。
+	// 伏せ字化する場合はmsg.Contentを書き換えた上でActionがmaskの結果を返す
+	Apply(ctx context.Context, msg *Message) (*FilterResult, error)
+}
+
+// 保存されたメッセージを記録するフィルターのインターフェース（連投の検出など、過去の投稿を基に判定するフィルターが実装する）
+// Applyの時点ではまだ拒否や保存の失敗があり得るので、記録はメッセージの保存に成功した後で行う
+type ContentFilterRecorder interface {
+	Record(ctx context.Context, msg *Message)
+}
+
+// フィルターによってメッセージが拒否されたことを表すエラー
+type ContentRejectedError struct {
+	FilterName string
+	Reason     string
+}
+
+func (e *ContentRejectedError) Error() string {
+	return fmt.Sprintf("メッセージがフィルター（%s）によって拒否されました: %s", e.FilterName, e.Reason)
+}
+
+// フィルターが発動した記録（モデレーターが確認する用）
+type FilterLog struct {
+	MessageID  MessageID // 拒否された場合は保存されなかったメッセージのID
+	TipID      TipID
+	UserID     UserID
+	FilterName string
+	Action     FilterAction
+	Reason     string
+	CreatedAt  time.Time
+}
+
+// フィルターの結果から記録を生成するファクトリ関数
+func NewFilterLog(msg *Message, result *FilterResult) *FilterLog {
+	return &FilterLog{
+		MessageID:  msg.ID,
+		TipID:      msg.TipID,
+		UserID:     msg.UserID,
+		FilterName: result.FilterName,
+		Action:     result.Action,
+		Reason:     result.Reason,
+		CreatedAt:  time.Now(),
+	}
+}
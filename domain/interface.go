@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 // メッセージの永続化処理のメソッドを定義するインターフェース
@@ -9,8 +10,127 @@ import (
 // 具体的な実装はインフラ層で行う
 type MessageRepository interface {
 	FetchMessageByID(ctx context.Context, id MessageID) (*Message, error) // クライアントからきたMessageIDを元にDBからメッセージを取得するメソッド
-	SaveMessage(msg *Message) error                                       // メッセージをDBに挿入するメソッド
+	SaveMessage(ctx context.Context, msg *Message) error                  // メッセージをDBに挿入するメソッド。ClientMsgIDが重複したらErrDuplicateClientMsgIDを返す
 	Update(ctx context.Context, msg *Message) error                       // メッセージを編集するメソッド
 	SoftDelete(ctx context.Context, msg *Message) error                   // メッセージを論理削除するメソッド
-	GetAllMessages(tipID TipID) ([]*Message, error)                       // tipIDでに対応するチャット履歴を一覧取得する。
+	GetAllMessages(ctx context.Context, tipID TipID) ([]*Message, error)  // tipIDでに対応するチャット履歴を一覧取得する。
+	UpdateHiddenAt(ctx context.Context, msg *Message) error               // メッセージの非表示状態（hidden_at）を更新するメソッド
+	// ユーザー・Tip・ClientMsgIDの組でメッセージを取得するメソッド。存在しなければnilを返す
+	FetchMessageByClientMsgID(ctx context.Context, userID UserID, tipID TipID, clientMsgID string) (*Message, error)
+}
+
+// 通報（モデレーションキュー）の永続化処理のメソッドを定義するインターフェース
+type ReportRepository interface {
+	// 通報を挿入する。同じ通報者による重複はErrAlreadyReportedを返す
+	SaveReport(ctx context.Context, report *Report) error
+	// メッセージに対する通報者の数を取得する
+	CountReportsByMessageID(ctx context.Context, messageID MessageID) (int, error)
+	// 通報をIDで取得する。存在しなければErrReportNotFoundを返す
+	FetchReportByID(ctx context.Context, id ReportID) (*Report, error)
+	// 未対応の通報を通報対象のメッセージと一緒に古い順で取得する
+	FetchPendingReports(ctx context.Context) ([]*Report, error)
+	// メッセージに対する未対応の通報を全て解決済みにする。未対応の通報が無ければErrReportAlreadyResolvedを返す
+	// msgがnilでなければ、同じトランザクションでメッセージの変更（statusがdeletedなら論理削除、dismissedなら非表示の解除）も永続化する
+	ResolveReportsByMessageID(ctx context.Context, messageID MessageID, status ReportStatus, resolvedBy UserID, msg *Message) error
+}
+
+// フィルターの発動記録の永続化処理のメソッドを定義するインターフェース
+type FilterLogRepository interface {
+	// フィルターの発動記録をまとめて挿入する
+	SaveFilterLogs(ctx context.Context, logs []*FilterLog) error
+	// 新しい順に最大limit件の発動記録を取得する
+	FetchRecentFilterLogs(ctx context.Context, limit int) ([]*FilterLog, error)
+}
+
+// メンションの取得処理のメソッドを定義するインターフェース
+// メンションの保存はメッセージの保存・編集と同じトランザクションで行うのでMessageRepository側の責務
+type MentionRepository interface {
+	// ユーザーがメンションされたメッセージを、全Tip横断で新しい順に最大limit件取得する（削除済みのメッセージは除く）
+	FetchMentionsByUserID(ctx context.Context, userID UserID, limit int) ([]*Mention, error)
+}
+
+// Roomに接続していないユーザーへのメンション通知のフック
+// 具体的な実装（プッシュ通知、メール等）はインフラ層で行う
+type MentionNotifier interface {
+	NotifyMention(ctx context.Context, mention *Mention) error
+}
+
+// 既読位置の永続化処理のメソッドを定義するインターフェース
+type ReadCursorRepository interface {
+	// Tipとユーザーに対応する既読位置を取得する。まだ既読位置がなければnilを返す
+	FetchReadCursor(ctx context.Context, tipID TipID, userID UserID) (*ReadCursor, error)
+	// 既読位置を保存する。保存済みの位置より古い場合は更新せずfalseを返す
+	SaveReadCursor(ctx context.Context, cursor *ReadCursor) (bool, error)
+	// 既読位置を持っているTipごとに、既読位置より後の他人のメッセージ数を取得する
+	FetchUnreadCounts(ctx context.Context, userID UserID) ([]*UnreadCount, error)
+}
+
+// Tipの参加者の取得処理のメソッドを定義するインターフェース
+type TipMemberRepository interface {
+	// ユーザーのTipに対する役割を取得する。参加していなければTipRoleNoneを返す
+	FetchTipRole(ctx context.Context, tipID TipID, userID UserID) (TipRole, error)
+}
+
+// Tipへのアクセスの可否を判定するインターフェース
+// 履歴の閲覧、WebSocketのRoomへの参加、全ての書き込みの前に呼ぶ。許可されない場合はErrTipAccessDeniedを返す
+type TipAuthorizer interface {
+	Authorize(ctx context.Context, tipID TipID, userID UserID, access TipAccess) error
+}
+
+// ピン留めの永続化処理のメソッドを定義するインターフェース
+// メッセージの論理削除時のピン留め解除はMessageRepository.SoftDeleteの責務
+type PinRepository interface {
+	// Tip内のピン留めをPositionの昇順で取得する
+	FetchPinsByTipID(ctx context.Context, tipID TipID) ([]*Pin, error)
+	// ピン留めを挿入し、採番したPositionをpinに設定する。
+	// Tip内のピン留め数がmaxPins以上ならErrPinLimitReached、すでにピン留め済みならErrAlreadyPinned、
+	// 取得から挿入までの間にメッセージが論理削除されていればErrPinDeletedを返す
+	SavePin(ctx context.Context, pin *Pin, maxPins int) error
+	// ピン留めを削除する。ピン留めされていなければErrNotPinnedを返す
+	DeletePin(ctx context.Context, tipID TipID, messageID MessageID) error
+}
+
+// 添付ファイルのメタデータの永続化処理のメソッドを定義するインターフェース
+// メッセージへの紐づけはメッセージの保存と同じトランザクションで行うのでMessageRepository側の責務
+type AttachmentRepository interface {
+	// アップロードされた添付ファイルのメタデータを挿入する
+	SaveAttachment(ctx context.Context, attachment *Attachment) error
+	// 添付ファイルをIDで取得する。添付先のメッセージが論理削除・非表示ならHiddenをtrueにする。
+	// 存在しなければErrAttachmentNotFoundを返す
+	FetchAttachmentByID(ctx context.Context, id AttachmentID) (*Attachment, error)
+}
+
+// アウトボックスのイベントの取得・配信済みの記録・掃除を行うリポジトリ
+// イベントの書き込みはMessageRepositoryの保存・編集・論理削除のトランザクション内で行う
+type OutboxRepository interface {
+	// コミット済みでまだ連番のないイベントに、書き込み順に連番を振って振った件数を返す（インスタンス間で直列化する）
+	SequenceEvents(ctx context.Context, limit int) (int, error)
+	LatestSeq(ctx context.Context) (int64, error) // 振られている連番の最大値を取得する。まだなければ0
+	// 連番がafterSeqより大きいイベントを連番の順に取得する（各インスタンスのHubへの配信用。配信済みかどうかは問わない）
+	FetchSequencedAfter(ctx context.Context, afterSeq int64, limit int) ([]*OutboxEvent, error)
+	// 永続的なシンクに未配信のイベントをleaseの間だけ確保し、連番の順に返す。他のインスタンスが確保しているイベントは含めない
+	ClaimPendingEvents(ctx context.Context, lease time.Duration, limit int) ([]*OutboxEvent, error)
+	MarkPublished(ctx context.Context, id int64) error                          // 永続的なシンク全てに配信したことを記録する。配信済みなら何もしない
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) // before以前に配信済みになったイベントを削除し、削除件数を返す
+	// Tipのイベントのうち、連番がafterSeqより大きいものを連番の順に取得する（SSEの再接続時の取りこぼしの再送用）
+	// 配信済みで保持期間を過ぎたイベントは削除されているので含まれない
+	FetchEventsAfter(ctx context.Context, tipID TipID, afterSeq int64, limit int) ([]*OutboxEvent, error)
+}
+
+// Webhookの登録内容の永続化処理のインターフェース
+type WebhookRepository interface {
+	SaveWebhook(ctx context.Context, webhook *Webhook) error
+	FetchWebhooks(ctx context.Context) ([]*Webhook, error)                    // 登録済みのWebhookを全て取得する
+	FetchWebhookByID(ctx context.Context, id WebhookID) (*Webhook, error)     // 存在しなければErrWebhookNotFoundを返す
+	FetchWebhooksForTip(ctx context.Context, tipID TipID) ([]*Webhook, error) // tipIDを対象にしたWebhookと、全Tip対象のWebhookを取得する
+	DeleteWebhook(ctx context.Context, id WebhookID) error                    // 配信と試行の履歴も削除する。存在しなければErrWebhookNotFoundを返す
+}
+
+// Webhookの配信と試行の履歴の永続化処理のインターフェース
+type WebhookDeliveryRepository interface {
+	EnqueueDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error // 同じイベントと同じWebhookの配信がすでにあれば無視する
+	// 次の試行日時を過ぎた配信待ちの配信を確保して返す。確保した配信の次の試行日時はnow+leaseに延ばし、その間は他のインスタンスに渡さない
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *WebhookDelivery, attempt *WebhookAttempt) error                // 配信の状態の更新と試行結果の追加を同じトランザクションで行う
+	FetchDeliveriesByWebhookID(ctx context.Context, webhookID WebhookID, limit int) ([]*WebhookDelivery, error) // 新しい順に、試行の履歴付きで取得する
 }
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+)
+
+// メッセージ内の「@ユーザーID」形式のメンション
+// ユーザーIDはUUIDなので、UUIDの形式に一致するものだけをメンションとみなす
+var mentionPattern = regexp.MustCompile(`@([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})`)
+
+// メンションのドメインモデル（どのメッセージで誰が誰をメンションしたか）
+type Mention struct {
+	MessageID       MessageID
+	TipID           TipID
+	MentionedUserID UserID    // メンションされたユーザー
+	MentionedBy     UserID    // メンションしたユーザー（メッセージの送信者）
+	CreatedAt       time.Time // メッセージの送信日時
+	Message         *Message  // 一覧表示用のメッセージ（永続化はしない）
+}
+
+// メッセージ内容からメンションされたユーザーIDを出現順に重複なしで取り出す
+func ParseMentions(content string) []UserID {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	seen := make(map[UserID]struct{}, len(matches))
+	ids := make([]UserID, 0, len(matches))
+	for _, m := range matches {
+		id := UserID(m[1])
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// 現在のContentからMentionsを再計算する。自分自身へのメンションは除く
+// フィルターで伏せ字化された場合など、Contentを書き換えた後に呼び出す
+func (m *Message) RefreshMentions() {
+	var ids []UserID
+	for _, id := range ParseMentions(m.Content) {
+		if id == m.UserID {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	m.Mentions = ids
+}
+
+// メッセージに含まれるメンションをMentionのスライスとして返す
+func (m *Message) MentionList() []*Mention {
+	mentions := make([]*Mention, 0, len(m.Mentions))
+	for _, id := range m.Mentions {
+		mentions = append(mentions, &Mention{
+			MessageID:       m.ID,
+			TipID:           m.TipID,
+			MentionedUserID: id,
+			MentionedBy:     m.UserID,
+			CreatedAt:       m.CreatedAt,
+		})
+	}
+	return mentions
+}
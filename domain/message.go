@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -12,19 +13,43 @@ type TipID string
 type UserID string
 
 type Message struct {
-	ID        MessageID  // メッセージ全部を識別する用途
-	TipID     TipID      // 各メッセージがどのTipID（実質チャットルーム）に属するか識別する用
-	UserID    UserID     // メッセージの送信主識別する用
-	Content   string     // メッセージの文章
-	CreatedAt time.Time  // メッセージの送信日時
-	UpdatedAt time.Time  // CreatedAtと比較して未編集かは判定できるのと、nil持たせてもあんまり意味ないのでポインタ型にはしない
-	DeletedAt *time.Time // 削除されてないという状態を分かりやすくしたい（nil使いたい）のでポインタ型
-	IsAuthor  bool       // メッセージが投稿主のものかどうかUI制御するためのフラグ（永続化はしない）
+	ID            MessageID      // メッセージ全部を識別する用途
+	TipID         TipID          // 各メッセージがどのTipID（実質チャットルーム）に属するか識別する用
+	UserID        UserID         // メッセージの送信主識別する用
+	Content       string         // メッセージの文章
+	CreatedAt     time.Time      // メッセージの送信日時
+	UpdatedAt     time.Time      // CreatedAtと比較して未編集かは判定できるのと、nil持たせてもあんまり意味ないのでポインタ型にはしない
+	DeletedAt     *time.Time     // 削除されてないという状態を分かりやすくしたい（nil使いたい）のでポインタ型
+	HiddenAt      *time.Time     // 通報が一定数集まって自動非表示になった日時。非表示でなければnil
+	Mentions      []UserID       // Contentから取り出した「@ユーザーID」のメンション（message_mentionsに永続化する）
+	AttachmentIDs []AttachmentID // 添付ファイルのID（attachments.message_idで紐づける）
+	ClientMsgID   string         // クライアントが生成した送信の識別子（再送時の重複防止用）。未指定なら空
+	Version       int64          // 編集・削除のたびに1ずつ増えるバージョン（楽観的排他制御用）。送信時は1
+	IsAuthor      bool           // メッセージが投稿主のものかどうかUI制御するためのフラグ（永続化はしない）
+}
+
+// 同じユーザーが同じTipに同じClientMsgIDで送信済みであることを表すエラー
+var ErrDuplicateClientMsgID = errors.New("同じclient_msg_idのメッセージが送信済みです")
+
+// 編集・削除の対象のメッセージが、操作を受け付けたTipとは別のTipのものであることを表すエラー
+var ErrTipMismatch = errors.New("このメッセージは別のTipのものです")
+
+// 編集・削除の対象のメッセージが、クライアントが想定しているバージョンから更新されていたことを表すエラー
+// クライアントが最新の内容で再編集できるように、現在のバージョンと内容を持たせる
+type VersionConflictError struct {
+	MessageID      MessageID
+	CurrentVersion int64
+	CurrentContent string
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("メッセージ%sは他の操作で更新されています（現在のバージョン: %d）", e.MessageID, e.CurrentVersion)
 }
 
 // メッセージのファクトリ関数定義
-func NewMessage(id MessageID, tipID TipID, userID UserID, content string, isAuthor bool) (*Message, error) {
-	if content == "" {
+// 添付ファイルがある場合は本文が空でもよい
+func NewMessage(id MessageID, tipID TipID, userID UserID, content string, attachmentIDs []AttachmentID, isAuthor bool) (*Message, error) {
+	if content == "" && len(attachmentIDs) == 0 {
 		return nil, errors.New("メッセージが空")
 	}
 
@@ -32,16 +57,21 @@ func NewMessage(id MessageID, tipID TipID, userID UserID, content string, isAuth
 	// IDはこのアプリでは意味を持たず単なる識別用でしか使わないので、ファクトリ関数内で初期化しない。
 	now := time.Now()
 
-	return &Message{
-		ID:        id,
-		TipID:     tipID,
-		UserID:    userID,
-		Content:   content,
-		CreatedAt: now,
-		UpdatedAt: now,
-		DeletedAt: nil, // 削除済み等のUI表示をするというドメインモデルの一部になるのでファクトリ関数内でnilで初期化する
-		IsAuthor:  isAuthor,
-	}, nil
+	msg := &Message{
+		ID:            id,
+		TipID:         tipID,
+		UserID:        userID,
+		Content:       content,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		DeletedAt:     nil, // 削除済み等のUI表示をするというドメインモデルの一部になるのでファクトリ関数内でnilで初期化する
+		HiddenAt:      nil,
+		AttachmentIDs: attachmentIDs,
+		Version:       1,
+		IsAuthor:      isAuthor,
+	}
+	msg.RefreshMentions()
+	return msg, nil
 }
 
 // TODO:メッセージの所有権を検証する関数を共通化して切り出すかどうか決める
@@ -57,6 +87,15 @@ func (m *Message) ValidateOwnership(userID UserID) bool {
 }
 */
 
+// クライアントが想定しているバージョンと現在のバージョンが一致するか検証する
+// expectedが0の場合（クライアントがバージョンを送ってこなかった場合）は検証しない
+func (m *Message) CheckVersion(expected int64) error {
+	if expected == 0 || expected == m.Version {
+		return nil
+	}
+	return &VersionConflictError{MessageID: m.ID, CurrentVersion: m.Version, CurrentContent: m.Content}
+}
+
 // メッセージの編集処理（ヒープメモリ上のMessageの実体に対する書き換え）
 func (m *Message) SetEditedContent(userID UserID, newContent string) error {
 
@@ -71,14 +110,15 @@ func (m *Message) SetEditedContent(userID UserID, newContent string) error {
 		return errors.New("このメッセージはすでに削除されています")
 	}
 
-	// 編集するメッセージが空の文字列の場合はエラーを返す
-	if newContent == "" {
+	// 編集するメッセージが空の文字列の場合はエラーを返す（添付ファイルがある場合は空でもよい）
+	if newContent == "" && len(m.AttachmentIDs) == 0 {
 		return errors.New("メッセージ内容が空です")
 	}
 
 	// if文全て通過したら、mのポインタが指すメモリ上のMessageインスタンスのContent、UpdatedAtフィールドをそれぞれ新しい値で書き換える。
 	m.Content = newContent
 	m.UpdatedAt = time.Now()
+	m.RefreshMentions()
 	return nil
 }
 
@@ -100,3 +140,35 @@ func (m *Message) SetDeletedMessage(userID UserID) error {
 	m.DeletedAt = &now
 	return nil
 }
+
+// モデレーターによるメッセージの削除処理
+// 所有権の検証は行わない（モデレーターかどうかの判定はユースケース層で行う）
+func (m *Message) SetDeletedByModerator() error {
+	if m.DeletedAt != nil {
+		return errors.New("このメッセージはすでに削除済みです")
+	}
+	now := time.Now()
+	m.DeletedAt = &now
+	return nil
+}
+
+// 通報数が閾値に達したメッセージを非表示にする
+// すでに非表示、または削除済みの場合は何もせずfalseを返す
+func (m *Message) Hide() bool {
+	if m.HiddenAt != nil || m.DeletedAt != nil {
+		return false
+	}
+	now := time.Now()
+	m.HiddenAt = &now
+	return true
+}
+
+// 通報が却下された場合に非表示を解除する
+// 非表示でなかった場合はfalseを返す
+func (m *Message) Unhide() bool {
+	if m.HiddenAt == nil {
+		return false
+	}
+	m.HiddenAt = nil
+	return true
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "errors"
+
+var ErrNotModerator = errors.New("モデレーター権限がありません")
+
+// モデレーター（通報の確認や他人のメッセージの削除ができるユーザー）の集合
+// 現状は環境変数で指定されたユーザーIDをそのまま使う（今後JWTのロールに置き換える）
+type ModeratorSet map[UserID]struct{}
+
+// ユーザーIDのスライスからModeratorSetを生成する。空文字は無視する
+func NewModeratorSet(userIDs []string) ModeratorSet {
+	s := make(ModeratorSet, len(userIDs))
+	for _, id := range userIDs {
+		if id == "" {
+			continue
+		}
+		s[UserID(id)] = struct{}{}
+	}
+	return s
+}
+
+// 引数のユーザーがモデレーターかどうか
+func (s ModeratorSet) IsModerator(userID UserID) bool {
+	_, ok := s[userID]
+	return ok
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// アウトボックスに書き込むメッセージのイベントの種類
+type OutboxEventType string
+
+const (
+	OutboxEventMessageSent     OutboxEventType = "message.sent"
+	OutboxEventMessageEdited   OutboxEventType = "message.edited"
+	OutboxEventMessageDeleted  OutboxEventType = "message.deleted"
+	OutboxEventMessageHidden   OutboxEventType = "message.hidden"   // 通報数が閾値に達して自動非表示になった
+	OutboxEventMessageUnhidden OutboxEventType = "message.unhidden" // 通報の却下で非表示が解除された
+	OutboxEventMessageUnpinned OutboxEventType = "message.unpinned" // 論理削除に伴ってピン留めが解除された
+)
+
+// アウトボックスのイベントのドメインモデル
+// メッセージの保存・編集・論理削除・非表示状態の更新と同じトランザクションで書き込まれ、リレーがWebSocketや外部のシンクに届ける
+type OutboxEvent struct {
+	ID          int64           // 書き込み時に採番されるID（コミット順とは限らない）
+	Seq         int64           // リレーが振るコミット順の連番（この順に届ける）。まだ振られていなければ0
+	Type        OutboxEventType // イベントの種類
+	Message     *Message        // イベント発生時点のメッセージ
+	CreatedAt   time.Time       // イベントの発生日時
+	PublishedAt *time.Time      // 永続的なシンク全てに届けた日時。未配信ならnil
+}
+
+// アウトボックスのイベントの配信先
+// 少なくとも1回の配信なので、同じイベントが複数回届いても問題ないように実装する
+// インスタンスごとのシンク（Hub）はエラーを返しても配信し直さない
+// 永続的なシンク（Webhook等）がエラーを返すと、そのイベントは確保の期限が切れた後のリレーで永続的なシンク全てに配信し直される
+// どちらの場合も、他のシンクへの配信と後続のイベントの配信は止めない
+type EventSink interface {
+	Name() string
+	Publish(ctx context.Context, event *OutboxEvent) error
+}
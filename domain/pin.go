@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPinForbidden    = errors.New("ピン留めする権限がありません")
+	ErrPinLimitReached = errors.New("このTipのピン留め数が上限に達しています")
+	ErrAlreadyPinned   = errors.New("このメッセージはすでにピン留めされています")
+	ErrNotPinned       = errors.New("このメッセージはピン留めされていません")
+	ErrPinDeleted      = errors.New("削除済みのメッセージはピン留めできません")
+)
+
+// Tip内でピン留めされたメッセージのドメインモデル
+type Pin struct {
+	TipID     TipID
+	MessageID MessageID
+	Position  int       // Tip内での並び順（小さいほど先にピン留めされた）。採番は永続化時に行う
+	PinnedBy  UserID    // ピン留めしたユーザー
+	PinnedAt  time.Time // ピン留めした日時
+	Message   *Message  // 一覧表示用のメッセージ（永続化はしない）
+}
+
+// ピン留めのファクトリ関数定義
+// 論理削除済みのメッセージや、別のTipのメッセージはピン留めできない
+func NewPin(tipID TipID, msg *Message, pinnedBy UserID) (*Pin, error) {
+	if msg.TipID != tipID {
+		return nil, errors.New("別のTipのメッセージはピン留めできません")
+	}
+	if msg.DeletedAt != nil {
+		return nil, ErrPinDeleted
+	}
+	return &Pin{
+		TipID:     tipID,
+		MessageID: msg.ID,
+		PinnedBy:  pinnedBy,
+		PinnedAt:  time.Now(),
+	}, nil
+}
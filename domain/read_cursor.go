@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ユーザーごと・Tipごとの既読位置のドメインモデル
+// 既読位置はメッセージ単位で持ち、そのメッセージの送信日時以前のメッセージを既読とみなす
+type ReadCursor struct {
+	TipID             TipID
+	UserID            UserID
+	LastReadMessageID MessageID // 最後に既読にしたメッセージ
+	LastReadAt        time.Time // 最後に既読にしたメッセージの送信日時（未読数の計算に使う）
+	UpdatedAt         time.Time // 既読位置を更新した日時
+}
+
+// Tipごとの未読数
+type UnreadCount struct {
+	TipID TipID
+	Count int
+}
+
+// 既読位置を引数のメッセージまで進める
+// 既読位置は後戻りさせないので、現在の位置より古いメッセージが指定された場合は何もせずfalseを返す
+func (c *ReadCursor) AdvanceTo(msg *Message) (bool, error) {
+	if msg.TipID != c.TipID {
+		return false, errors.New("別のTipのメッセージは既読にできません")
+	}
+	if c.LastReadMessageID != "" && !msg.CreatedAt.After(c.LastReadAt) {
+		return false, nil
+	}
+	c.LastReadMessageID = msg.ID
+	c.LastReadAt = msg.CreatedAt
+	c.UpdatedAt = time.Now()
+	return true, nil
+}
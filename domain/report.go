@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ユーザーによるメッセージ通報のドメインモデル
+// 通報はモデレーションキュー（message_reportsテーブル）に積まれ、モデレーターが却下か削除で解決する
+
+type ReportID string
+
+// 通報理由のカテゴリ
+type ReportReason string
+
+const (
+	ReportReasonSpam          ReportReason = "spam"          // スパム・宣伝
+	ReportReasonHarassment    ReportReason = "harassment"    // 嫌がらせ・誹謗中傷
+	ReportReasonInappropriate ReportReason = "inappropriate" // 不適切な内容
+	ReportReasonOther         ReportReason = "other"         // その他（詳細はDetailに記載）
+)
+
+// 通報の状態
+type ReportStatus string
+
+const (
+	ReportStatusPending   ReportStatus = "pending"   // モデレーター未対応
+	ReportStatusDismissed ReportStatus = "dismissed" // 問題なしとして却下
+	ReportStatusDeleted   ReportStatus = "deleted"   // メッセージを削除して解決
+)
+
+// モデレーターが通報を解決する際のアクション
+type ReportAction string
+
+const (
+	ReportActionDismiss ReportAction = "dismiss"
+	ReportActionDelete  ReportAction = "delete"
+)
+
+var (
+	ErrAlreadyReported = errors.New("このメッセージはすでに通報済みです")
+	ErrReportNotFound  = errors.New("通報が見つかりません")
+	// 他のモデレーターが先に解決した（解決は通報の状態がpendingの場合だけ行う）
+	ErrReportAlreadyResolved = errors.New("この通報はすでに対応済みです")
+)
+
+type Report struct {
+	ID          ReportID
+	MessageID   MessageID // 通報対象のメッセージ
+	TipID       TipID     // 通報対象のメッセージが属するTipID
+	ReporterID  UserID    // 通報したユーザー
+	Reason      ReportReason
+	Detail      string // 任意の補足説明
+	Status      ReportStatus
+	CreatedAt   time.Time
+	ResolvedAt  *time.Time // 未解決ならnil
+	ResolvedBy  *UserID    // 解決したモデレーター。未解決ならnil
+	Message     *Message   // 一覧表示用の通報対象メッセージ（永続化はしない）
+	ReportCount int        // 同一メッセージに対する通報数（永続化はしない）
+}
+
+// 通報理由が定義済みのカテゴリかどうか
+func (r ReportReason) IsValid() bool {
+	switch r {
+	case ReportReasonSpam, ReportReasonHarassment, ReportReasonInappropriate, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+// 通報のファクトリ関数定義
+// 自分のメッセージや削除済みのメッセージは通報できない
+func NewReport(id ReportID, msg *Message, reporterID UserID, reason ReportReason, detail string) (*Report, error) {
+	if !reason.IsValid() {
+		return nil, errors.New("通報理由が不正です")
+	}
+	if msg.UserID == reporterID {
+		return nil, errors.New("自分のメッセージは通報できません")
+	}
+	if msg.DeletedAt != nil {
+		return nil, errors.New("このメッセージはすでに削除されています")
+	}
+
+	return &Report{
+		ID:         id,
+		MessageID:  msg.ID,
+		TipID:      msg.TipID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Detail:     detail,
+		Status:     ReportStatusPending,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// モデレーターのアクションに対応する解決後の状態を返す
+func (a ReportAction) ResolvedStatus() (ReportStatus, error) {
+	switch a {
+	case ReportActionDismiss:
+		return ReportStatusDismissed, nil
+	case ReportActionDelete:
+		return ReportStatusDeleted, nil
+	}
+	return "", errors.New("通報の解決アクションが不正です")
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "errors"
+
+// Tipへのアクセスが許可されていないことを表すエラー
+var ErrTipAccessDenied = errors.New("このTipへのアクセス権限がありません")
+
+// Tipの公開範囲
+// Tip自体はメインのAPI側で管理しているので、チャットAPIでは公開範囲の設定だけを持つ
+type TipVisibility string
+
+const (
+	TipVisibilityPublic  TipVisibility = "public"  // 誰でも履歴の閲覧とRoomへの参加ができる
+	TipVisibilityPrivate TipVisibility = "private" // 参加者だけが履歴の閲覧とRoomへの参加ができる
+)
+
+// Tipに対する操作の種類
+type TipAccess string
+
+const (
+	TipAccessRead  TipAccess = "read"  // 履歴の閲覧、Roomへの参加、既読・通報
+	TipAccessWrite TipAccess = "write" // メッセージの送信・編集・削除、ピン留め、添付ファイルのアップロード
+)
+
+// Tipの公開範囲とユーザーの役割から、操作が許可されるかどうかを判定する
+// 書き込みは公開範囲にかかわらずTipの参加者（オーナー・モデレーター・メンバー）だけができる
+func CanAccessTip(visibility TipVisibility, role TipRole, access TipAccess) bool {
+	if role != TipRoleNone {
+		return true
+	}
+	return access == TipAccessRead && visibility != TipVisibilityPrivate
+}
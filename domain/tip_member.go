@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// Tipに対するユーザーの役割
+// Tip自体はメインのAPI側で管理しているので、チャットAPIではTipとユーザーの関係だけを持つ
+type TipRole string
+
+const (
+	TipRoleOwner     TipRole = "owner"     // Tipの作成者
+	TipRoleModerator TipRole = "moderator" // Tip内のモデレーター（ピン留め等ができる）
+	TipRoleMember    TipRole = "member"    // 一般の参加者
+	TipRoleNone      TipRole = ""          // Tipに参加していない
+)
+
+// Tipの参加者
+type TipMember struct {
+	TipID    TipID
+	UserID   UserID
+	Role     TipRole
+	JoinedAt time.Time
+}
+
+// Tip内のメッセージを管理（ピン留め等）できる役割かどうか
+func (r TipRole) CanManageMessages() bool {
+	return r == TipRoleOwner || r == TipRoleModerator
+}
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type WebhookID string
+type WebhookDeliveryID int64
+
+var (
+	ErrWebhookNotFound   = errors.New("Webhookが見つかりません")
+	ErrInvalidWebhookURL = errors.New("WebhookのURLはhttpまたはhttpsの絶対URLで指定してください")
+)
+
+// 運営者が登録したWebhookのドメインモデル
+// TipIDがnilの場合は全てのTipのイベントを受け取る
+type Webhook struct {
+	ID        WebhookID
+	TipID     *TipID    // 対象のTip。nilなら全Tip
+	URL       string    // イベントをPOSTする先
+	Secret    string    // 署名（HMAC-SHA256）の鍵。登録時にだけ利用者に返す
+	CreatedBy UserID    // 登録したモデレーター
+	CreatedAt time.Time // 登録日時
+}
+
+// Webhookのファクトリ関数定義
+func NewWebhook(id WebhookID, tipID *TipID, rawURL string, secret string, createdBy UserID) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if secret == "" {
+		return nil, errors.New("Webhookの署名用の鍵が空です")
+	}
+	return &Webhook{
+		ID:        id,
+		TipID:     tipID,
+		URL:       rawURL,
+		Secret:    secret,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// ペイロードの署名を計算する
+// 受信側はX-Tipstar-Timestampの値と本文を"."でつないだものを同じ鍵でHMAC-SHA256し、X-Tipstar-Signatureと比較して検証する
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhookの配信状態
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // 配信待ち（リトライ待ちを含む）
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // 2xxが返ってきた
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // 最大試行回数まで失敗した（これ以上リトライしない）
+)
+
+// 1つのイベントを1つのWebhookに届ける配信のドメインモデル
+type WebhookDelivery struct {
+	ID            WebhookDeliveryID // 永続化時に採番する
+	WebhookID     WebhookID
+	EventID       int64           // 元になったアウトボックスのイベントのID（同じイベントを同じWebhookに二重に登録しない）
+	EventType     OutboxEventType // イベントの種類
+	Payload       []byte          // 送信するJSON（リトライでも同じものを送る）
+	Status        WebhookDeliveryStatus
+	Attempts      int               // これまでの試行回数
+	NextAttemptAt time.Time         // 次に試行する日時
+	CreatedAt     time.Time         // 配信を登録した日時
+	History       []*WebhookAttempt // 試行の履歴（一覧表示用）
+}
+
+// 1回の配信の試行結果
+type WebhookAttempt struct {
+	DeliveryID  WebhookDeliveryID
+	AttemptNo   int       // 何回目の試行か（1始まり）
+	StatusCode  int       // 受信側が返したHTTPステータス。接続できなかった場合は0
+	Error       string    // 失敗の理由。成功なら空
+	AttemptedAt time.Time // 試行した日時
+}
+
+// 配信のファクトリ関数定義
+func NewWebhookDelivery(webhookID WebhookID, event *OutboxEvent, payload []byte) *WebhookDelivery {
+	now := time.Now()
+	return &WebhookDelivery{
+		WebhookID:     webhookID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+// 配信のリトライ方針（指数バックオフ）
+type WebhookRetryPolicy struct {
+	MaxAttempts int           // この回数失敗したらdeadにする
+	BaseDelay   time.Duration // 1回目の失敗後の待ち時間。以降失敗するたびに倍にする
+	MaxDelay    time.Duration // 待ち時間の上限
+}
+
+// attempts回失敗した後、次の試行までの待ち時間
+func (p WebhookRetryPolicy) Backoff(attempts int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+// 試行の結果を配信に反映し、履歴に追加する試行結果を返す
+// 2xxなら成功、それ以外はリトライ待ちに戻し、最大試行回数に達したらdeadにする
+func (d *WebhookDelivery) RecordAttempt(statusCode int, sendErr error, policy WebhookRetryPolicy) *WebhookAttempt {
+	now := time.Now()
+	d.Attempts++
+	attempt := &WebhookAttempt{
+		DeliveryID:  d.ID,
+		AttemptNo:   d.Attempts,
+		StatusCode:  statusCode,
+		AttemptedAt: now,
+	}
+	switch {
+	case sendErr != nil:
+		attempt.Error = sendErr.Error()
+	case statusCode < 200 || statusCode >= 300:
+		attempt.Error = "2xx以外のステータスが返されました: " + strconv.Itoa(statusCode)
+	default:
+		d.Status = WebhookDeliverySucceeded
+		return attempt
+	}
+	if d.Attempts >= policy.MaxAttempts {
+		d.Status = WebhookDeliveryDead
+		return attempt
+	}
+	d.NextAttemptAt = now.Add(policy.Backoff(d.Attempts))
+	return attempt
+}
+
+// Webhookへの送信リクエスト
+type WebhookRequest struct {
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// WebhookをHTTPで送信するインターフェース
+// 受信側が返したHTTPステータスを返す。接続できなかった場合などはエラーを返す
+type WebhookSender interface {
+	Send(ctx context.Context, req *WebhookRequest) (int, error)
+}
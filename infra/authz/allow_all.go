@@ -0,0 +1,20 @@
+package authz
+
+// ドメイン層で定義したTipAuthorizerの、全てのアクセスを許可する実装
+// tip_membersやtip_settingsを用意していないローカル開発用。本番では使わない
+
+import (
+	"context"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type AllowAllTipAuthorizer struct{}
+
+func NewAllowAllTipAuthorizer() domain.TipAuthorizer {
+	return &AllowAllTipAuthorizer{}
+}
+
+func (a *AllowAllTipAuthorizer) Authorize(ctx context.Context, tipID domain.TipID, userID domain.UserID, access domain.TipAccess) error {
+	return nil
+}
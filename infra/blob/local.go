@@ -0,0 +1,82 @@
+package blob
+
+// ドメイン層で定義したBlobStoreの実装（ローカルファイルシステム）
+// サーバーが1台の間はこれを使い、複数台にする場合はS3等の実装に差し替える
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type LocalBlobStore struct {
+	baseDir string // 実体を保存するディレクトリ
+}
+
+// 保存先のディレクトリを作成してLocalBlobStoreをインスタンス化する
+func NewLocalBlobStore(baseDir string) (domain.BlobStore, error) {
+	if err := os.MkdirAll(baseDir, 0o750); err != nil {
+		return nil, fmt.Errorf("添付ファイルの保存先ディレクトリの作成に失敗しました: %w", err)
+	}
+	return &LocalBlobStore{baseDir: baseDir}, nil
+}
+
+// 一時ファイルに書き込んでからリネームすることで、書き込み途中のファイルを読み出さないようにする
+func (s *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.baseDir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // リネーム済みなら何もしない
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrAttachmentNotFound
+	}
+	return f, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// キーから保存先のパスを組み立てる。ディレクトリの外を指すキーは受け付けない
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", fmt.Errorf("不正なキーです: %q", key)
+	}
+	return filepath.Join(s.baseDir, key), nil
+}
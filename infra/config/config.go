@@ -0,0 +1,293 @@
+package config
+
+// サーバー全体の設定を型付きで一箇所にまとめる
+// 読み込みの優先順位は「デフォルト値 < YAMLファイル < 環境変数」
+// 環境変数の名前は各フィールドのenvタグ、YAMLのキーはyamlタグで指定する。secretタグが付いたフィールドは表示時に伏せる
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+	"github.com/minminseo/tipstar-chat-api/infra/tracing"
+)
+
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Log        LogConfig        `yaml:"log"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Origins    OriginsConfig    `yaml:"origins"`
+	Access     AccessConfig     `yaml:"access"`
+	WebSocket  WebSocketConfig  `yaml:"websocket"`
+	SSE        SSEConfig        `yaml:"sse"`
+	LongPoll   LongPollConfig   `yaml:"long_poll"`
+	Attachment AttachmentConfig `yaml:"attachment"`
+	Moderation ModerationConfig `yaml:"moderation"`
+	Filter     FilterConfig     `yaml:"filter"`
+	Outbox     OutboxConfig     `yaml:"outbox"`
+	Webhook    WebhookConfig    `yaml:"webhook"`
+}
+
+// HTTPサーバーの起動と停止の設定
+type ServerConfig struct {
+	Port               string        `yaml:"port" env:"PORT"`
+	ReadinessTimeout   time.Duration `yaml:"readiness_timeout" env:"READINESS_TIMEOUT"`       // /readyzのチェック全体にかける時間の上限
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY"` // /readyzを503にしてから停止を始めるまでの待ち時間
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`         // 処理中のリクエストを待つ時間の上限
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" env:"DATABASE_URL" secret:"true"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL"` // "debug", "info", "warn", "error"
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"` // "none", "stdout", "otlp"
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// ブラウザからのWebSocketの接続とREST APIの呼び出し（CORS）を許可するOrigin
+type OriginsConfig struct {
+	Allowed []string `yaml:"allowed" env:"ALLOWED_ORIGINS"`  // "https://app.example.com"や"https://*.example.com"の形式
+	DevMode bool     `yaml:"dev_mode" env:"ORIGIN_DEV_MODE"` // trueなら全てのOriginを許可する（ローカル開発用。本番では使わない）
+}
+
+// Tipへのアクセス制御の設定
+type AccessConfig struct {
+	TipAuthorizer string `yaml:"tip_authorizer" env:"TIP_AUTHORIZER"` // "postgres"（tip_membersとtip_settingsで判定）, "allow_all"（ローカル開発用。本番では使わない）
+}
+
+// WebSocketの接続とRoomの管理の設定
+type WebSocketConfig struct {
+	SendBufferSize   int           `yaml:"send_buffer_size" env:"WS_SEND_BUFFER_SIZE"`     // 接続ごとの送信チャネルのバッファ数
+	RoomIdleTimeout  time.Duration `yaml:"room_idle_timeout" env:"WS_ROOM_IDLE_TIMEOUT"`   // この時間アクティビティが無いRoomの接続を閉じる
+	HubSweepInterval time.Duration `yaml:"hub_sweep_interval" env:"WS_HUB_SWEEP_INTERVAL"` // アイドリングしたRoomを掃除する間隔
+	MaxSubscriptions int           `yaml:"max_subscriptions" env:"WS_MAX_SUBSCRIPTIONS"`   // /wsの1つの接続が同時に購読できるTipの数
+
+	CompressionEnabled   bool `yaml:"compression_enabled" env:"WS_COMPRESSION_ENABLED"`     // クライアントが対応していればpermessage-deflateで圧縮する
+	CompressionLevel     int  `yaml:"compression_level" env:"WS_COMPRESSION_LEVEL"`         // 圧縮レベル（-2〜9。1が最速、9が最小。-2はハフマン符号化のみ）
+	CompressionThreshold int  `yaml:"compression_threshold" env:"WS_COMPRESSION_THRESHOLD"` // このバイト数未満のフレームは圧縮しない（小さいフレームは圧縮しても縮まない）
+
+	BatchMaxDelay  time.Duration `yaml:"batch_max_delay" env:"WS_BATCH_MAX_DELAY"`   // 送信で後続のフレームをまとめるために待つ最大時間（配信の遅延の上限。0なら溜まっている分だけまとめる）
+	BatchMaxFrames int           `yaml:"batch_max_frames" env:"WS_BATCH_MAX_FRAMES"` // 送信で1回の書き込みにまとめる最大のフレーム数
+}
+
+// WebSocketが使えない環境向けのServer-Sent Eventsの設定
+type SSEConfig struct {
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env:"SSE_HEARTBEAT_INTERVAL"` // プロキシに切られないようにハートビートのコメントを送る間隔
+	ReplayBatchSize   int           `yaml:"replay_batch_size" env:"SSE_REPLAY_BATCH_SIZE"`   // 再接続時にLast-Event-ID以降のイベントを1回に取得する件数
+}
+
+// WebSocketもSSEも使えないクライアント向けのロングポーリングの設定
+type LongPollConfig struct {
+	Timeout   time.Duration `yaml:"timeout" env:"LONG_POLL_TIMEOUT"`       // イベントが届かない場合にリクエストを保留しておく時間の上限
+	MaxEvents int           `yaml:"max_events" env:"LONG_POLL_MAX_EVENTS"` // 1回の応答で返すイベント数の上限
+}
+
+type AttachmentConfig struct {
+	Dir          string   `yaml:"dir" env:"ATTACHMENT_DIR"`
+	MaxBytes     int64    `yaml:"max_bytes" env:"ATTACHMENT_MAX_BYTES"`
+	AllowedTypes []string `yaml:"allowed_types" env:"ATTACHMENT_ALLOWED_TYPES"`
+}
+
+type ModerationConfig struct {
+	ModeratorUserIDs    []string `yaml:"moderator_user_ids" env:"MODERATOR_USER_IDS"`
+	ReportHideThreshold int      `yaml:"report_hide_threshold" env:"REPORT_HIDE_THRESHOLD"` // 自動非表示にする通報者数
+	MaxPinsPerTip       int      `yaml:"max_pins_per_tip" env:"MAX_PINS_PER_TIP"`
+}
+
+// 送信・編集時に適用するコンテンツフィルターの設定
+type FilterConfig struct {
+	SpamWindow        time.Duration       `yaml:"spam_window" env:"FILTER_SPAM_WINDOW"`
+	SpamMaxRepeats    int                 `yaml:"spam_max_repeats" env:"FILTER_SPAM_MAX_REPEATS"`
+	SpamAction        domain.FilterAction `yaml:"spam_action" env:"FILTER_SPAM_ACTION"`
+	SpamSweepInterval time.Duration       `yaml:"spam_sweep_interval" env:"FILTER_SPAM_SWEEP_INTERVAL"` // 連投検出用の直近の投稿を掃除する間隔
+	BannedWords       []string            `yaml:"banned_words" env:"FILTER_BANNED_WORDS"`
+	BannedWordsAction domain.FilterAction `yaml:"banned_words_action" env:"FILTER_BANNED_WORDS_ACTION"`
+	URLAllowlist      []string            `yaml:"url_allowlist" env:"FILTER_URL_ALLOWLIST"`
+	URLDenylist       []string            `yaml:"url_denylist" env:"FILTER_URL_DENYLIST"`
+	URLAction         domain.FilterAction `yaml:"url_action" env:"FILTER_URL_ACTION"`
+}
+
+type OutboxConfig struct {
+	Sinks        []string      `yaml:"sinks" env:"OUTBOX_SINKS"` // Roomへのブロードキャストとwebhook以外の配信先（"log"）
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"`     // 配信済みのイベントを残しておく期間
+	ClaimLease   time.Duration `yaml:"claim_lease" env:"OUTBOX_CLAIM_LEASE"` // Webhook等への配信のために確保したイベントを、他のインスタンスに渡さない期間
+}
+
+type WebhookConfig struct {
+	Timeout        time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	MaxAttempts    int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay" env:"WEBHOOK_RETRY_BASE_DELAY"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay" env:"WEBHOOK_RETRY_MAX_DELAY"`
+	PollInterval   time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL"`
+	ClaimLease     time.Duration `yaml:"claim_lease" env:"WEBHOOK_CLAIM_LEASE"` // 送信のために確保した配信を他のインスタンスに渡さない期間（timeoutより長くする）
+}
+
+// 選べるアウトボックスの配信先
+const OutboxSinkLog = "log"
+
+// 選べるTipへのアクセスの判定方法
+const (
+	TipAuthorizerPostgres = "postgres"
+	TipAuthorizerAllowAll = "allow_all"
+)
+
+// 何も指定しなかった場合の設定
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               "8080",
+			ReadinessTimeout:   2 * time.Second,
+			ShutdownDrainDelay: 10 * time.Second,
+			ShutdownTimeout:    30 * time.Second,
+		},
+		Log: LogConfig{Level: "info"},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "tipstar-chat-api",
+		},
+		Access: AccessConfig{
+			TipAuthorizer: TipAuthorizerPostgres,
+		},
+		WebSocket: WebSocketConfig{
+			SendBufferSize:   256,
+			RoomIdleTimeout:  5 * time.Minute,
+			HubSweepInterval: 5 * time.Minute,
+			MaxSubscriptions: 50,
+
+			CompressionEnabled:   true,
+			CompressionLevel:     flate.BestSpeed,
+			CompressionThreshold: 512,
+
+			BatchMaxDelay:  5 * time.Millisecond,
+			BatchMaxFrames: 64,
+		},
+		SSE: SSEConfig{
+			HeartbeatInterval: 15 * time.Second,
+			ReplayBatchSize:   500,
+		},
+		LongPoll: LongPollConfig{
+			Timeout:   25 * time.Second,
+			MaxEvents: 100,
+		},
+		Attachment: AttachmentConfig{
+			Dir:          "./data/attachments",
+			MaxBytes:     10 << 20,
+			AllowedTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"},
+		},
+		Moderation: ModerationConfig{
+			ReportHideThreshold: 3,
+			MaxPinsPerTip:       10,
+		},
+		Filter: FilterConfig{
+			SpamWindow:        time.Minute,
+			SpamMaxRepeats:    3,
+			SpamAction:        domain.FilterActionReject,
+			SpamSweepInterval: 5 * time.Minute,
+			BannedWordsAction: domain.FilterActionMask,
+			URLAction:         domain.FilterActionReject,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: time.Second,
+			Retention:    24 * time.Hour,
+			ClaimLease:   30 * time.Second,
+		},
+		Webhook: WebhookConfig{
+			Timeout:        10 * time.Second,
+			MaxAttempts:    8,
+			RetryBaseDelay: 10 * time.Second,
+			RetryMaxDelay:  time.Hour,
+			PollInterval:   5 * time.Second,
+			ClaimLease:     time.Minute,
+		},
+	}
+}
+
+// 設定値を検証し、問題を全てまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(name string, d time.Duration) {
+		check(d > 0, "%sは0より大きい時間を指定してください: %s", name, d)
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port <= 65535, "server.port（PORT）が不正です: %q", c.Server.Port)
+	positive("server.readiness_timeout", c.Server.ReadinessTimeout)
+	check(c.Server.ShutdownDrainDelay >= 0, "server.shutdown_drain_delayは0以上を指定してください: %s", c.Server.ShutdownDrainDelay)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	check(c.Database.URL != "", "database.url（DATABASE_URL）が設定されていません")
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter（OTEL_TRACES_EXPORTER）が不正です: %s", c.Tracing.Exporter))
+	}
+
+	check(c.Origins.DevMode || len(c.Origins.Allowed) > 0, "origins.allowed（ALLOWED_ORIGINS）が空です。ローカル開発ではorigins.dev_mode（ORIGIN_DEV_MODE）をtrueにしてください")
+
+	check(c.Access.TipAuthorizer == TipAuthorizerPostgres || c.Access.TipAuthorizer == TipAuthorizerAllowAll, "access.tip_authorizer（TIP_AUTHORIZER）が不正です: %s", c.Access.TipAuthorizer)
+
+	check(c.WebSocket.SendBufferSize > 0, "websocket.send_buffer_sizeは1以上を指定してください: %d", c.WebSocket.SendBufferSize)
+	positive("websocket.room_idle_timeout", c.WebSocket.RoomIdleTimeout)
+	positive("websocket.hub_sweep_interval", c.WebSocket.HubSweepInterval)
+	check(c.WebSocket.MaxSubscriptions > 0, "websocket.max_subscriptionsは1以上を指定してください: %d", c.WebSocket.MaxSubscriptions)
+	check(c.WebSocket.CompressionLevel >= flate.HuffmanOnly && c.WebSocket.CompressionLevel <= flate.BestCompression, "websocket.compression_levelは-2から9の間で指定してください: %d", c.WebSocket.CompressionLevel)
+	check(c.WebSocket.CompressionThreshold >= 0, "websocket.compression_thresholdは0以上を指定してください: %d", c.WebSocket.CompressionThreshold)
+	check(c.WebSocket.BatchMaxDelay >= 0, "websocket.batch_max_delayは0以上を指定してください: %s", c.WebSocket.BatchMaxDelay)
+	check(c.WebSocket.BatchMaxFrames > 0, "websocket.batch_max_framesは1以上を指定してください: %d", c.WebSocket.BatchMaxFrames)
+
+	positive("sse.heartbeat_interval", c.SSE.HeartbeatInterval)
+	check(c.SSE.ReplayBatchSize > 0, "sse.replay_batch_sizeは1以上を指定してください: %d", c.SSE.ReplayBatchSize)
+
+	positive("long_poll.timeout", c.LongPoll.Timeout)
+	check(c.LongPoll.MaxEvents > 0, "long_poll.max_eventsは1以上を指定してください: %d", c.LongPoll.MaxEvents)
+
+	check(c.Attachment.Dir != "", "attachment.dirが設定されていません")
+	check(c.Attachment.MaxBytes > 0, "attachment.max_bytesは1以上を指定してください: %d", c.Attachment.MaxBytes)
+	check(len(c.Attachment.AllowedTypes) > 0, "attachment.allowed_typesが空です")
+
+	check(c.Moderation.ReportHideThreshold > 0, "moderation.report_hide_thresholdは1以上を指定してください: %d", c.Moderation.ReportHideThreshold)
+	check(c.Moderation.MaxPinsPerTip > 0, "moderation.max_pins_per_tipは1以上を指定してください: %d", c.Moderation.MaxPinsPerTip)
+
+	positive("filter.spam_window", c.Filter.SpamWindow)
+	check(c.Filter.SpamMaxRepeats > 0, "filter.spam_max_repeatsは1以上を指定してください: %d", c.Filter.SpamMaxRepeats)
+	positive("filter.spam_sweep_interval", c.Filter.SpamSweepInterval)
+	check(c.Filter.SpamAction.IsValid(), "filter.spam_actionが不正です: %s", c.Filter.SpamAction)
+	check(c.Filter.BannedWordsAction.IsValid(), "filter.banned_words_actionが不正です: %s", c.Filter.BannedWordsAction)
+	check(c.Filter.URLAction.IsValid(), "filter.url_actionが不正です: %s", c.Filter.URLAction)
+
+	for _, sink := range c.Outbox.Sinks {
+		check(sink == OutboxSinkLog, "outbox.sinks（OUTBOX_SINKS）が不正です: %s", sink)
+	}
+	positive("outbox.poll_interval", c.Outbox.PollInterval)
+	positive("outbox.retention", c.Outbox.Retention)
+	positive("outbox.claim_lease", c.Outbox.ClaimLease)
+
+	positive("webhook.timeout", c.Webhook.Timeout)
+	check(c.Webhook.MaxAttempts > 0, "webhook.max_attemptsは1以上を指定してください: %d", c.Webhook.MaxAttempts)
+	positive("webhook.retry_base_delay", c.Webhook.RetryBaseDelay)
+	check(c.Webhook.RetryMaxDelay >= c.Webhook.RetryBaseDelay, "webhook.retry_max_delayはretry_base_delay以上を指定してください: %s", c.Webhook.RetryMaxDelay)
+	positive("webhook.poll_interval", c.Webhook.PollInterval)
+	check(c.Webhook.ClaimLease > c.Webhook.Timeout, "webhook.claim_leaseはtimeoutより長く指定してください: %s", c.Webhook.ClaimLease)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,35 @@
+package config
+
+// 実際に使われる設定の表示（config printサブコマンド用）
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// secretタグの付いたフィールドを伏せたコピーを返す
+func (c *Config) Redacted() *Config {
+	cp := *c
+	redact(reflect.ValueOf(&cp).Elem())
+	return &cp
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field, def := v.Field(i), v.Type().Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redact(field)
+		case def.Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "":
+			field.SetString(redacted)
+		}
+	}
+}
+
+// 秘密の値を伏せた設定をYAMLで返す（そのまま設定ファイルとして使える形式）
+func (c *Config) RedactedYAML() ([]byte, error) {
+	return yaml.Marshal(c.Redacted())
+}
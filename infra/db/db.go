@@ -13,8 +13,15 @@ func NewDB(databaseURL string) (*pgxpool.Pool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("DATABASE_URLの解析に失敗しました: %w", err)
+	}
+	// クエリごとにトレースのスパンを記録する
+	config.ConnConfig.Tracer = queryTracer{}
+
 	// コネクションプール作成
-	pool, err := pgxpool.New(ctx, databaseURL)
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("DB接続プールの初期化に失敗しました: %w", err)
 	}
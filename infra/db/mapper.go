@@ -1,12 +1,15 @@
 package db
 
 import (
+	"encoding/json"
+
 	"github.com/minminseo/tipstar-chat-api/domain"
 )
 
 // DB構造体をドメインモデル構造体に変換する関数
+// メンションはContentから導出できるので、変換時にContentから再計算する
 func ToDomainModel(m *MessageModel, isAuthor bool) *domain.Message {
-	return &domain.Message{
+	msg := &domain.Message{
 		ID:        domain.MessageID(m.ID),
 		TipID:     domain.TipID(m.TipID),
 		UserID:    domain.UserID(m.UserID),
@@ -14,19 +17,330 @@ func ToDomainModel(m *MessageModel, isAuthor bool) *domain.Message {
 		CreatedAt: m.CreatedAt,
 		UpdatedAt: m.UpdatedAt,
 		DeletedAt: m.DeletedAt,
+		HiddenAt:  m.HiddenAt,
+		Version:   m.Version,
 		IsAuthor:  isAuthor,
 	}
+	for _, id := range m.AttachmentIDs {
+		msg.AttachmentIDs = append(msg.AttachmentIDs, domain.AttachmentID(id))
+	}
+	if m.ClientMsgID != nil {
+		msg.ClientMsgID = *m.ClientMsgID
+	}
+	msg.RefreshMentions()
+	return msg
 }
 
 // ドメインモデル構造体をDBモデル構造体に変換する関数
 func ToDbModel(msg *domain.Message) *MessageModel {
+	attachmentIDs := make([]string, 0, len(msg.AttachmentIDs))
+	for _, id := range msg.AttachmentIDs {
+		attachmentIDs = append(attachmentIDs, string(id))
+	}
+	var clientMsgID *string
+	if msg.ClientMsgID != "" {
+		clientMsgID = &msg.ClientMsgID
+	}
 	return &MessageModel{
-		ID:        string(msg.ID),
-		TipID:     string(msg.TipID),
-		UserID:    string(msg.UserID),
-		Content:   msg.Content,
-		CreatedAt: msg.CreatedAt,
-		UpdatedAt: msg.UpdatedAt,
-		DeletedAt: msg.DeletedAt,
+		ID:            string(msg.ID),
+		TipID:         string(msg.TipID),
+		UserID:        string(msg.UserID),
+		Content:       msg.Content,
+		CreatedAt:     msg.CreatedAt,
+		UpdatedAt:     msg.UpdatedAt,
+		DeletedAt:     msg.DeletedAt,
+		HiddenAt:      msg.HiddenAt,
+		ClientMsgID:   clientMsgID,
+		Version:       msg.Version,
+		AttachmentIDs: attachmentIDs,
+	}
+}
+
+// 通報のDB構造体をドメインモデル構造体に変換する関数
+func ToReportDomainModel(m *ReportModel) *domain.Report {
+	var resolvedBy *domain.UserID
+	if m.ResolvedBy != nil {
+		id := domain.UserID(*m.ResolvedBy)
+		resolvedBy = &id
+	}
+	return &domain.Report{
+		ID:         domain.ReportID(m.ID),
+		MessageID:  domain.MessageID(m.MessageID),
+		TipID:      domain.TipID(m.TipID),
+		ReporterID: domain.UserID(m.ReporterID),
+		Reason:     domain.ReportReason(m.Reason),
+		Detail:     m.Detail,
+		Status:     domain.ReportStatus(m.Status),
+		CreatedAt:  m.CreatedAt,
+		ResolvedAt: m.ResolvedAt,
+		ResolvedBy: resolvedBy,
+	}
+}
+
+// 通報のドメインモデル構造体をDBモデル構造体に変換する関数
+func ToReportDbModel(r *domain.Report) *ReportModel {
+	var resolvedBy *string
+	if r.ResolvedBy != nil {
+		id := string(*r.ResolvedBy)
+		resolvedBy = &id
+	}
+	return &ReportModel{
+		ID:         string(r.ID),
+		MessageID:  string(r.MessageID),
+		TipID:      string(r.TipID),
+		ReporterID: string(r.ReporterID),
+		Reason:     string(r.Reason),
+		Detail:     r.Detail,
+		Status:     string(r.Status),
+		CreatedAt:  r.CreatedAt,
+		ResolvedAt: r.ResolvedAt,
+		ResolvedBy: resolvedBy,
+	}
+}
+
+// フィルター発動記録のDB構造体をドメインモデル構造体に変換する関数
+func ToFilterLogDomainModel(m *FilterLogModel) *domain.FilterLog {
+	return &domain.FilterLog{
+		MessageID:  domain.MessageID(m.MessageID),
+		TipID:      domain.TipID(m.TipID),
+		UserID:     domain.UserID(m.UserID),
+		FilterName: m.FilterName,
+		Action:     domain.FilterAction(m.Action),
+		Reason:     m.Reason,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+// フィルター発動記録のドメインモデル構造体をDBモデル構造体に変換する関数
+func ToFilterLogDbModel(l *domain.FilterLog) *FilterLogModel {
+	return &FilterLogModel{
+		MessageID:  string(l.MessageID),
+		TipID:      string(l.TipID),
+		UserID:     string(l.UserID),
+		FilterName: l.FilterName,
+		Action:     string(l.Action),
+		Reason:     l.Reason,
+		CreatedAt:  l.CreatedAt,
+	}
+}
+
+// メンションのDB構造体をドメインモデル構造体に変換する関数
+func ToMentionDomainModel(m *MentionModel) *domain.Mention {
+	return &domain.Mention{
+		MessageID:       domain.MessageID(m.MessageID),
+		TipID:           domain.TipID(m.TipID),
+		MentionedUserID: domain.UserID(m.MentionedUserID),
+		MentionedBy:     domain.UserID(m.MentionedBy),
+		CreatedAt:       m.CreatedAt,
+	}
+}
+
+// メンションのドメインモデル構造体をDBモデル構造体に変換する関数
+func ToMentionDbModel(m *domain.Mention) *MentionModel {
+	return &MentionModel{
+		MessageID:       string(m.MessageID),
+		TipID:           string(m.TipID),
+		MentionedUserID: string(m.MentionedUserID),
+		MentionedBy:     string(m.MentionedBy),
+		CreatedAt:       m.CreatedAt,
+	}
+}
+
+// 既読位置のDB構造体をドメインモデル構造体に変換する関数
+func ToReadCursorDomainModel(m *ReadCursorModel) *domain.ReadCursor {
+	return &domain.ReadCursor{
+		TipID:             domain.TipID(m.TipID),
+		UserID:            domain.UserID(m.UserID),
+		LastReadMessageID: domain.MessageID(m.LastReadMessageID),
+		LastReadAt:        m.LastReadAt,
+		UpdatedAt:         m.UpdatedAt,
+	}
+}
+
+// 既読位置のドメインモデル構造体をDBモデル構造体に変換する関数
+func ToReadCursorDbModel(c *domain.ReadCursor) *ReadCursorModel {
+	return &ReadCursorModel{
+		TipID:             string(c.TipID),
+		UserID:            string(c.UserID),
+		LastReadMessageID: string(c.LastReadMessageID),
+		LastReadAt:        c.LastReadAt,
+		UpdatedAt:         c.UpdatedAt,
+	}
+}
+
+// ピン留めのDB構造体をドメインモデル構造体に変換する関数
+func ToPinDomainModel(m *PinModel) *domain.Pin {
+	return &domain.Pin{
+		TipID:     domain.TipID(m.TipID),
+		MessageID: domain.MessageID(m.MessageID),
+		Position:  m.Position,
+		PinnedBy:  domain.UserID(m.PinnedBy),
+		PinnedAt:  m.PinnedAt,
+	}
+}
+
+// ピン留めのドメインモデル構造体をDBモデル構造体に変換する関数
+func ToPinDbModel(p *domain.Pin) *PinModel {
+	return &PinModel{
+		TipID:     string(p.TipID),
+		MessageID: string(p.MessageID),
+		Position:  p.Position,
+		PinnedBy:  string(p.PinnedBy),
+		PinnedAt:  p.PinnedAt,
+	}
+}
+
+// 添付ファイルのDB構造体をドメインモデル構造体に変換する関数
+func ToAttachmentDomainModel(m *AttachmentModel) *domain.Attachment {
+	var messageID *domain.MessageID
+	if m.MessageID != nil {
+		id := domain.MessageID(*m.MessageID)
+		messageID = &id
+	}
+	return &domain.Attachment{
+		ID:          domain.AttachmentID(m.ID),
+		TipID:       domain.TipID(m.TipID),
+		UploaderID:  domain.UserID(m.UploaderID),
+		MessageID:   messageID,
+		FileName:    m.FileName,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		StorageKey:  m.StorageKey,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+// 添付ファイルのドメインモデル構造体をDBモデル構造体に変換する関数
+func ToAttachmentDbModel(a *domain.Attachment) *AttachmentModel {
+	var messageID *string
+	if a.MessageID != nil {
+		id := string(*a.MessageID)
+		messageID = &id
+	}
+	return &AttachmentModel{
+		ID:          string(a.ID),
+		TipID:       string(a.TipID),
+		UploaderID:  string(a.UploaderID),
+		MessageID:   messageID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		StorageKey:  a.StorageKey,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// メッセージのドメインモデル構造体をアウトボックスのpayload（JSON）に変換する関数
+func ToOutboxPayload(msg *domain.Message) ([]byte, error) {
+	p := &OutboxMessagePayload{
+		ID:          string(msg.ID),
+		TipID:       string(msg.TipID),
+		UserID:      string(msg.UserID),
+		Content:     msg.Content,
+		CreatedAt:   msg.CreatedAt,
+		UpdatedAt:   msg.UpdatedAt,
+		DeletedAt:   msg.DeletedAt,
+		HiddenAt:    msg.HiddenAt,
+		Version:     msg.Version,
+		ClientMsgID: msg.ClientMsgID,
+	}
+	for _, id := range msg.AttachmentIDs {
+		p.AttachmentIDs = append(p.AttachmentIDs, string(id))
+	}
+	return json.Marshal(p)
+}
+
+// アウトボックスのDB構造体をドメインモデル構造体に変換する関数
+func ToOutboxEventDomainModel(m *OutboxModel) (*domain.OutboxEvent, error) {
+	var p OutboxMessagePayload
+	if err := json.Unmarshal(m.Payload, &p); err != nil {
+		return nil, err
+	}
+	msg := &domain.Message{
+		ID:          domain.MessageID(p.ID),
+		TipID:       domain.TipID(p.TipID),
+		UserID:      domain.UserID(p.UserID),
+		Content:     p.Content,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		DeletedAt:   p.DeletedAt,
+		HiddenAt:    p.HiddenAt,
+		Version:     p.Version,
+		ClientMsgID: p.ClientMsgID,
+	}
+	for _, id := range p.AttachmentIDs {
+		msg.AttachmentIDs = append(msg.AttachmentIDs, domain.AttachmentID(id))
+	}
+	msg.RefreshMentions()
+	event := &domain.OutboxEvent{
+		ID:          m.ID,
+		Type:        domain.OutboxEventType(m.EventType),
+		Message:     msg,
+		CreatedAt:   m.CreatedAt,
+		PublishedAt: m.PublishedAt,
+	}
+	if m.Seq != nil {
+		event.Seq = *m.Seq
+	}
+	return event, nil
+}
+
+// WebhookのDB構造体をドメインモデル構造体に変換する関数
+func ToWebhookDomainModel(m *WebhookModel) *domain.Webhook {
+	var tipID *domain.TipID
+	if m.TipID != nil {
+		id := domain.TipID(*m.TipID)
+		tipID = &id
+	}
+	return &domain.Webhook{
+		ID:        domain.WebhookID(m.ID),
+		TipID:     tipID,
+		URL:       m.URL,
+		Secret:    m.Secret,
+		CreatedBy: domain.UserID(m.CreatedBy),
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// Webhookのドメインモデル構造体をDBモデル構造体に変換する関数
+func ToWebhookDbModel(w *domain.Webhook) *WebhookModel {
+	var tipID *string
+	if w.TipID != nil {
+		id := string(*w.TipID)
+		tipID = &id
+	}
+	return &WebhookModel{
+		ID:        string(w.ID),
+		TipID:     tipID,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		CreatedBy: string(w.CreatedBy),
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// Webhookの配信のDB構造体をドメインモデル構造体に変換する関数
+func ToWebhookDeliveryDomainModel(m *WebhookDeliveryModel) *domain.WebhookDelivery {
+	return &domain.WebhookDelivery{
+		ID:            domain.WebhookDeliveryID(m.ID),
+		WebhookID:     domain.WebhookID(m.WebhookID),
+		EventID:       m.EventID,
+		EventType:     domain.OutboxEventType(m.EventType),
+		Payload:       m.Payload,
+		Status:        domain.WebhookDeliveryStatus(m.Status),
+		Attempts:      m.Attempts,
+		NextAttemptAt: m.NextAttemptAt,
+		CreatedAt:     m.CreatedAt,
+	}
+}
+
+// Webhookの配信の試行結果のDB構造体をドメインモデル構造体に変換する関数
+func ToWebhookAttemptDomainModel(m *WebhookAttemptModel) *domain.WebhookAttempt {
+	return &domain.WebhookAttempt{
+		DeliveryID:  domain.WebhookDeliveryID(m.DeliveryID),
+		AttemptNo:   m.AttemptNo,
+		StatusCode:  m.StatusCode,
+		Error:       m.Error,
+		AttemptedAt: m.AttemptedAt,
 	}
 }
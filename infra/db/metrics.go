@@ -0,0 +1,76 @@
+package db
+
+// DBまわりのメトリクス（/metricsで公開する）
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 永続化（コミット）まで完了したメッセージの送信・編集・削除の数
+// アウトボックスの再配信に影響されないように、リポジトリのコミット後に数える
+var messageEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tipstar_messages_total",
+	Help: "永続化したメッセージの送信・編集・削除の数",
+}, []string{"event"}) // "message.sent", "message.edited", "message.deleted"
+
+func countMessageEvent(eventType domain.OutboxEventType) {
+	messageEvents.WithLabelValues(string(eventType)).Inc()
+}
+
+// pgxの接続プールの統計をスクレイプのたびに取得するコレクター
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+// 接続プールの統計のコレクターを生成する（prometheus.Registerer.Registerで登録する）
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("tipstar_pgxpool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		pool:                 pool,
+		acquiredConns:        desc("acquired_conns", "使用中の接続数"),
+		idleConns:            desc("idle_conns", "アイドル状態の接続数"),
+		totalConns:           desc("total_conns", "プール内の接続数"),
+		maxConns:             desc("max_conns", "プールの最大接続数"),
+		acquireCount:         desc("acquire_total", "接続の取得に成功した回数"),
+		acquireDuration:      desc("acquire_duration_seconds_total", "接続の取得にかかった時間の合計"),
+		emptyAcquireCount:    desc("empty_acquire_total", "空きがなく接続の取得を待った回数"),
+		canceledAcquireCount: desc("canceled_acquire_total", "接続の取得中にキャンセルされた回数"),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquireCount
+	ch <- c.canceledAcquireCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
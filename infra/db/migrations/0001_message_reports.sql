@@ -0,0 +1,22 @@
+-- ユーザーによるメッセージ通報とモデレーションキュー
+
+-- 通報が一定数集まったメッセージの自動非表示用
+ALTER TABLE messages ADD COLUMN hidden_at TIMESTAMP NULL;
+
+CREATE TABLE message_reports (
+    id          UUID PRIMARY KEY,
+    message_id  UUID NOT NULL REFERENCES messages (id),
+    tip_id      UUID NOT NULL,
+    reporter_id UUID NOT NULL,
+    reason      TEXT NOT NULL,
+    detail      TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL DEFAULT 'pending',
+    created_at  TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP NULL,
+    resolved_by UUID NULL,
+    -- 同じユーザーが同じメッセージを複数回通報できないようにする
+    UNIQUE (message_id, reporter_id)
+);
+
+-- 未対応の通報を古い順に取り出すキューとして使うためのインデックス
+CREATE INDEX idx_message_reports_status_created_at ON message_reports (status, created_at);
@@ -0,0 +1,15 @@
+-- コンテンツフィルターが発動した記録（モデレーター確認用）
+
+CREATE TABLE message_filter_logs (
+    id          BIGSERIAL PRIMARY KEY,
+    -- 拒否されたメッセージはmessagesに保存されないので外部キーにはしない
+    message_id  UUID NOT NULL,
+    tip_id      UUID NOT NULL,
+    user_id     UUID NOT NULL,
+    filter_name TEXT NOT NULL,
+    action      TEXT NOT NULL,
+    reason      TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_message_filter_logs_created_at ON message_filter_logs (created_at DESC);
@@ -0,0 +1,13 @@
+-- メッセージ内の「@ユーザーID」のメンション
+
+CREATE TABLE message_mentions (
+    message_id        UUID NOT NULL REFERENCES messages (id),
+    tip_id            UUID NOT NULL,
+    mentioned_user_id UUID NOT NULL,
+    mentioned_by      UUID NOT NULL,
+    created_at        TIMESTAMP NOT NULL,
+    PRIMARY KEY (message_id, mentioned_user_id)
+);
+
+-- 「自分がメンションされたメッセージ」を新しい順に取得するためのインデックス
+CREATE INDEX idx_message_mentions_user_created_at ON message_mentions (mentioned_user_id, created_at DESC);
@@ -0,0 +1,16 @@
+-- ユーザーごと・Tipごとの既読位置
+
+CREATE TABLE read_cursors (
+    tip_id               UUID NOT NULL,
+    user_id              UUID NOT NULL,
+    last_read_message_id UUID NOT NULL REFERENCES messages (id),
+    last_read_at         TIMESTAMP NOT NULL,
+    updated_at           TIMESTAMP NOT NULL,
+    PRIMARY KEY (tip_id, user_id)
+);
+
+-- 「自分の未読数」をTip横断で取得するためのインデックス
+CREATE INDEX idx_read_cursors_user_id ON read_cursors (user_id);
+
+-- 既読位置より後のメッセージ数を数えるためのインデックス
+CREATE INDEX idx_messages_tip_id_created_at ON messages (tip_id, created_at);
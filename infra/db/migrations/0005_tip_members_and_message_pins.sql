@@ -0,0 +1,22 @@
+-- Tipの参加者とその役割、Tip内のピン留め
+
+-- Tip自体はメインのAPI側で管理しているので、チャットAPIではTipとユーザーの関係だけを持つ
+CREATE TABLE tip_members (
+    tip_id    UUID NOT NULL,
+    user_id   UUID NOT NULL,
+    role      TEXT NOT NULL, -- 'owner', 'moderator', 'member'
+    joined_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (tip_id, user_id)
+);
+
+CREATE TABLE message_pins (
+    tip_id     UUID NOT NULL,
+    message_id UUID NOT NULL REFERENCES messages (id),
+    position   INTEGER NOT NULL,
+    pinned_by  UUID NOT NULL,
+    pinned_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (tip_id, message_id)
+);
+
+-- メッセージの論理削除時にピン留めを解除するためのインデックス
+CREATE INDEX idx_message_pins_message_id ON message_pins (message_id);
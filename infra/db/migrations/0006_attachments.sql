@@ -0,0 +1,16 @@
+-- メッセージの添付ファイルのメタデータ（実体はBlobStoreに保存する）
+
+CREATE TABLE attachments (
+    id           UUID PRIMARY KEY,
+    tip_id       UUID NOT NULL,
+    uploader_id  UUID NOT NULL,
+    -- アップロード時点では未添付。メッセージ送信時に紐づける
+    message_id   UUID NULL REFERENCES messages (id),
+    file_name    TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         BIGINT NOT NULL,
+    storage_key  TEXT NOT NULL,
+    created_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_attachments_message_id ON attachments (message_id);
@@ -0,0 +1,8 @@
+-- クライアントが生成した送信の識別子（再接続後の再送で同じメッセージが重複しないようにする）
+
+ALTER TABLE messages ADD COLUMN client_msg_id TEXT NULL;
+
+-- 同じユーザーが同じTipに同じclient_msg_idで送信できるのは1回だけ
+CREATE UNIQUE INDEX uq_messages_user_tip_client_msg_id
+    ON messages (user_id, tip_id, client_msg_id)
+    WHERE client_msg_id IS NOT NULL;
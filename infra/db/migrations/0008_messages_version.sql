@@ -0,0 +1,3 @@
+-- 編集・削除の楽観的排他制御用のバージョン（編集・削除のたびに1ずつ増やす）
+
+ALTER TABLE messages ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,18 @@
+-- メッセージのイベントのアウトボックス
+-- メッセージの保存・編集・論理削除と同じトランザクションで書き込み、リレーがWebSocketや外部のシンクに配信する
+
+CREATE TABLE outbox (
+    id           BIGSERIAL PRIMARY KEY,
+    event_type   TEXT NOT NULL,
+    tip_id       UUID NOT NULL,
+    message_id   UUID NOT NULL,
+    payload      JSONB NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    published_at TIMESTAMP NULL
+);
+
+-- 未配信のイベントを書き込み順に取り出すためのインデックス
+CREATE INDEX idx_outbox_pending ON outbox (id) WHERE published_at IS NULL;
+
+-- 配信済みの古いイベントを掃除するためのインデックス
+CREATE INDEX idx_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,43 @@
+-- 運営者が登録したWebhookと、その配信・試行の履歴
+
+CREATE TABLE webhooks (
+    id         UUID PRIMARY KEY,
+    tip_id     UUID NULL, -- NULLなら全Tipのイベントを受け取る
+    url        TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webhooks_tip_id ON webhooks (tip_id);
+
+-- 1つのイベントを1つのWebhookに届ける配信
+-- アウトボックスのイベントは配信後に掃除されるので、event_idは外部キーにしない
+CREATE TABLE webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      UUID NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        BIGINT NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    status          TEXT NOT NULL, -- 'pending', 'succeeded', 'dead'
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    UNIQUE (webhook_id, event_id) -- アウトボックスの再配信で同じ配信を二重に登録しない
+);
+
+-- 試行日時を過ぎた配信待ちの配信を取り出すためのインデックス
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- Webhookごとの配信一覧を新しい順に取得するためのインデックス
+CREATE INDEX idx_webhook_deliveries_webhook_id_created_at ON webhook_deliveries (webhook_id, created_at DESC);
+
+-- 配信の試行結果
+CREATE TABLE webhook_delivery_attempts (
+    delivery_id  BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempt_no   INTEGER NOT NULL,
+    status_code  INTEGER NOT NULL, -- 接続できなかった場合は0
+    error        TEXT NOT NULL,
+    attempted_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (delivery_id, attempt_no)
+);
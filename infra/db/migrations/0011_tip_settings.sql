@@ -0,0 +1,9 @@
+-- Tipの公開範囲の設定
+
+-- Tip自体はメインのAPI側で管理しているので、チャットAPIでは公開範囲だけを持つ
+-- 行が無いTipは公開（public）として扱う
+CREATE TABLE tip_settings (
+    tip_id     UUID PRIMARY KEY,
+    visibility TEXT NOT NULL DEFAULT 'public', -- 'public', 'private'
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,3 @@
+-- SSEの再接続時に、Tipのイベントを前回受け取ったID以降から取り出すためのインデックス
+
+CREATE INDEX idx_outbox_tip_id_id ON outbox (tip_id, id);
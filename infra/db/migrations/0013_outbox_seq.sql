@@ -0,0 +1,24 @@
+-- アウトボックスのイベントにコミット順の連番（seq）を振る
+-- idはINSERTの時点で採番されるので、コミット順とは一致しない（後からコミットされたイベントの方がidが小さい場合がある）
+-- リレーがインスタンス間で直列化して、コミット済みでまだ連番のないイベントに連番を振る
+
+CREATE SEQUENCE outbox_seq;
+
+ALTER TABLE outbox
+    ADD COLUMN seq BIGINT NULL,
+    -- Webhook等の永続的なシンクへの配信を確保しているインスタンスの、確保の期限
+    ADD COLUMN claimed_until TIMESTAMP NULL;
+
+-- 既存のイベントはidの順に連番を振る
+UPDATE outbox SET seq = id;
+SELECT setval('outbox_seq', COALESCE((SELECT MAX(seq) FROM outbox), 0) + 1, false);
+
+-- 各インスタンスのHubへの配信で、連番の順に取り出すためのインデックス
+CREATE UNIQUE INDEX idx_outbox_seq ON outbox (seq);
+
+-- まだ連番のないイベントを書き込み順に取り出すためのインデックス
+CREATE INDEX idx_outbox_unsequenced ON outbox (id) WHERE seq IS NULL;
+
+-- 永続的なシンクに未配信のイベントを連番の順に取り出すためのインデックス
+DROP INDEX idx_outbox_pending;
+CREATE INDEX idx_outbox_pending ON outbox (seq) WHERE published_at IS NULL;
@@ -0,0 +1,5 @@
+-- SSEの再接続時とロングポーリングで、Tipのイベントを前回受け取った連番（seq）以降から取り出すためのインデックス
+-- idはコミット順ではないので、idでの取り出し用のインデックスは使わなくなる
+
+DROP INDEX idx_outbox_tip_id_id;
+CREATE INDEX idx_outbox_tip_id_seq ON outbox (tip_id, seq);
@@ -4,11 +4,141 @@ import "time"
 
 // DBモデル構造体定義
 type MessageModel struct {
-	ID        string     // messages.id（UUID）←PK
-	TipID     string     // messages.tip_id（UUID）←NOT NULL制約
-	UserID    string     // messages.user_id（UUID）←NOT NULL制約
-	Content   string     // messages.content（TEXT）←NOT NULL制約
-	CreatedAt time.Time  // messages.created_at（TIMESTAMP） ←NOT NULL制約
-	UpdatedAt time.Time  // messages.updated_at（TIMESTAMP） ←NOT NULL制約（初期値はcreated_atと同じにする）
-	DeletedAt *time.Time // messages.deleted_at（TIMESTAMP） ←NULL許容（論理削除したいから）
+	ID            string     // messages.id（UUID）←PK
+	TipID         string     // messages.tip_id（UUID）←NOT NULL制約
+	UserID        string     // messages.user_id（UUID）←NOT NULL制約
+	Content       string     // messages.content（TEXT）←NOT NULL制約
+	CreatedAt     time.Time  // messages.created_at（TIMESTAMP） ←NOT NULL制約
+	UpdatedAt     time.Time  // messages.updated_at（TIMESTAMP） ←NOT NULL制約（初期値はcreated_atと同じにする）
+	DeletedAt     *time.Time // messages.deleted_at（TIMESTAMP） ←NULL許容（論理削除したいから）
+	HiddenAt      *time.Time // messages.hidden_at（TIMESTAMP） ←NULL許容（通報による自動非表示）
+	ClientMsgID   *string    // messages.client_msg_id（TEXT） ←NULL許容、(user_id, tip_id, client_msg_id)でUNIQUE制約
+	Version       int64      // messages.version（BIGINT） ←NOT NULL制約（初期値は1）
+	AttachmentIDs []string   // attachments.message_idでこのメッセージに紐づく添付ファイルのID（messagesのカラムではない）
+}
+
+// 通報のDBモデル構造体定義
+type ReportModel struct {
+	ID         string     // message_reports.id（UUID）←PK
+	MessageID  string     // message_reports.message_id（UUID）←NOT NULL制約、(message_id, reporter_id)でUNIQUE制約
+	TipID      string     // message_reports.tip_id（UUID）←NOT NULL制約
+	ReporterID string     // message_reports.reporter_id（UUID）←NOT NULL制約
+	Reason     string     // message_reports.reason（TEXT）←NOT NULL制約
+	Detail     string     // message_reports.detail（TEXT）←NOT NULL制約（空文字許容）
+	Status     string     // message_reports.status（TEXT）←NOT NULL制約
+	CreatedAt  time.Time  // message_reports.created_at（TIMESTAMP）←NOT NULL制約
+	ResolvedAt *time.Time // message_reports.resolved_at（TIMESTAMP）←NULL許容
+	ResolvedBy *string    // message_reports.resolved_by（UUID）←NULL許容
+}
+
+// フィルター発動記録のDBモデル構造体定義
+type FilterLogModel struct {
+	ID         int64     // message_filter_logs.id（BIGSERIAL）←PK
+	MessageID  string    // message_filter_logs.message_id（UUID）←NOT NULL制約（拒否されたメッセージはmessagesに存在しないので外部キーにはしない）
+	TipID      string    // message_filter_logs.tip_id（UUID）←NOT NULL制約
+	UserID     string    // message_filter_logs.user_id（UUID）←NOT NULL制約
+	FilterName string    // message_filter_logs.filter_name（TEXT）←NOT NULL制約
+	Action     string    // message_filter_logs.action（TEXT）←NOT NULL制約
+	Reason     string    // message_filter_logs.reason（TEXT）←NOT NULL制約
+	CreatedAt  time.Time // message_filter_logs.created_at（TIMESTAMP）←NOT NULL制約
+}
+
+// メンションのDBモデル構造体定義
+type MentionModel struct {
+	MessageID       string    // message_mentions.message_id（UUID）←NOT NULL制約、(message_id, mentioned_user_id)でPK
+	TipID           string    // message_mentions.tip_id（UUID）←NOT NULL制約
+	MentionedUserID string    // message_mentions.mentioned_user_id（UUID）←NOT NULL制約
+	MentionedBy     string    // message_mentions.mentioned_by（UUID）←NOT NULL制約
+	CreatedAt       time.Time // message_mentions.created_at（TIMESTAMP）←NOT NULL制約（メッセージの送信日時）
+}
+
+// 既読位置のDBモデル構造体定義
+type ReadCursorModel struct {
+	TipID             string    // read_cursors.tip_id（UUID）←(tip_id, user_id)でPK
+	UserID            string    // read_cursors.user_id（UUID）
+	LastReadMessageID string    // read_cursors.last_read_message_id（UUID）←NOT NULL制約
+	LastReadAt        time.Time // read_cursors.last_read_at（TIMESTAMP）←NOT NULL制約
+	UpdatedAt         time.Time // read_cursors.updated_at（TIMESTAMP）←NOT NULL制約
+}
+
+// ピン留めのDBモデル構造体定義
+type PinModel struct {
+	TipID     string    // message_pins.tip_id（UUID）←(tip_id, message_id)でPK
+	MessageID string    // message_pins.message_id（UUID）
+	Position  int       // message_pins.position（INTEGER）←NOT NULL制約
+	PinnedBy  string    // message_pins.pinned_by（UUID）←NOT NULL制約
+	PinnedAt  time.Time // message_pins.pinned_at（TIMESTAMP）←NOT NULL制約
+}
+
+// 添付ファイルのDBモデル構造体定義
+type AttachmentModel struct {
+	ID          string    // attachments.id（UUID）←PK
+	TipID       string    // attachments.tip_id（UUID）←NOT NULL制約
+	UploaderID  string    // attachments.uploader_id（UUID）←NOT NULL制約
+	MessageID   *string   // attachments.message_id（UUID）←NULL許容（メッセージ送信までは未添付）
+	FileName    string    // attachments.file_name（TEXT）←NOT NULL制約
+	ContentType string    // attachments.content_type（TEXT）←NOT NULL制約
+	Size        int64     // attachments.size（BIGINT）←NOT NULL制約
+	StorageKey  string    // attachments.storage_key（TEXT）←NOT NULL制約
+	CreatedAt   time.Time // attachments.created_at（TIMESTAMP）←NOT NULL制約
+}
+
+// アウトボックスのイベントのDBモデル構造体定義
+type OutboxModel struct {
+	ID          int64      // outbox.id（BIGSERIAL）←PK（書き込み時に採番）
+	Seq         *int64     // outbox.seq（BIGINT）←NULL許容（リレーがコミット順に振るまではNULL）
+	EventType   string     // outbox.event_type（TEXT）←NOT NULL制約
+	TipID       string     // outbox.tip_id（UUID）←NOT NULL制約
+	MessageID   string     // outbox.message_id（UUID）←NOT NULL制約
+	Payload     []byte     // outbox.payload（JSONB）←NOT NULL制約（OutboxMessagePayloadをJSONにしたもの）
+	CreatedAt   time.Time  // outbox.created_at（TIMESTAMP）←NOT NULL制約
+	PublishedAt *time.Time // outbox.published_at（TIMESTAMP）←NULL許容（未配信ならNULL）
+}
+
+// アウトボックスのpayloadに保存する、イベント発生時点のメッセージ
+// メンションはContentから導出できるので保存しない
+type OutboxMessagePayload struct {
+	ID            string     `json:"id"`
+	TipID         string     `json:"tip_id"`
+	UserID        string     `json:"user_id"`
+	Content       string     `json:"content"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	HiddenAt      *time.Time `json:"hidden_at,omitempty"`
+	Version       int64      `json:"version"`
+	ClientMsgID   string     `json:"client_msg_id,omitempty"`
+	AttachmentIDs []string   `json:"attachment_ids,omitempty"`
+}
+
+// WebhookのDBモデル構造体定義
+type WebhookModel struct {
+	ID        string    // webhooks.id（UUID）←PK
+	TipID     *string   // webhooks.tip_id（UUID）←NULL許容（NULLなら全Tip）
+	URL       string    // webhooks.url（TEXT）←NOT NULL制約
+	Secret    string    // webhooks.secret（TEXT）←NOT NULL制約
+	CreatedBy string    // webhooks.created_by（UUID）←NOT NULL制約
+	CreatedAt time.Time // webhooks.created_at（TIMESTAMP）←NOT NULL制約
+}
+
+// Webhookの配信のDBモデル構造体定義
+type WebhookDeliveryModel struct {
+	ID            int64     // webhook_deliveries.id（BIGSERIAL）←PK
+	WebhookID     string    // webhook_deliveries.webhook_id（UUID）←NOT NULL制約、(webhook_id, event_id)でUNIQUE制約
+	EventID       int64     // webhook_deliveries.event_id（BIGINT）←NOT NULL制約
+	EventType     string    // webhook_deliveries.event_type（TEXT）←NOT NULL制約
+	Payload       []byte    // webhook_deliveries.payload（JSONB）←NOT NULL制約
+	Status        string    // webhook_deliveries.status（TEXT）←NOT NULL制約
+	Attempts      int       // webhook_deliveries.attempts（INTEGER）←NOT NULL制約
+	NextAttemptAt time.Time // webhook_deliveries.next_attempt_at（TIMESTAMP）←NOT NULL制約
+	CreatedAt     time.Time // webhook_deliveries.created_at（TIMESTAMP）←NOT NULL制約
+}
+
+// Webhookの配信の試行結果のDBモデル構造体定義
+type WebhookAttemptModel struct {
+	DeliveryID  int64     // webhook_delivery_attempts.delivery_id（BIGINT）←(delivery_id, attempt_no)でPK
+	AttemptNo   int       // webhook_delivery_attempts.attempt_no（INTEGER）
+	StatusCode  int       // webhook_delivery_attempts.status_code（INTEGER）←NOT NULL制約
+	Error       string    // webhook_delivery_attempts.error（TEXT）←NOT NULL制約
+	AttemptedAt time.Time // webhook_delivery_attempts.attempted_at（TIMESTAMP）←NOT NULL制約
 }
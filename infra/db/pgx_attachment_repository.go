@@ -0,0 +1,80 @@
+package db
+
+// ドメイン層で定義した添付ファイルのメタデータの永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type PgxAttachmentRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxAttachmentRepository(db *pgxpool.Pool) domain.AttachmentRepository {
+	return &PgxAttachmentRepository{DB: db}
+}
+
+// アップロードされた添付ファイルのメタデータの挿入（この時点ではどのメッセージにも紐づかない）
+func (r *PgxAttachmentRepository) SaveAttachment(ctx context.Context, attachment *domain.Attachment) error {
+	const query = `
+	INSERT INTO attachments (id, tip_id, uploader_id, file_name, content_type, size, storage_key, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	m := ToAttachmentDbModel(attachment)
+	_, err := r.DB.Exec(ctx, query,
+		m.ID,
+		m.TipID,
+		m.UploaderID,
+		m.FileName,
+		m.ContentType,
+		m.Size,
+		m.StorageKey,
+		m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "添付ファイルのアップロード（永続化）", "attachment_id", string(attachment.ID), logging.KeyTipID, string(attachment.TipID), logging.KeyUserID, string(attachment.UploaderID))
+	return nil
+}
+
+// 添付ファイルをIDで取得。添付先のメッセージが論理削除・非表示になっていればHiddenをtrueにする
+func (r *PgxAttachmentRepository) FetchAttachmentByID(ctx context.Context, id domain.AttachmentID) (*domain.Attachment, error) {
+	const query = `
+	SELECT
+		a.id, a.tip_id, a.uploader_id, a.message_id, a.file_name, a.content_type, a.size, a.storage_key, a.created_at,
+		COALESCE(m.deleted_at IS NOT NULL OR m.hidden_at IS NOT NULL, false)
+	FROM attachments a
+	LEFT JOIN messages m ON m.id = a.message_id
+	WHERE a.id = $1
+	`
+	var m AttachmentModel
+	var hidden bool
+	err := r.DB.QueryRow(ctx, query, string(id)).Scan(
+		&m.ID,
+		&m.TipID,
+		&m.UploaderID,
+		&m.MessageID,
+		&m.FileName,
+		&m.ContentType,
+		&m.Size,
+		&m.StorageKey,
+		&m.CreatedAt,
+		&hidden,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	attachment := ToAttachmentDomainModel(&m)
+	attachment.Hidden = hidden
+	return attachment, nil
+}
@@ -0,0 +1,66 @@
+package db
+
+// ドメイン層で定義したフィルター発動記録の永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type PgxFilterLogRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxFilterLogRepository(db *pgxpool.Pool) domain.FilterLogRepository {
+	return &PgxFilterLogRepository{DB: db}
+}
+
+// フィルター発動記録をまとめて挿入。1メッセージで複数のフィルターが発動しうるのでバッチで送る
+func (r *PgxFilterLogRepository) SaveFilterLogs(ctx context.Context, logs []*domain.FilterLog) error {
+	const query = `
+	INSERT INTO message_filter_logs (message_id, tip_id, user_id, filter_name, action, reason, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	batch := &pgx.Batch{}
+	for _, l := range logs {
+		m := ToFilterLogDbModel(l)
+		batch.Queue(query, m.MessageID, m.TipID, m.UserID, m.FilterName, m.Action, m.Reason, m.CreatedAt)
+	}
+	if err := r.DB.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "フィルター発動記録（永続化）", "count", len(logs))
+	return nil
+}
+
+// 新しい順に最大limit件の発動記録を取得
+func (r *PgxFilterLogRepository) FetchRecentFilterLogs(ctx context.Context, limit int) ([]*domain.FilterLog, error) {
+	const query = `
+	SELECT id, message_id, tip_id, user_id, filter_name, action, reason, created_at
+	FROM message_filter_logs
+	ORDER BY created_at DESC
+	LIMIT $1
+	`
+	rows, err := r.DB.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*domain.FilterLog
+	for rows.Next() {
+		var m FilterLogModel
+		if err := rows.Scan(&m.ID, &m.MessageID, &m.TipID, &m.UserID, &m.FilterName, &m.Action, &m.Reason, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, ToFilterLogDomainModel(&m))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
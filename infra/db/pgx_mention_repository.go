@@ -0,0 +1,60 @@
+package db
+
+// ドメイン層で定義したメンションの取得処理のインターフェースをここで実装
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type PgxMentionRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxMentionRepository(db *pgxpool.Pool) domain.MentionRepository {
+	return &PgxMentionRepository{DB: db}
+}
+
+// ユーザーがメンションされたメッセージを全Tip横断で新しい順に取得。削除済みのメッセージは除く
+func (r *PgxMentionRepository) FetchMentionsByUserID(ctx context.Context, userID domain.UserID, limit int) ([]*domain.Mention, error) {
+	const query = `
+	SELECT
+		mm.message_id, mm.tip_id, mm.mentioned_user_id, mm.mentioned_by, mm.created_at,
+		m.id, m.tip_id, m.user_id, m.content, m.created_at, m.updated_at, m.deleted_at, m.hidden_at
+	FROM message_mentions mm
+	JOIN messages m ON m.id = mm.message_id
+	WHERE mm.mentioned_user_id = $1 AND m.deleted_at IS NULL
+	ORDER BY mm.created_at DESC
+	LIMIT $2
+	`
+	rows, err := r.DB.Query(ctx, query, string(userID), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []*domain.Mention
+	for rows.Next() {
+		var mm MentionModel
+		var m MessageModel
+		if err := rows.Scan(
+			&mm.MessageID, &mm.TipID, &mm.MentionedUserID, &mm.MentionedBy, &mm.CreatedAt,
+			&m.ID, &m.TipID, &m.UserID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt, &m.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		mention := ToMentionDomainModel(&mm)
+		mention.Message = ToDomainModel(&m, false)
+		mentions = append(mentions, mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "メンション一覧取得", logging.KeyUserID, string(userID))
+	return mentions, nil
+}
@@ -0,0 +1,202 @@
+package db
+
+// ドメイン層で定義したアウトボックスの永続化処理のインターフェースをここで実装
+// イベントの書き込みはメッセージの永続化と同じトランザクションで行うので、insertOutboxEventをPgxMessageRepositoryから呼ぶ
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type PgxOutboxRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxOutboxRepository(db *pgxpool.Pool) domain.OutboxRepository {
+	return &PgxOutboxRepository{DB: db}
+}
+
+// メッセージのイベントをアウトボックスに書き込む（メッセージの保存・編集・論理削除のトランザクション内で呼ぶ）
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, eventType domain.OutboxEventType, msg *domain.Message) error {
+	const query = `
+	INSERT INTO outbox (event_type, tip_id, message_id, payload, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+	payload, err := ToOutboxPayload(msg)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, query, string(eventType), string(msg.TipID), string(msg.ID), payload, time.Now())
+	return err
+}
+
+// アウトボックスのイベントを取得するクエリで選択する列
+const outboxColumns = `id, seq, event_type, tip_id, message_id, payload, created_at, published_at`
+
+// コミット済みでまだ連番のないイベントに、書き込み順に連番を振る
+// 連番を振るトランザクションをアドバイザリロックで直列化するので、先に振られた連番のイベントが後からコミットされることはない
+func (r *PgxOutboxRepository) SequenceEvents(ctx context.Context, limit int) (int, error) {
+	const lockQuery = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	const query = `
+	UPDATE outbox o
+	SET seq = s.seq
+	FROM (
+		SELECT id, nextval('outbox_seq') AS seq
+		FROM (
+			SELECT id
+			FROM outbox
+			WHERE seq IS NULL
+			ORDER BY id ASC
+			LIMIT $1
+		) pending
+	) s
+	WHERE o.id = s.id
+	`
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockQuery, "outbox:seq"); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// 振られている連番の最大値を取得
+func (r *PgxOutboxRepository) LatestSeq(ctx context.Context) (int64, error) {
+	const query = `SELECT COALESCE(MAX(seq), 0) FROM outbox`
+	var seq int64
+	if err := r.DB.QueryRow(ctx, query).Scan(&seq); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// 連番がafterSeqより大きいイベントを連番の順に取得
+func (r *PgxOutboxRepository) FetchSequencedAfter(ctx context.Context, afterSeq int64, limit int) ([]*domain.OutboxEvent, error) {
+	const query = `
+	SELECT ` + outboxColumns + `
+	FROM outbox
+	WHERE seq > $1
+	ORDER BY seq ASC
+	LIMIT $2
+	`
+	rows, err := r.DB.Query(ctx, query, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxEvents(rows)
+}
+
+// 永続的なシンクに未配信で、誰も確保していない（確保の期限が切れた）イベントを確保する
+// 複数のインスタンスが同時に確保しても同じイベントを取り合わないよう、ロック中の行は飛ばす
+func (r *PgxOutboxRepository) ClaimPendingEvents(ctx context.Context, lease time.Duration, limit int) ([]*domain.OutboxEvent, error) {
+	const query = `
+	UPDATE outbox
+	SET claimed_until = $1
+	WHERE id IN (
+		SELECT id
+		FROM outbox
+		WHERE published_at IS NULL
+			AND seq IS NOT NULL
+			AND (claimed_until IS NULL OR claimed_until < $2)
+		ORDER BY seq ASC
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + outboxColumns
+	now := time.Now()
+	rows, err := r.DB.Query(ctx, query, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	events, err := scanOutboxEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNINGの順序は保証されないので、連番の順に並べ直す
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+// Tipのイベントのうち、連番がafterSeqより大きいものを連番の順に取得
+// 未配信のイベントも含める（受け取る側で連番によって重複を取り除く）。まだ連番のないイベントはHubから届くので含めない
+func (r *PgxOutboxRepository) FetchEventsAfter(ctx context.Context, tipID domain.TipID, afterSeq int64, limit int) ([]*domain.OutboxEvent, error) {
+	const query = `
+	SELECT ` + outboxColumns + `
+	FROM outbox
+	WHERE tip_id = $1 AND seq > $2
+	ORDER BY seq ASC
+	LIMIT $3
+	`
+	rows, err := r.DB.Query(ctx, query, string(tipID), afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxEvents(rows)
+}
+
+// outboxColumnsを選択したクエリの結果をドメインモデルにする
+func scanOutboxEvents(rows pgx.Rows) ([]*domain.OutboxEvent, error) {
+	defer rows.Close()
+
+	var events []*domain.OutboxEvent
+	for rows.Next() {
+		var m OutboxModel
+		if err := rows.Scan(&m.ID, &m.Seq, &m.EventType, &m.TipID, &m.MessageID, &m.Payload, &m.CreatedAt, &m.PublishedAt); err != nil {
+			return nil, err
+		}
+		event, err := ToOutboxEventDomainModel(&m)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// イベントを配信済みにする
+// 確保の期限が切れて別のインスタンスが先に配信済みにした場合もあるので、配信済みでもエラーにしない
+func (r *PgxOutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	const query = `
+	UPDATE outbox
+	SET published_at = $1, claimed_until = NULL
+	WHERE id = $2 AND published_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, time.Now(), id)
+	return err
+}
+
+// before以前に配信済みになったイベントを削除
+func (r *PgxOutboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	const query = `
+	DELETE FROM outbox
+	WHERE published_at IS NOT NULL AND published_at < $1
+	`
+	tag, err := r.DB.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		slog.InfoContext(ctx, "配信済みのアウトボックスのイベントを削除", "count", n)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,147 @@
+package db
+
+// ドメイン層で定義したピン留めの永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type PgxPinRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxPinRepository(db *pgxpool.Pool) domain.PinRepository {
+	return &PgxPinRepository{DB: db}
+}
+
+// Tip内のピン留めを、ピン留めされたメッセージと一緒にPositionの昇順で取得
+func (r *PgxPinRepository) FetchPinsByTipID(ctx context.Context, tipID domain.TipID) ([]*domain.Pin, error) {
+	const query = `
+	SELECT
+		p.tip_id, p.message_id, p.position, p.pinned_by, p.pinned_at,
+		m.id, m.tip_id, m.user_id, m.content, m.created_at, m.updated_at, m.deleted_at, m.hidden_at
+	FROM message_pins p
+	JOIN messages m ON m.id = p.message_id
+	WHERE p.tip_id = $1
+	ORDER BY p.position ASC
+	`
+	rows, err := r.DB.Query(ctx, query, string(tipID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []*domain.Pin
+	for rows.Next() {
+		var pm PinModel
+		var m MessageModel
+		if err := rows.Scan(
+			&pm.TipID, &pm.MessageID, &pm.Position, &pm.PinnedBy, &pm.PinnedAt,
+			&m.ID, &m.TipID, &m.UserID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt, &m.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		pin := ToPinDomainModel(&pm)
+		pin.Message = ToDomainModel(&m, false)
+		pins = append(pins, pin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "ピン留め一覧取得", logging.KeyTipID, string(tipID))
+	return pins, nil
+}
+
+// ピン留めの挿入。上限数の確認とPositionの採番を同じTipに対して直列化するため、Tip単位のアドバイザリロックを取る
+// メッセージの論理削除と競合しないよう、削除されていないことの確認と挿入を1つの文で行い、メッセージの行を共有ロックする
+// （論理削除のトランザクションはメッセージの行を更新してからピン留めを削除するので、先に挿入されたピン留めも削除される）
+func (r *PgxPinRepository) SavePin(ctx context.Context, pin *domain.Pin, maxPins int) error {
+	const lockQuery = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	const countQuery = `
+	SELECT COUNT(*), COALESCE(MAX(position), 0)
+	FROM message_pins
+	WHERE tip_id = $1
+	`
+	const insertQuery = `
+	INSERT INTO message_pins (tip_id, message_id, position, pinned_by, pinned_at)
+	SELECT $1, id, $3, $4, $5
+	FROM messages
+	WHERE id = $2 AND deleted_at IS NULL
+	FOR SHARE
+	`
+	m := ToPinDbModel(pin)
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockQuery, "message_pins:"+m.TipID); err != nil {
+		return err
+	}
+	var count, maxPosition int
+	if err := tx.QueryRow(ctx, countQuery, m.TipID).Scan(&count, &maxPosition); err != nil {
+		return err
+	}
+	if count >= maxPins {
+		return domain.ErrPinLimitReached
+	}
+	m.Position = maxPosition + 1
+	tag, err := tx.Exec(ctx, insertQuery, m.TipID, m.MessageID, m.Position, m.PinnedBy, m.PinnedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return domain.ErrAlreadyPinned
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrPinDeleted
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	pin.Position = m.Position
+	slog.InfoContext(ctx, "メッセージのピン留め（永続化）", logging.KeyTipID, string(pin.TipID), logging.KeyMessageID, string(pin.MessageID))
+	return nil
+}
+
+// ピン留めの削除
+func (r *PgxPinRepository) DeletePin(ctx context.Context, tipID domain.TipID, messageID domain.MessageID) error {
+	const query = `
+	DELETE FROM message_pins
+	WHERE tip_id = $1 AND message_id = $2
+	`
+	tag, err := r.DB.Exec(ctx, query, string(tipID), string(messageID))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotPinned
+	}
+	slog.InfoContext(ctx, "メッセージのピン留め解除（永続化）", logging.KeyTipID, string(tipID), logging.KeyMessageID, string(messageID))
+	return nil
+}
+
+// メッセージの論理削除のトランザクション内で、そのメッセージのピン留めを解除する。解除したかどうかを返す
+func deletePinsByMessageID(ctx context.Context, tx pgx.Tx, messageID string) (bool, error) {
+	const query = `
+	DELETE FROM message_pins
+	WHERE message_id = $1
+	`
+	tag, err := tx.Exec(ctx, query, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
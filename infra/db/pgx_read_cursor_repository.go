@@ -0,0 +1,105 @@
+package db
+
+// ドメイン層で定義した既読位置の永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type PgxReadCursorRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxReadCursorRepository(db *pgxpool.Pool) domain.ReadCursorRepository {
+	return &PgxReadCursorRepository{DB: db}
+}
+
+// Tipとユーザーに対応する既読位置を取得。まだなければnilを返す
+func (r *PgxReadCursorRepository) FetchReadCursor(ctx context.Context, tipID domain.TipID, userID domain.UserID) (*domain.ReadCursor, error) {
+	const query = `
+	SELECT tip_id, user_id, last_read_message_id, last_read_at, updated_at
+	FROM read_cursors
+	WHERE tip_id = $1 AND user_id = $2
+	`
+	var m ReadCursorModel
+	err := r.DB.QueryRow(ctx, query, string(tipID), string(userID)).Scan(
+		&m.TipID,
+		&m.UserID,
+		&m.LastReadMessageID,
+		&m.LastReadAt,
+		&m.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToReadCursorDomainModel(&m), nil
+}
+
+// 既読位置のUPSERT。複数端末から同時に既読にされた場合でも後戻りしないよう、保存済みの位置より新しい場合だけ更新する。更新しなかった場合はfalseを返す
+func (r *PgxReadCursorRepository) SaveReadCursor(ctx context.Context, cursor *domain.ReadCursor) (bool, error) {
+	const query = `
+	INSERT INTO read_cursors (tip_id, user_id, last_read_message_id, last_read_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (tip_id, user_id) DO UPDATE
+	SET last_read_message_id = EXCLUDED.last_read_message_id,
+		last_read_at = EXCLUDED.last_read_at,
+		updated_at = EXCLUDED.updated_at
+	WHERE read_cursors.last_read_at < EXCLUDED.last_read_at
+	`
+	m := ToReadCursorDbModel(cursor)
+	tag, err := r.DB.Exec(ctx, query, m.TipID, m.UserID, m.LastReadMessageID, m.LastReadAt, m.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	// 取得から保存までの間に、別の接続がより新しい位置まで進めていた場合
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	slog.DebugContext(ctx, "既読位置の更新（永続化）", logging.KeyTipID, string(cursor.TipID), logging.KeyUserID, string(cursor.UserID), logging.KeyMessageID, string(cursor.LastReadMessageID))
+	return true, nil
+}
+
+// 既読位置を持っているTipごとに、既読位置より後の他人のメッセージ数（削除済み・非表示は除く）を取得
+func (r *PgxReadCursorRepository) FetchUnreadCounts(ctx context.Context, userID domain.UserID) ([]*domain.UnreadCount, error) {
+	const query = `
+	SELECT rc.tip_id, COUNT(m.id)
+	FROM read_cursors rc
+	LEFT JOIN messages m
+		ON m.tip_id = rc.tip_id
+		AND m.created_at > rc.last_read_at
+		AND m.user_id <> rc.user_id
+		AND m.deleted_at IS NULL
+		AND m.hidden_at IS NULL
+	WHERE rc.user_id = $1
+	GROUP BY rc.tip_id
+	`
+	rows, err := r.DB.Query(ctx, query, string(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*domain.UnreadCount
+	for rows.Next() {
+		var tipID string
+		var count int
+		if err := rows.Scan(&tipID, &count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &domain.UnreadCount{TipID: domain.TipID(tipID), Count: count})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
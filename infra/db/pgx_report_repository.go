@@ -0,0 +1,192 @@
+package db
+
+// ドメイン層で定義した通報（モデレーションキュー）の永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+// PostgreSQLの一意制約違反のエラーコード
+const uniqueViolationCode = "23505"
+
+type PgxReportRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxReportRepository(db *pgxpool.Pool) domain.ReportRepository {
+	return &PgxReportRepository{DB: db}
+}
+
+// 通報の挿入。(message_id, reporter_id)の一意制約に違反した場合は同じ通報者による重複とみなす
+func (r *PgxReportRepository) SaveReport(ctx context.Context, report *domain.Report) error {
+	const query = `
+	INSERT INTO message_reports (id, message_id, tip_id, reporter_id, reason, detail, status, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	m := ToReportDbModel(report)
+	_, err := r.DB.Exec(ctx, query,
+		m.ID,
+		m.MessageID,
+		m.TipID,
+		m.ReporterID,
+		m.Reason,
+		m.Detail,
+		m.Status,
+		m.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return domain.ErrAlreadyReported
+		}
+		return err
+	}
+	slog.InfoContext(ctx, "メッセージ通報（永続化）", "report_id", string(report.ID), logging.KeyMessageID, string(report.MessageID))
+	return nil
+}
+
+// メッセージに対する通報者の数を取得。通報者ごとに一意なので行数がそのまま通報者数になる
+func (r *PgxReportRepository) CountReportsByMessageID(ctx context.Context, messageID domain.MessageID) (int, error) {
+	const query = `
+	SELECT COUNT(*)
+	FROM message_reports
+	WHERE message_id = $1
+	`
+	var count int
+	if err := r.DB.QueryRow(ctx, query, string(messageID)).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// 通報をIDで取得する
+func (r *PgxReportRepository) FetchReportByID(ctx context.Context, id domain.ReportID) (*domain.Report, error) {
+	const query = `
+	SELECT id, message_id, tip_id, reporter_id, reason, detail, status, created_at, resolved_at, resolved_by
+	FROM message_reports
+	WHERE id = $1
+	`
+	var m ReportModel
+	err := r.DB.QueryRow(ctx, query, string(id)).Scan(
+		&m.ID,
+		&m.MessageID,
+		&m.TipID,
+		&m.ReporterID,
+		&m.Reason,
+		&m.Detail,
+		&m.Status,
+		&m.CreatedAt,
+		&m.ResolvedAt,
+		&m.ResolvedBy,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToReportDomainModel(&m), nil
+}
+
+// 未対応の通報を通報対象のメッセージ（文脈表示用）と、そのメッセージに対する通報数と一緒に古い順で取得
+func (r *PgxReportRepository) FetchPendingReports(ctx context.Context) ([]*domain.Report, error) {
+	const query = `
+	SELECT
+		r.id, r.message_id, r.tip_id, r.reporter_id, r.reason, r.detail, r.status, r.created_at, r.resolved_at, r.resolved_by,
+		m.id, m.tip_id, m.user_id, m.content, m.created_at, m.updated_at, m.deleted_at, m.hidden_at,
+		(SELECT COUNT(*) FROM message_reports c WHERE c.message_id = r.message_id)
+	FROM message_reports r
+	JOIN messages m ON m.id = r.message_id
+	WHERE r.status = $1
+	ORDER BY r.created_at ASC
+	`
+	rows, err := r.DB.Query(ctx, query, string(domain.ReportStatusPending))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*domain.Report
+	for rows.Next() {
+		var rm ReportModel
+		var mm MessageModel
+		var count int
+		if err := rows.Scan(
+			&rm.ID, &rm.MessageID, &rm.TipID, &rm.ReporterID, &rm.Reason, &rm.Detail, &rm.Status, &rm.CreatedAt, &rm.ResolvedAt, &rm.ResolvedBy,
+			&mm.ID, &mm.TipID, &mm.UserID, &mm.Content, &mm.CreatedAt, &mm.UpdatedAt, &mm.DeletedAt, &mm.HiddenAt,
+			&count,
+		); err != nil {
+			return nil, err
+		}
+		report := ToReportDomainModel(&rm)
+		report.Message = ToDomainModel(&mm, false)
+		report.ReportCount = count
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "未対応の通報一覧取得")
+	return reports, nil
+}
+
+// メッセージに対する未対応の通報を全て解決済みにし、msgがあれば同じトランザクションでメッセージの変更も永続化する
+// 通報の行を条件付きで更新してから（行ロックを取ってから）メッセージを変更するので、
+// 複数のモデレーターが同時に解決しても、先に更新した1人のアクションだけが反映され、残りはErrReportAlreadyResolvedになる
+func (r *PgxReportRepository) ResolveReportsByMessageID(ctx context.Context, messageID domain.MessageID, status domain.ReportStatus, resolvedBy domain.UserID, msg *domain.Message) error {
+	const query = `
+	UPDATE message_reports
+	SET status = $1, resolved_at = $2, resolved_by = $3
+	WHERE message_id = $4 AND status = $5
+	`
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query,
+		string(status),
+		time.Now(),
+		string(resolvedBy),
+		string(messageID),
+		string(domain.ReportStatusPending))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrReportAlreadyResolved
+	}
+
+	var version int64
+	if msg != nil {
+		switch status {
+		case domain.ReportStatusDeleted:
+			if version, err = softDeleteMessage(ctx, tx, msg); err != nil {
+				return err
+			}
+		case domain.ReportStatusDismissed:
+			if err := updateHiddenAt(ctx, tx, msg); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "通報の解決（永続化）", logging.KeyMessageID, string(messageID), "status", string(status), "message_changed", msg != nil)
+	if msg != nil && status == domain.ReportStatusDeleted {
+		msg.Version = version
+		countMessageEvent(domain.OutboxEventMessageDeleted)
+	}
+	return nil
+}
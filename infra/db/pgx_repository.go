@@ -5,12 +5,41 @@ package db
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
 )
 
+// messagesのSELECTで取得する列（scanMessageの引数の順番と合わせる）
+// 最後の列はそのメッセージに紐づく添付ファイルのIDの配列
+const messageColumns = `id, tip_id, user_id, content, created_at, updated_at, deleted_at, hidden_at, client_msg_id, version,
+	ARRAY(SELECT a.id::text FROM attachments a WHERE a.message_id = messages.id ORDER BY a.created_at) AS attachment_ids`
+
+// messageColumnsで取得した1行をDBモデル構造体に読み込む
+func scanMessage(row pgx.Row) (*MessageModel, error) {
+	var m MessageModel
+	err := row.Scan(
+		&m.ID,
+		&m.TipID,
+		&m.UserID,
+		&m.Content,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+		&m.DeletedAt,
+		&m.HiddenAt,
+		&m.ClientMsgID,
+		&m.Version,
+		&m.AttachmentIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 type PgxMessageRepository struct {
 	DB *pgxpool.Pool
 }
@@ -22,112 +51,331 @@ func NewPgxMessageRepository(db *pgxpool.Pool) domain.MessageRepository {
 // メッセージをIDで取得する（論理削除も含めて）
 func (r *PgxMessageRepository) FetchMessageByID(ctx context.Context, id domain.MessageID) (*domain.Message, error) {
 	const query = `
-		SELECT id, tip_id, user_id, content, created_at, updated_at, deleted_at
+		SELECT ` + messageColumns + `
 		FROM messages
 		WHERE id = $1
 	`
 
-	var m MessageModel
-	err := r.DB.QueryRow(ctx, query, string(id)).Scan(
-		&m.ID,
-		&m.TipID,
-		&m.UserID,
-		&m.Content,
-		&m.CreatedAt,
-		&m.UpdatedAt,
-		&m.DeletedAt,
-	)
+	m, err := scanMessage(r.DB.QueryRow(ctx, query, string(id)))
 	if err != nil {
 		return nil, err
 	}
-	return ToDomainModel(&m, false), nil // 同時にドメインモデル構造体に変換
+	return ToDomainModel(m, false), nil // 同時にドメインモデル構造体に変換
+}
+
+// ユーザー・Tip・ClientMsgIDの組でメッセージを取得する（再送されたメッセージの元のメッセージを探す用）。存在しなければnilを返す
+func (r *PgxMessageRepository) FetchMessageByClientMsgID(ctx context.Context, userID domain.UserID, tipID domain.TipID, clientMsgID string) (*domain.Message, error) {
+	const query = `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE user_id = $1 AND tip_id = $2 AND client_msg_id = $3
+	`
+
+	m, err := scanMessage(r.DB.QueryRow(ctx, query, string(userID), string(tipID), clientMsgID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToDomainModel(m, false), nil
 }
 
 // 以下永続化処理
 // DBモデル構造体→ドメインモデル構造体へのマッピング、その逆のマッピングは変換関数を使用（/infra/db/mapper.goに定義）
 
-// メッセージの挿入（ユースケース的にはメッセージ送信）。これを使うコードの実装は後回しだけどインターフェースの条件満たすために先に定義。
-func (r *PgxMessageRepository) SaveMessage(msg *domain.Message) error {
+// メッセージの挿入（ユースケース的にはメッセージ送信）。メンションと送信のイベントも同じトランザクションでmessage_mentionsとoutboxに挿入する
+// 同じユーザー・Tip・ClientMsgIDの組が挿入済みなら何も挿入せずErrDuplicateClientMsgIDを返す
+func (r *PgxMessageRepository) SaveMessage(ctx context.Context, msg *domain.Message) error {
 	const query = `
-	INSERT INTO messages (id, tip_id, user_id, content, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6)
+	INSERT INTO messages (id, tip_id, user_id, content, created_at, updated_at, client_msg_id, version)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (user_id, tip_id, client_msg_id) WHERE client_msg_id IS NOT NULL DO NOTHING
 	`
 	dbMsg := ToDbModel(msg)
-	_, err := r.DB.Exec(context.Background(), query,
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Commit済みなら何もしない
+
+	tag, err := tx.Exec(ctx, query,
 		dbMsg.ID,
 		dbMsg.TipID,
 		dbMsg.UserID,
 		dbMsg.Content,
 		dbMsg.CreatedAt,
-		dbMsg.UpdatedAt)
-	log.Printf("メッセージ送信（永続化）")
-	return err
+		dbMsg.UpdatedAt,
+		dbMsg.ClientMsgID,
+		dbMsg.Version)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrDuplicateClientMsgID
+	}
+	if err := insertMentions(ctx, tx, msg); err != nil {
+		return err
+	}
+	if err := attachAttachments(ctx, tx, dbMsg); err != nil {
+		return err
+	}
+	if err := insertOutboxEvent(ctx, tx, domain.OutboxEventMessageSent, msg); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	countMessageEvent(domain.OutboxEventMessageSent)
+	slog.InfoContext(ctx, "メッセージ送信（永続化）", messageAttrs(msg)...)
+	return nil
 }
 
 // メッセージの編集。編集対象のメッセージがなければエラー返す
+// 編集でメンションが変わりうるので、同じトランザクションでmessage_mentionsを入れ替え、編集のイベントをoutboxに書き込む
+// msg.Versionが現在のバージョンと一致する場合だけ更新し、一致しなければ*domain.VersionConflictErrorを返す
+// 更新に成功したらmsg.Versionを新しいバージョンに進める
 func (r *PgxMessageRepository) Update(ctx context.Context, msg *domain.Message) error {
 	const query = `
 	UPDATE messages
-	SET content = $1, updated_at = $2
-	WHERE id = $3
+	SET content = $1, updated_at = $2, version = version + 1
+	WHERE id = $3 AND version = $4
+	RETURNING version
+	`
+	const deleteMentionsQuery = `
+	DELETE FROM message_mentions
+	WHERE message_id = $1
 	`
 	dbMsg := ToDbModel(msg)
-	tag, err := r.DB.Exec(ctx, query, dbMsg.Content, dbMsg.UpdatedAt, dbMsg.ID)
+
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	if tag.RowsAffected() == 0 {
-		return errors.New("対象メッセージが見つかりません")
+	defer tx.Rollback(ctx)
+
+	var version int64
+	err = tx.QueryRow(ctx, query, dbMsg.Content, dbMsg.UpdatedAt, dbMsg.ID, dbMsg.Version).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return versionConflict(ctx, tx, msg.ID, "対象メッセージが見つかりません")
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, deleteMentionsQuery, dbMsg.ID); err != nil {
+		return err
+	}
+	if err := insertMentions(ctx, tx, msg); err != nil {
+		return err
+	}
+	// コミットに失敗した場合にmsg.Versionだけ進んでしまわないように、イベントにはコピーを渡す
+	edited := *msg
+	edited.Version = version
+	if err := insertOutboxEvent(ctx, tx, domain.OutboxEventMessageEdited, &edited); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	msg.Version = version
+	countMessageEvent(domain.OutboxEventMessageEdited)
+	slog.InfoContext(ctx, "メッセージ編集（永続化）", messageAttrs(msg)...)
+	return nil
+}
+
+// 条件付きUPDATEで1行も更新されなかった理由を調べる
+// メッセージが存在すればバージョンの不一致なので現在のバージョンと内容を持った*domain.VersionConflictErrorを、存在しなければnotFoundMsgのエラーを返す
+func versionConflict(ctx context.Context, tx pgx.Tx, id domain.MessageID, notFoundMsg string) error {
+	const query = `
+	SELECT version, content
+	FROM messages
+	WHERE id = $1
+	`
+	conflict := &domain.VersionConflictError{MessageID: id}
+	err := tx.QueryRow(ctx, query, string(id)).Scan(&conflict.CurrentVersion, &conflict.CurrentContent)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New(notFoundMsg)
+	}
+	if err != nil {
+		return err
+	}
+	return conflict
+}
+
+// アップロード済みの添付ファイルをメッセージに紐づける（メッセージの保存のトランザクション内で呼ぶ）
+// 同じTipに同じユーザーがアップロードした未添付のものだけを紐づけ、1つでも条件を満たさなければエラーにする
+func attachAttachments(ctx context.Context, tx pgx.Tx, dbMsg *MessageModel) error {
+	if len(dbMsg.AttachmentIDs) == 0 {
+		return nil
+	}
+	const query = `
+	UPDATE attachments
+	SET message_id = $1
+	WHERE id = ANY($2::uuid[]) AND tip_id = $3 AND uploader_id = $4 AND message_id IS NULL
+	`
+	tag, err := tx.Exec(ctx, query, dbMsg.ID, dbMsg.AttachmentIDs, dbMsg.TipID, dbMsg.UserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() != int64(len(dbMsg.AttachmentIDs)) {
+		return domain.ErrAttachmentUnavailable
+	}
+	return nil
+}
+
+// メッセージに含まれるメンションをmessage_mentionsに挿入する（メッセージの保存・編集のトランザクション内で呼ぶ）
+func insertMentions(ctx context.Context, tx pgx.Tx, msg *domain.Message) error {
+	const query = `
+	INSERT INTO message_mentions (message_id, tip_id, mentioned_user_id, mentioned_by, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+	for _, mention := range msg.MentionList() {
+		m := ToMentionDbModel(mention)
+		if _, err := tx.Exec(ctx, query, m.MessageID, m.TipID, m.MentionedUserID, m.MentionedBy, m.CreatedAt); err != nil {
+			return err
+		}
 	}
-	log.Printf("メッセージ編集（永続化）")
 	return nil
 }
 
 // メッセージの論理削除（deleted_atを設定）。削除対象のメッセージなければエラー返す
+// 削除されたメッセージがピン留めされたまま残らないよう、同じトランザクションでピン留めも解除し、削除（とピン留め解除）のイベントをoutboxに書き込む
 func (r *PgxMessageRepository) SoftDelete(ctx context.Context, msg *domain.Message) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	version, err := softDeleteMessage(ctx, tx, msg)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	msg.Version = version
+	countMessageEvent(domain.OutboxEventMessageDeleted)
+	slog.InfoContext(ctx, "メッセージ削除（永続化）", messageAttrs(msg)...)
+	return nil
+}
+
+// メッセージを論理削除し、ピン留めの解除と削除のイベントの書き込みまでを行う（トランザクション内で呼ぶ）
+// ピン留めされていた場合は、ピン留め一覧を表示しているクライアントのためにピン留め解除のイベントも書き込む
+// msg.Versionが現在のバージョンと一致しなければ*domain.VersionConflictErrorを返す。成功したら新しいバージョンを返す
+func softDeleteMessage(ctx context.Context, tx pgx.Tx, msg *domain.Message) (int64, error) {
+	const query = `
+	UPDATE messages
+	SET deleted_at = $1, version = version + 1
+	WHERE id = $2 AND version = $3
+	RETURNING version
+	`
+	dbMsg := ToDbModel(msg)
+
+	var version int64
+	err := tx.QueryRow(ctx, query, dbMsg.DeletedAt, dbMsg.ID, dbMsg.Version).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, versionConflict(ctx, tx, msg.ID, "削除対象のメッセージが見つかりません")
+	}
+	if err != nil {
+		return 0, err
+	}
+	unpinned, err := deletePinsByMessageID(ctx, tx, dbMsg.ID)
+	if err != nil {
+		return 0, err
+	}
+	// コミットに失敗した場合にmsg.Versionだけ進んでしまわないように、イベントにはコピーを渡す
+	deleted := *msg
+	deleted.Version = version
+	if err := insertOutboxEvent(ctx, tx, domain.OutboxEventMessageDeleted, &deleted); err != nil {
+		return 0, err
+	}
+	if unpinned {
+		if err := insertOutboxEvent(ctx, tx, domain.OutboxEventMessageUnpinned, &deleted); err != nil {
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+// メッセージの非表示状態の更新（hidden_atを設定、またはNULLに戻す）。対象のメッセージがなければエラー返す
+// 同じトランザクションで非表示または非表示解除のイベントをoutboxに書き込む
+func (r *PgxMessageRepository) UpdateHiddenAt(ctx context.Context, msg *domain.Message) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateHiddenAt(ctx, tx, msg); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "メッセージ非表示状態の更新（永続化）", messageAttrs(msg)...)
+	return nil
+}
+
+// メッセージのhidden_atを更新し、非表示（hidden_atがnilでない）か非表示解除のイベントを書き込む（トランザクション内で呼ぶ）
+func updateHiddenAt(ctx context.Context, tx pgx.Tx, msg *domain.Message) error {
 	const query = `
 	UPDATE messages
-	SET deleted_at = $1
+	SET hidden_at = $1
 	WHERE id = $2
 	`
 	dbMsg := ToDbModel(msg)
-	tag, err := r.DB.Exec(ctx, query, dbMsg.DeletedAt, dbMsg.ID)
+	tag, err := tx.Exec(ctx, query, dbMsg.HiddenAt, dbMsg.ID)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return errors.New("削除対象のメッセージが見つかりません")
+		return errors.New("対象メッセージが見つかりません")
 	}
-	log.Printf("メッセージ削除（永続化）")
-	return nil
+	eventType := domain.OutboxEventMessageUnhidden
+	if msg.HiddenAt != nil {
+		eventType = domain.OutboxEventMessageHidden
+	}
+	return insertOutboxEvent(ctx, tx, eventType, msg)
 }
 
 // tip_idに紐づくメッセージの一覧をcreatedAtの昇順で取得。
-func (r *PgxMessageRepository) GetAllMessages(tipID domain.TipID) ([]*domain.Message, error) {
+func (r *PgxMessageRepository) GetAllMessages(ctx context.Context, tipID domain.TipID) ([]*domain.Message, error) {
 	const query = `
-	SELECT id, tip_id, user_id, content, created_at, updated_at, deleted_at
+	SELECT ` + messageColumns + `
 	FROM messages
 	WHERE tip_id = $1
 	ORDER BY created_at ASC
 	`
-	rows, err := r.DB.Query(context.Background(), query, string(tipID))
+	rows, err := r.DB.Query(ctx, query, string(tipID))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var messages []*domain.Message
 	for rows.Next() {
-		var m MessageModel
-		if err := rows.Scan(&m.ID, &m.TipID, &m.UserID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
-		message := ToDomainModel(&m, false)
+		message := ToDomainModel(m, false)
 		messages = append(messages, message)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	log.Printf("メッセージ一覧取得")
+	slog.DebugContext(ctx, "メッセージ一覧取得", logging.KeyTipID, string(tipID))
 	return messages, nil
 }
+
+// メッセージのログに付ける属性（内容は含めない）
+func messageAttrs(msg *domain.Message) []any {
+	return []any{
+		logging.KeyTipID, string(msg.TipID),
+		logging.KeyUserID, string(msg.UserID),
+		logging.KeyMessageID, string(msg.ID),
+		"version", msg.Version,
+	}
+}
@@ -0,0 +1,36 @@
+package db
+
+// ドメイン層で定義したTipAuthorizerの、tip_membersとtip_settingsを使った実装
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type PgxTipAuthorizer struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxTipAuthorizer(db *pgxpool.Pool) domain.TipAuthorizer {
+	return &PgxTipAuthorizer{DB: db}
+}
+
+// Tipの公開範囲とユーザーの役割を1回のクエリで取得し、ドメイン層のルールで判定する
+// tip_settingsに行が無いTipは公開、tip_membersに行が無いユーザーは参加していないものとして扱う
+func (a *PgxTipAuthorizer) Authorize(ctx context.Context, tipID domain.TipID, userID domain.UserID, access domain.TipAccess) error {
+	const query = `
+	SELECT
+		COALESCE((SELECT visibility FROM tip_settings WHERE tip_id = $1), 'public'),
+		COALESCE((SELECT role FROM tip_members WHERE tip_id = $1 AND user_id = $2), '')
+	`
+	var visibility, role string
+	if err := a.DB.QueryRow(ctx, query, string(tipID), string(userID)).Scan(&visibility, &role); err != nil {
+		return err
+	}
+	if !domain.CanAccessTip(domain.TipVisibility(visibility), domain.TipRole(role), access) {
+		return domain.ErrTipAccessDenied
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package db
+
+// ドメイン層で定義したTipの参加者の取得処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type PgxTipMemberRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxTipMemberRepository(db *pgxpool.Pool) domain.TipMemberRepository {
+	return &PgxTipMemberRepository{DB: db}
+}
+
+// ユーザーのTipに対する役割を取得。参加していなければTipRoleNoneを返す
+func (r *PgxTipMemberRepository) FetchTipRole(ctx context.Context, tipID domain.TipID, userID domain.UserID) (domain.TipRole, error) {
+	const query = `
+	SELECT role
+	FROM tip_members
+	WHERE tip_id = $1 AND user_id = $2
+	`
+	var role string
+	err := r.DB.QueryRow(ctx, query, string(tipID), string(userID)).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TipRoleNone, nil
+	}
+	if err != nil {
+		return domain.TipRoleNone, err
+	}
+	return domain.TipRole(role), nil
+}
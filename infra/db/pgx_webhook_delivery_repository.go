@@ -0,0 +1,178 @@
+package db
+
+// ドメイン層で定義したWebhookの配信の永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type PgxWebhookDeliveryRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxWebhookDeliveryRepository(db *pgxpool.Pool) domain.WebhookDeliveryRepository {
+	return &PgxWebhookDeliveryRepository{DB: db}
+}
+
+// 配信の登録。アウトボックスのイベントが再配信された場合に備えて、同じイベントと同じWebhookの配信がすでにあれば無視する
+func (r *PgxWebhookDeliveryRepository) EnqueueDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	const query = `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+	batch := &pgx.Batch{}
+	for _, d := range deliveries {
+		batch.Queue(query,
+			string(d.WebhookID),
+			d.EventID,
+			string(d.EventType),
+			d.Payload,
+			string(d.Status),
+			d.Attempts,
+			d.NextAttemptAt,
+			d.CreatedAt,
+		)
+	}
+	return r.DB.SendBatch(ctx, batch).Close()
+}
+
+// 次の試行日時を過ぎた配信待ちの配信を、試行日時の早い順に確保
+// 確保した配信は次の試行日時をnow+leaseに延ばすので、送信中に他のインスタンスが同じ配信を取り出すことはない
+// 送信の途中で落ちた場合は、leaseが過ぎた後に再び取り出される
+func (r *PgxWebhookDeliveryRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	const query = `
+	UPDATE webhook_deliveries
+	SET next_attempt_at = $1
+	WHERE id IN (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE status = $2 AND next_attempt_at <= $3
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT $4
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at
+	`
+	deliveries, err := r.queryDeliveries(ctx, query, now.Add(lease), string(domain.WebhookDeliveryPending), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNINGの順序は保証されないので、登録順に並べ直す
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+// 配信の状態の更新と試行結果の追加
+func (r *PgxWebhookDeliveryRepository) RecordAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	const updateQuery = `
+	UPDATE webhook_deliveries
+	SET status = $1, attempts = $2, next_attempt_at = $3
+	WHERE id = $4
+	`
+	const insertQuery = `
+	INSERT INTO webhook_delivery_attempts (delivery_id, attempt_no, status_code, error, attempted_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, updateQuery, string(delivery.Status), delivery.Attempts, delivery.NextAttemptAt, int64(delivery.ID))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("対象の配信が見つかりません")
+	}
+	if _, err := tx.Exec(ctx, insertQuery,
+		int64(attempt.DeliveryID),
+		attempt.AttemptNo,
+		attempt.StatusCode,
+		attempt.Error,
+		attempt.AttemptedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Webhookの配信を新しい順に、試行の履歴付きで取得
+func (r *PgxWebhookDeliveryRepository) FetchDeliveriesByWebhookID(ctx context.Context, webhookID domain.WebhookID, limit int) ([]*domain.WebhookDelivery, error) {
+	const query = `
+	SELECT id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2
+	`
+	const attemptsQuery = `
+	SELECT delivery_id, attempt_no, status_code, error, attempted_at
+	FROM webhook_delivery_attempts
+	WHERE delivery_id = ANY($1)
+	ORDER BY delivery_id, attempt_no ASC
+	`
+	deliveries, err := r.queryDeliveries(ctx, query, string(webhookID), limit)
+	if err != nil || len(deliveries) == 0 {
+		return deliveries, err
+	}
+
+	byID := make(map[domain.WebhookDeliveryID]*domain.WebhookDelivery, len(deliveries))
+	ids := make([]int64, 0, len(deliveries))
+	for _, d := range deliveries {
+		byID[d.ID] = d
+		ids = append(ids, int64(d.ID))
+	}
+	rows, err := r.DB.Query(ctx, attemptsQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m WebhookAttemptModel
+		if err := rows.Scan(&m.DeliveryID, &m.AttemptNo, &m.StatusCode, &m.Error, &m.AttemptedAt); err != nil {
+			return nil, err
+		}
+		attempt := ToWebhookAttemptDomainModel(&m)
+		if d, ok := byID[attempt.DeliveryID]; ok {
+			d.History = append(d.History, attempt)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *PgxWebhookDeliveryRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]*domain.WebhookDelivery, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		var m WebhookDeliveryModel
+		if err := rows.Scan(&m.ID, &m.WebhookID, &m.EventID, &m.EventType, &m.Payload, &m.Status, &m.Attempts, &m.NextAttemptAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, ToWebhookDeliveryDomainModel(&m))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
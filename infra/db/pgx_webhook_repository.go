@@ -0,0 +1,112 @@
+package db
+
+// ドメイン層で定義したWebhookの登録内容の永続化処理のインターフェースをここで実装
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type PgxWebhookRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPgxWebhookRepository(db *pgxpool.Pool) domain.WebhookRepository {
+	return &PgxWebhookRepository{DB: db}
+}
+
+// Webhookの登録
+func (r *PgxWebhookRepository) SaveWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	const query = `
+	INSERT INTO webhooks (id, tip_id, url, secret, created_by, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	m := ToWebhookDbModel(webhook)
+	if _, err := r.DB.Exec(ctx, query, m.ID, m.TipID, m.URL, m.Secret, m.CreatedBy, m.CreatedAt); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Webhook登録（永続化）", "webhook_id", string(webhook.ID))
+	return nil
+}
+
+// 登録済みのWebhookを登録日時の昇順で全て取得
+func (r *PgxWebhookRepository) FetchWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	const query = `
+	SELECT id, tip_id, url, secret, created_by, created_at
+	FROM webhooks
+	ORDER BY created_at ASC
+	`
+	return r.queryWebhooks(ctx, query)
+}
+
+// IDに対応するWebhookを取得
+func (r *PgxWebhookRepository) FetchWebhookByID(ctx context.Context, id domain.WebhookID) (*domain.Webhook, error) {
+	const query = `
+	SELECT id, tip_id, url, secret, created_by, created_at
+	FROM webhooks
+	WHERE id = $1
+	`
+	var m WebhookModel
+	err := r.DB.QueryRow(ctx, query, string(id)).Scan(&m.ID, &m.TipID, &m.URL, &m.Secret, &m.CreatedBy, &m.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToWebhookDomainModel(&m), nil
+}
+
+// tipIDを対象にしたWebhookと、全Tip対象のWebhookを取得
+func (r *PgxWebhookRepository) FetchWebhooksForTip(ctx context.Context, tipID domain.TipID) ([]*domain.Webhook, error) {
+	const query = `
+	SELECT id, tip_id, url, secret, created_by, created_at
+	FROM webhooks
+	WHERE tip_id = $1 OR tip_id IS NULL
+	ORDER BY created_at ASC
+	`
+	return r.queryWebhooks(ctx, query, string(tipID))
+}
+
+// Webhookの削除。配信と試行の履歴はON DELETE CASCADEで一緒に削除される
+func (r *PgxWebhookRepository) DeleteWebhook(ctx context.Context, id domain.WebhookID) error {
+	const query = `
+	DELETE FROM webhooks
+	WHERE id = $1
+	`
+	tag, err := r.DB.Exec(ctx, query, string(id))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	slog.InfoContext(ctx, "Webhook削除（永続化）", "webhook_id", string(id))
+	return nil
+}
+
+func (r *PgxWebhookRepository) queryWebhooks(ctx context.Context, query string, args ...any) ([]*domain.Webhook, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*domain.Webhook
+	for rows.Next() {
+		var m WebhookModel
+		if err := rows.Scan(&m.ID, &m.TipID, &m.URL, &m.Secret, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, ToWebhookDomainModel(&m))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
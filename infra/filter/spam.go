@@ -0,0 +1,125 @@
+package filter
+
+// ドメイン層で定義したContentFilterの実装（同一内容の連投を検出するフィルター）
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 同じユーザーが同じTipに同じ内容を一定時間内に繰り返し投稿した場合に発動するフィルター
+// 直近の投稿はメモリ上にだけ保持する（サーバーを複数台にする場合はRedis等に置き換える）
+// 数えるのは保存されたメッセージだけ（Applyでは判定だけを行い、保存後のRecordで記録する）
+type RepeatSpamFilter struct {
+	window     time.Duration // 連投とみなす期間
+	maxRepeats int           // この回数を超えて同じ内容が投稿されたら発動する
+	action     domain.FilterAction
+	mu         sync.Mutex
+	recent     map[spamKey][]spamEntry // ユーザーとTipごとの直近の投稿
+}
+
+type spamKey struct {
+	userID domain.UserID
+	tipID  domain.TipID
+}
+
+type spamEntry struct {
+	messageID domain.MessageID
+	content   string // 比較用に正規化した内容
+	at        time.Time
+}
+
+// 連投とみなす期間と許容回数、発動時の動作からフィルターを生成する
+// 伏せ字化は意味を持たないので、maskが指定された場合はflagとして扱う
+func NewRepeatSpamFilter(window time.Duration, maxRepeats int, action domain.FilterAction) *RepeatSpamFilter {
+	if action == domain.FilterActionMask {
+		action = domain.FilterActionFlag
+	}
+	return &RepeatSpamFilter{
+		window:     window,
+		maxRepeats: maxRepeats,
+		action:     action,
+		recent:     make(map[spamKey][]spamEntry),
+	}
+}
+
+func (f *RepeatSpamFilter) Name() string {
+	return "repeat_spam"
+}
+
+func (f *RepeatSpamFilter) Apply(ctx context.Context, msg *domain.Message) (*domain.FilterResult, error) {
+	if f.maxRepeats <= 0 || f.window <= 0 {
+		return nil, nil
+	}
+	key := spamKey{userID: msg.UserID, tipID: msg.TipID}
+	content := normalizeSpamContent(msg.Content)
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 期間内の同じ内容の別メッセージを数える
+	// 同じメッセージIDは編集なので数えない
+	repeats := 0
+	for _, e := range f.recent[key] {
+		if now.Sub(e.at) > f.window {
+			continue
+		}
+		if e.content == content && e.messageID != msg.ID {
+			repeats++
+		}
+	}
+	if repeats < f.maxRepeats {
+		return nil, nil
+	}
+	return &domain.FilterResult{
+		FilterName: f.Name(),
+		Action:     f.action,
+		Reason:     fmt.Sprintf("%s以内に同じ内容を%d回投稿", f.window, repeats+1),
+	}, nil
+}
+
+// 保存されたメッセージを直近の投稿として記録する
+// 期間外の投稿はここで捨てる。編集の場合は同じメッセージIDの記録を置き換える
+func (f *RepeatSpamFilter) Record(ctx context.Context, msg *domain.Message) {
+	if f.maxRepeats <= 0 || f.window <= 0 {
+		return
+	}
+	key := spamKey{userID: msg.UserID, tipID: msg.TipID}
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries := f.recent[key][:0]
+	for _, e := range f.recent[key] {
+		if now.Sub(e.at) > f.window || e.messageID == msg.ID {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	f.recent[key] = append(entries, spamEntry{messageID: msg.ID, content: normalizeSpamContent(msg.Content), at: now})
+}
+
+// 比較用に内容を正規化する（前後の空白と大文字・小文字の違いを無視する）
+func normalizeSpamContent(content string) string {
+	return strings.ToLower(strings.TrimSpace(content))
+}
+
+// 期間外の投稿しか残っていないユーザーとTipの組をメモリから削除する
+// Hubのルーム管理ループと同様に、呼び出し側で定期的に実行する
+func (f *RepeatSpamFilter) Sweep() {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, entries := range f.recent {
+		if len(entries) == 0 || now.Sub(entries[len(entries)-1].at) > f.window {
+			delete(f.recent, key)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package filter
+
+// ドメイン層で定義したContentFilterの実装（URLの許可リスト・拒否リストのフィルター）
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+var urlPattern = regexp.MustCompile(`(?i)https?://[^\s]+`)
+
+// メッセージに含まれるURLのホストを検査するフィルター
+// 拒否リストに一致するホスト、または許可リストが設定されている場合にそれに一致しないホストがあれば発動する
+// リストの各要素はドメイン名で、そのサブドメインにも一致する（例：example.com は www.example.com にも一致）
+type URLFilter struct {
+	allow  []string
+	deny   []string
+	action domain.FilterAction
+}
+
+// 許可リスト・拒否リストと発動時の動作からフィルターを生成する
+// 伏せ字化の場合は該当URLを「[blocked]」に置き換える
+func NewURLFilter(allow, deny []string, action domain.FilterAction) *URLFilter {
+	return &URLFilter{
+		allow:  normalizeHosts(allow),
+		deny:   normalizeHosts(deny),
+		action: action,
+	}
+}
+
+func (f *URLFilter) Name() string {
+	return "url"
+}
+
+func (f *URLFilter) Apply(ctx context.Context, msg *domain.Message) (*domain.FilterResult, error) {
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return nil, nil
+	}
+
+	var blocked []string
+	content := urlPattern.ReplaceAllStringFunc(msg.Content, func(raw string) string {
+		if f.isAllowed(raw) {
+			return raw
+		}
+		blocked = append(blocked, raw)
+		return "[blocked]"
+	})
+	if len(blocked) == 0 {
+		return nil, nil
+	}
+
+	if f.action == domain.FilterActionMask {
+		msg.Content = content
+	}
+	return &domain.FilterResult{
+		FilterName: f.Name(),
+		Action:     f.action,
+		Reason:     "許可されていないURLを検出: " + strings.Join(blocked, ", "),
+	}, nil
+}
+
+// URLのホストがリストの条件を満たすかどうか。パースできないURLは許可しない
+func (f *URLFilter) isAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if matchHost(host, f.deny) {
+		return false
+	}
+	if len(f.allow) > 0 {
+		return matchHost(host, f.allow)
+	}
+	return true
+}
+
+// ホストがリストのいずれかのドメイン、またはそのサブドメインと一致するか
+func matchHost(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHosts(hosts []string) []string {
+	res := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		res = append(res, h)
+	}
+	return res
+}
@@ -0,0 +1,63 @@
+package filter
+
+// ドメイン層で定義したContentFilterの実装（禁止語句のフィルター）
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 禁止語句を含むメッセージを、設定された動作（伏せ字化・拒否・フラグ付け）で処理するフィルター
+// 語句の照合は大文字小文字を区別しない
+type WordListFilter struct {
+	pattern *regexp.Regexp
+	action  domain.FilterAction
+}
+
+// 禁止語句のリストと発動時の動作からフィルターを生成する
+// 語句が1つもなければ何もしないフィルターになる
+func NewWordListFilter(words []string, action domain.FilterAction) *WordListFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		quoted = append(quoted, regexp.QuoteMeta(w))
+	}
+	f := &WordListFilter{action: action}
+	if len(quoted) > 0 {
+		f.pattern = regexp.MustCompile(`(?i)(` + strings.Join(quoted, "|") + `)`)
+	}
+	return f
+}
+
+func (f *WordListFilter) Name() string {
+	return "word_list"
+}
+
+func (f *WordListFilter) Apply(ctx context.Context, msg *domain.Message) (*domain.FilterResult, error) {
+	if f.pattern == nil {
+		return nil, nil
+	}
+	matches := f.pattern.FindAllString(msg.Content, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	// 伏せ字化する場合は該当箇所を文字数分の「*」に置き換える
+	if f.action == domain.FilterActionMask {
+		msg.Content = f.pattern.ReplaceAllStringFunc(msg.Content, func(s string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(s))
+		})
+	}
+	return &domain.FilterResult{
+		FilterName: f.Name(),
+		Action:     f.action,
+		Reason:     "禁止語句を検出: " + strings.Join(matches, ", "),
+	}, nil
+}
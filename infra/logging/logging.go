@@ -0,0 +1,143 @@
+package logging
+
+// log/slogの設定と、ログの相関用の属性（tip_id, user_id, message_id, connection_id, request_id）の受け渡し
+// 相関用の属性はContextに入れておき、slog.InfoContextなどでContextを渡すとこのパッケージのハンドラーが全ての行に付ける
+// 同じキーをログの呼び出し側で直接渡した場合はそちらを優先する
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 相関用の属性のキー。全ての行にこの順番で出力する（不明な場合は空文字）
+const (
+	KeyTipID        = "tip_id"
+	KeyUserID       = "user_id"
+	KeyMessageID    = "message_id"
+	KeyConnectionID = "connection_id"
+	KeyRequestID    = "request_id"
+)
+
+var correlationKeys = [...]string{KeyTipID, KeyUserID, KeyMessageID, KeyConnectionID, KeyRequestID}
+
+// 相関用の属性の値（correlationKeysと同じ順番）
+type correlation [len(correlationKeys)]string
+
+func correlationIndex(key string) int {
+	for i, k := range correlationKeys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+type ctxKey struct{}
+
+func fromContext(ctx context.Context) correlation {
+	if ctx == nil {
+		return correlation{}
+	}
+	c, _ := ctx.Value(ctxKey{}).(correlation)
+	return c
+}
+
+func with(ctx context.Context, key string, value string) context.Context {
+	c := fromContext(ctx)
+	c[correlationIndex(key)] = value
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+func WithTipID(ctx context.Context, tipID string) context.Context {
+	return with(ctx, KeyTipID, tipID)
+}
+
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return with(ctx, KeyUserID, userID)
+}
+
+func WithMessageID(ctx context.Context, messageID string) context.Context {
+	return with(ctx, KeyMessageID, messageID)
+}
+
+func WithConnectionID(ctx context.Context, connectionID string) context.Context {
+	return with(ctx, KeyConnectionID, connectionID)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return with(ctx, KeyRequestID, requestID)
+}
+
+// ログレベルの文字列（"debug", "info", "warn", "error"）を解釈する
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(s))); err != nil {
+		return 0, fmt.Errorf("ログレベルが不正です: %s", s)
+	}
+	return level, nil
+}
+
+// JSONで出力し、全ての行に相関用の属性を付けるハンドラーを生成する
+func NewHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return &handler{inner: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})}
+}
+
+type handler struct {
+	inner slog.Handler
+	bound correlation // Logger.Withで渡された相関用の属性
+	set   [len(correlationKeys)]bool
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// 優先順位は、ログの呼び出し側で渡した属性 > Logger.Withで渡した属性 > Contextの属性
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	c := fromContext(ctx)
+	for i, ok := range h.set {
+		if ok {
+			c[i] = h.bound[i]
+		}
+	}
+	var rest []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		if i := correlationIndex(a.Key); i >= 0 {
+			c[i] = a.Value.String()
+		} else {
+			rest = append(rest, a)
+		}
+		return true
+	})
+
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	for i, k := range correlationKeys {
+		out.AddAttrs(slog.String(k, c[i]))
+	}
+	out.AddAttrs(rest...)
+	return h.inner.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	var rest []slog.Attr
+	for _, a := range attrs {
+		if i := correlationIndex(a.Key); i >= 0 {
+			next.bound[i] = a.Value.String()
+			next.set[i] = true
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	next.inner = h.inner.WithAttrs(rest)
+	return &next
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.inner = h.inner.WithGroup(name)
+	return &next
+}
@@ -0,0 +1,27 @@
+package notify
+
+// ドメイン層で定義したEventSinkの実装
+// 外部の配信先（メッセージキューなど）ができるまでは、イベントの内容をログに出すだけにしておく
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type LogEventSink struct{}
+
+func NewLogEventSink() domain.EventSink {
+	return &LogEventSink{}
+}
+
+func (s *LogEventSink) Name() string {
+	return "log"
+}
+
+func (s *LogEventSink) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	slog.InfoContext(ctx, "イベント配信", "event_id", event.ID, "event_type", string(event.Type), logging.KeyTipID, string(event.Message.TipID), logging.KeyMessageID, string(event.Message.ID), "version", event.Message.Version)
+	return nil
+}
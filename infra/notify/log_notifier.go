@@ -0,0 +1,23 @@
+package notify
+
+// ドメイン層で定義したMentionNotifierの実装
+// プッシュ通知やメールの送信基盤ができるまでは、通知内容をログに出すだけにしておく
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type LogMentionNotifier struct{}
+
+func NewLogMentionNotifier() domain.MentionNotifier {
+	return &LogMentionNotifier{}
+}
+
+func (n *LogMentionNotifier) NotifyMention(ctx context.Context, mention *domain.Mention) error {
+	slog.InfoContext(ctx, "メンション通知", logging.KeyTipID, string(mention.TipID), logging.KeyMessageID, string(mention.MessageID), "to", string(mention.MentionedUserID), "from", string(mention.MentionedBy))
+	return nil
+}
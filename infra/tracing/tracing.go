@@ -0,0 +1,60 @@
+package tracing
+
+// OpenTelemetryのトレースの設定
+// エクスポーターはOTEL_TRACES_EXPORTERと同じ値（otlp, stdout, none）で選ぶ
+// OTLPの送信先やヘッダーはOTEL_EXPORTER_OTLP_ENDPOINTなどの標準の環境変数で設定する
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	ExporterNone   = "none"   // トレースを記録しない（伝播用のヘッダーの受け渡しだけ行う）
+	ExporterStdout = "stdout" // コレクター無しで確認するために標準エラー出力に書き出す
+	ExporterOTLP   = "otlp"   // OTLP/HTTPでコレクターに送信する
+)
+
+// グローバルのTracerProviderとプロパゲーター（W3C Trace Context）を設定する
+// 戻り値の関数はサーバー終了時に呼び出し、未送信のスパンを送り切る
+func Setup(ctx context.Context, exporter string, serviceName string) (func(context.Context) error, error) {
+	// エクスポーターが無くても、受け取ったtraceparentを下流（Webhookなど）に引き継げるようにする
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		// 標準出力はJSONのログが使うので、トレースは標準エラー出力に分ける
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("トレースのエクスポーターが不正です: %s", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("トレースのエクスポーターの初期化に失敗しました: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("トレースのリソースの生成に失敗しました: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
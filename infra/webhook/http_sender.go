@@ -0,0 +1,48 @@
+package webhook
+
+// ドメイン層で定義したWebhookSenderの実装（HTTPでPOSTする）
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 受信側のレスポンス本文は使わないが、接続を再利用できるように読み捨てる上限
+const maxDiscardBody = 64 << 10
+
+type HTTPWebhookSender struct {
+	client *http.Client
+}
+
+// timeoutは1回の送信（接続からレスポンス本文の読み捨てまで）にかける時間の上限
+func NewHTTPWebhookSender(timeout time.Duration) domain.WebhookSender {
+	return &HTTPWebhookSender{client: &http.Client{Timeout: timeout}}
+}
+
+func (s *HTTPWebhookSender) Send(ctx context.Context, req *domain.WebhookRequest) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	// 受信側でトレースを繋げられるように、配信のスパンをtraceparentヘッダーで渡す
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscardBody))
+	return resp.StatusCode, nil
+}
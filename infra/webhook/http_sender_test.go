@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+func TestHTTPWebhookSender_Send(t *testing.T) {
+	const secret = "test-secret"
+	const timestamp int64 = 1700000000
+	body := []byte(`{"event_id":1,"type":"message.sent"}`)
+	signature := domain.SignWebhookPayload(secret, timestamp, body)
+
+	var gotMethod, gotSignature, gotContentType string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotSignature = r.Header.Get("X-Tipstar-Signature")
+		gotContentType = r.Header.Get("Content-Type")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sender := NewHTTPWebhookSender(5 * time.Second)
+	status, err := sender.Send(context.Background(), &domain.WebhookRequest{
+		URL:     server.URL,
+		Headers: map[string]string{"X-Tipstar-Signature": signature},
+		Body:    body,
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	// 2xx以外でもエラーにはせず、ステータスをそのまま返す（リトライするかどうかは配信側が決める）
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	if gotMethod != http.MethodPost {
+		t.Errorf("method = %q, want POST", gotMethod)
+	}
+	if gotSignature != signature {
+		t.Errorf("X-Tipstar-Signature = %q, want %q", gotSignature, signature)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", gotContentType)
+	}
+	if string(gotBody) != string(body) {
+		t.Errorf("本文 = %s, want %s", gotBody, body)
+	}
+}
+
+func TestHTTPWebhookSender_SendConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	status, err := NewHTTPWebhookSender(time.Second).Send(context.Background(), &domain.WebhookRequest{URL: url, Body: []byte("{}")})
+	if err == nil {
+		t.Fatal("接続できない場合はエラーを返すべきです")
+	}
+	if status != 0 {
+		t.Errorf("status = %d, want 0", status)
+	}
+}
@@ -0,0 +1,95 @@
+package origin
+
+// ブラウザからの接続を許可するOriginの判定（WebSocketの昇格のオリジンチェックとREST APIのCORSで共有する）
+// 許可リストには"https://app.example.com"のような完全一致と、"https://*.example.com"のようなサブドメインのワイルドカードを書ける
+// ワイルドカードは1階層以上のサブドメインに一致し、example.com自体には一致しない
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type pattern struct {
+	scheme   string
+	host     string // ワイルドカードの場合は"*."を除いたドメイン
+	port     string // 省略された場合はスキームのデフォルトのポート
+	wildcard bool
+}
+
+type Allowlist struct {
+	patterns []pattern
+	devMode  bool // trueなら全てのOriginを許可する（ローカル開発用）
+}
+
+// 許可リストを解析する。書式が不正なエントリがあればエラーを返す
+func NewAllowlist(entries []string, devMode bool) (*Allowlist, error) {
+	a := &Allowlist{devMode: devMode}
+	for _, entry := range entries {
+		p, err := parsePattern(entry)
+		if err != nil {
+			return nil, err
+		}
+		a.patterns = append(a.patterns, p)
+	}
+	return a, nil
+}
+
+func parsePattern(entry string) (pattern, error) {
+	u, err := url.Parse(strings.TrimSpace(entry))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return pattern{}, fmt.Errorf("許可するOriginの書式が不正です（\"https://example.com\"や\"https://*.example.com\"の形式）: %q", entry)
+	}
+	p := pattern{scheme: u.Scheme, host: strings.ToLower(u.Hostname()), port: portOrDefault(u)}
+	if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.wildcard = rest, true
+	}
+	if p.host == "" || strings.Contains(p.host, "*") {
+		return pattern{}, fmt.Errorf("ワイルドカードはサブドメインの先頭にだけ書けます: %q", entry)
+	}
+	return p, nil
+}
+
+func portOrDefault(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// 開発モードかどうか
+func (a *Allowlist) DevMode() bool {
+	return a.devMode
+}
+
+// Originヘッダーの値が許可リストに一致すればtrueを返す
+func (a *Allowlist) Allowed(origin string) bool {
+	if a.devMode {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme, host, port := u.Scheme, strings.ToLower(u.Hostname()), portOrDefault(u)
+	if net.ParseIP(host) == nil {
+		host = strings.TrimSuffix(host, ".")
+	}
+	for _, p := range a.patterns {
+		if p.scheme != scheme || p.port != port {
+			continue
+		}
+		if p.wildcard {
+			if strings.HasSuffix(host, "."+p.host) {
+				return true
+			}
+		} else if host == p.host {
+			return true
+		}
+	}
+	return false
+}
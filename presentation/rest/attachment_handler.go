@@ -0,0 +1,135 @@
+package rest
+
+// ここではHTTP経由（Rest API）の添付ファイルのアップロードとダウンロードのハンドリングを行う
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+// multipartのヘッダー等のためにファイルサイズの上限に上乗せして許容するバイト数
+const multipartOverhead = 1 << 20
+
+type AttachmentHandler struct {
+	uc      usecase.AttachmentUsecase
+	maxSize int64 // 1ファイルあたりの最大バイト数（リクエストボディの上限に使う）
+}
+
+// 添付ファイルのユースケースを注入するコンストラクタ関数
+func NewAttachmentHandler(uc usecase.AttachmentUsecase, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{uc: uc, maxSize: maxSize}
+}
+
+// 添付ファイルアップロードのハンドラー（POST /tips/{tipID}/attachments、multipartの"file"フィールド）
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	tipID := chi.URLParam(r, "tipID")
+	if tipID == "" {
+		http.Error(w, "tipIDが必要です", http.StatusBadRequest)
+		return
+	}
+
+	// ファイル全体をメモリに載せないよう、multipartはストリームで読む
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+multipartOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "multipart/form-dataで送信してください", http.StatusBadRequest)
+		return
+	}
+	var part io.ReadCloser
+	var fileName string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "リクエストボディが不正です", http.StatusBadRequest)
+			return
+		}
+		if p.FormName() == "file" {
+			part, fileName = p, filepath.Base(p.FileName())
+			break
+		}
+		p.Close()
+	}
+	if part == nil {
+		http.Error(w, "fileフィールドが必要です", http.StatusBadRequest)
+		return
+	}
+	defer part.Close()
+
+	attachment, err := h.uc.Upload(r.Context(), domain.AttachmentID(uuid.New().String()), domain.TipID(tipID), domain.UserID(userID), fileName, part)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, domain.ErrTipAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrAttachmentTooLarge), errors.As(err, &maxBytesErr):
+		http.Error(w, domain.ErrAttachmentTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, domain.ErrAttachmentTypeNotAllowed):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		http.Error(w, "添付ファイルのアップロードに失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := ToAttachmentResponse(attachment)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// 添付ファイルダウンロードのハンドラー（GET /attachments/{attachmentID}）
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	attachmentID := chi.URLParam(r, "attachmentID")
+	if attachmentID == "" {
+		http.Error(w, "attachmentIDが必要です", http.StatusBadRequest)
+		return
+	}
+
+	attachment, body, err := h.uc.Open(r.Context(), domain.AttachmentID(attachmentID), domain.UserID(userID))
+	switch {
+	case errors.Is(err, domain.ErrTipAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrAttachmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "添付ファイルの取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	// ブラウザに中身から形式を推測させない（保存時に判定した形式をそのまま使う）
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, body); err != nil {
+		slog.WarnContext(r.Context(), "Download: 添付ファイルの書き込みに失敗", "error", err)
+	}
+}
@@ -0,0 +1,101 @@
+package rest
+
+// ここではKubernetesのプローブやロードバランサー向けの死活監視と準備完了の確認を行う
+// /healthzはプロセスが応答できるかだけを返し、/readyzは登録されたチェック（DB、Hubのループなど）とシャットダウン中かどうかを返す
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 準備完了の確認で使うチェック。問題があればエラーを返す
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+type HealthHandler struct {
+	checks   []namedHealthCheck
+	timeout  time.Duration // 1回の確認で全てのチェックにかける時間の上限
+	draining atomic.Bool   // シャットダウンが始まったらtrue（新しいリクエストを振り分けないようにしてもらう）
+}
+
+// timeoutはチェック全体（並行に実行する）にかける時間の上限
+func NewHealthHandler(timeout time.Duration) *HealthHandler {
+	return &HealthHandler{timeout: timeout}
+}
+
+// 準備完了の確認にチェックを追加する。サーバー起動前に呼び出す
+func (h *HealthHandler) AddCheck(name string, check HealthCheck) {
+	h.checks = append(h.checks, namedHealthCheck{name: name, check: check})
+}
+
+// シャットダウンの開始を記録する。以降の/readyzは503を返す
+func (h *HealthHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// 死活監視のハンドラー（GET /healthz）
+// 依存先の状態は見ない（DBが落ちただけでプロセスを再起動させないため）
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&HealthResponse{Status: healthStatusOK})
+}
+
+// 準備完了の確認のハンドラー（GET /readyz）
+// チェックを並行に実行し、全て成功していてシャットダウン中でなければ200、それ以外は503を返す
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	results := make(map[string]*HealthCheckResponse, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c namedHealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := c.check(ctx)
+			res := &HealthCheckResponse{
+				Status:    healthStatusOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				res.Status = healthStatusFail
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			results[c.name] = res
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	response := &ReadinessResponse{
+		Status:   healthStatusOK,
+		Draining: h.draining.Load(),
+		Checks:   results,
+	}
+	for _, res := range results {
+		if res.Status != healthStatusOK {
+			response.Status = healthStatusFail
+		}
+	}
+	if response.Draining {
+		response.Status = healthStatusDraining
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if response.Status != healthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,18 +1,35 @@
 package rest
 
 import (
+	"encoding/json"
+
 	"github.com/minminseo/tipstar-chat-api/domain"
 )
 
 // ToChatMessageResponse converts a domain.Message to ChatMessageResponse.
+// Hidden messages are returned without their content, and hidden or deleted messages without their attachments.
 func ToChatMessageResponse(msg *domain.Message) *ChatMessageResponse {
+	content := msg.Content
+	if msg.HiddenAt != nil {
+		content = ""
+	}
+	attachmentIDs := make([]string, 0, len(msg.AttachmentIDs))
+	if msg.HiddenAt == nil && msg.DeletedAt == nil {
+		for _, id := range msg.AttachmentIDs {
+			attachmentIDs = append(attachmentIDs, string(id))
+		}
+	}
 	return &ChatMessageResponse{
 		MessageID: string(msg.ID),
 		TipID:     string(msg.TipID),
 		UserID:    string(msg.UserID),
-		Content:   msg.Content,
+		Content:   content,
 		CreatedAt: msg.CreatedAt.Unix(),
 		UpdatedAt: msg.UpdatedAt.Unix(),
+		IsHidden:  msg.HiddenAt != nil,
+		Version:   msg.Version,
+
+		AttachmentIDs: attachmentIDs,
 	}
 }
 
@@ -24,3 +41,169 @@ func ToChatMessagesResponse(msgs []*domain.Message) []*ChatMessageResponse {
 	}
 	return res
 }
+
+// ToReportResponse converts a domain.Report (with its message context) to ReportResponse.
+func ToReportResponse(r *domain.Report) *ReportResponse {
+	res := &ReportResponse{
+		ReportID:    string(r.ID),
+		ReporterID:  string(r.ReporterID),
+		Reason:      string(r.Reason),
+		Detail:      r.Detail,
+		Status:      string(r.Status),
+		CreatedAt:   r.CreatedAt.Unix(),
+		ReportCount: r.ReportCount,
+	}
+	if m := r.Message; m != nil {
+		res.Message = &ReportedMessageContext{
+			MessageID: string(m.ID),
+			TipID:     string(m.TipID),
+			UserID:    string(m.UserID),
+			Content:   m.Content,
+			CreatedAt: m.CreatedAt.Unix(),
+			IsHidden:  m.HiddenAt != nil,
+			IsDeleted: m.DeletedAt != nil,
+		}
+	}
+	return res
+}
+
+// ToReportsResponse converts a slice of domain.Report to a slice of ReportResponse.
+func ToReportsResponse(reports []*domain.Report) []*ReportResponse {
+	res := make([]*ReportResponse, 0, len(reports))
+	for _, r := range reports {
+		res = append(res, ToReportResponse(r))
+	}
+	return res
+}
+
+// ToFilterLogsResponse converts a slice of domain.FilterLog to a slice of FilterLogResponse.
+func ToFilterLogsResponse(logs []*domain.FilterLog) []*FilterLogResponse {
+	res := make([]*FilterLogResponse, 0, len(logs))
+	for _, l := range logs {
+		res = append(res, &FilterLogResponse{
+			MessageID:  string(l.MessageID),
+			TipID:      string(l.TipID),
+			UserID:     string(l.UserID),
+			FilterName: l.FilterName,
+			Action:     string(l.Action),
+			Reason:     l.Reason,
+			CreatedAt:  l.CreatedAt.Unix(),
+		})
+	}
+	return res
+}
+
+// ToMentionsResponse converts a slice of domain.Mention to a slice of MentionResponse.
+func ToMentionsResponse(mentions []*domain.Mention) []*MentionResponse {
+	res := make([]*MentionResponse, 0, len(mentions))
+	for _, m := range mentions {
+		r := &MentionResponse{
+			MessageID:   string(m.MessageID),
+			TipID:       string(m.TipID),
+			MentionedBy: string(m.MentionedBy),
+			CreatedAt:   m.CreatedAt.Unix(),
+		}
+		if m.Message != nil {
+			msg := ToChatMessageResponse(m.Message)
+			r.Content = msg.Content
+			r.IsHidden = msg.IsHidden
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// ToUnreadCountsResponse converts a slice of domain.UnreadCount to a slice of UnreadCountResponse.
+func ToUnreadCountsResponse(counts []*domain.UnreadCount) []*UnreadCountResponse {
+	res := make([]*UnreadCountResponse, 0, len(counts))
+	for _, c := range counts {
+		res = append(res, &UnreadCountResponse{TipID: string(c.TipID), Unread: c.Count})
+	}
+	return res
+}
+
+// ToPinsResponse converts a slice of domain.Pin to a slice of PinResponse.
+func ToPinsResponse(pins []*domain.Pin) []*PinResponse {
+	res := make([]*PinResponse, 0, len(pins))
+	for _, p := range pins {
+		r := &PinResponse{
+			Position: p.Position,
+			PinnedBy: string(p.PinnedBy),
+			PinnedAt: p.PinnedAt.Unix(),
+		}
+		if p.Message != nil {
+			r.Message = ToChatMessageResponse(p.Message)
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// ToAttachmentResponse converts a domain.Attachment to AttachmentResponse.
+func ToAttachmentResponse(a *domain.Attachment) *AttachmentResponse {
+	return &AttachmentResponse{
+		AttachmentID: string(a.ID),
+		TipID:        string(a.TipID),
+		FileName:     a.FileName,
+		ContentType:  a.ContentType,
+		Size:         a.Size,
+		CreatedAt:    a.CreatedAt.Unix(),
+	}
+}
+
+// ToWebhookResponse converts a domain.Webhook to WebhookResponse. The secret is included only when withSecret is true.
+func ToWebhookResponse(w *domain.Webhook, withSecret bool) *WebhookResponse {
+	var tipID *string
+	if w.TipID != nil {
+		id := string(*w.TipID)
+		tipID = &id
+	}
+	res := &WebhookResponse{
+		WebhookID: string(w.ID),
+		TipID:     tipID,
+		URL:       w.URL,
+		CreatedBy: string(w.CreatedBy),
+		CreatedAt: w.CreatedAt.Unix(),
+	}
+	if withSecret {
+		res.Secret = w.Secret
+	}
+	return res
+}
+
+// ToWebhooksResponse converts a slice of domain.Webhook to a slice of WebhookResponse without secrets.
+func ToWebhooksResponse(webhooks []*domain.Webhook) []*WebhookResponse {
+	res := make([]*WebhookResponse, 0, len(webhooks))
+	for _, w := range webhooks {
+		res = append(res, ToWebhookResponse(w, false))
+	}
+	return res
+}
+
+// ToWebhookDeliveriesResponse converts a slice of domain.WebhookDelivery to a slice of WebhookDeliveryResponse.
+func ToWebhookDeliveriesResponse(deliveries []*domain.WebhookDelivery) []*WebhookDeliveryResponse {
+	res := make([]*WebhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		history := make([]*WebhookAttemptResponse, 0, len(d.History))
+		for _, a := range d.History {
+			history = append(history, &WebhookAttemptResponse{
+				AttemptNo:   a.AttemptNo,
+				StatusCode:  a.StatusCode,
+				Error:       a.Error,
+				AttemptedAt: a.AttemptedAt.Unix(),
+			})
+		}
+		res = append(res, &WebhookDeliveryResponse{
+			DeliveryID:    int64(d.ID),
+			EventID:       d.EventID,
+			EventType:     string(d.EventType),
+			Status:        string(d.Status),
+			Attempts:      d.Attempts,
+			NextAttemptAt: d.NextAttemptAt.Unix(),
+			CreatedAt:     d.CreatedAt.Unix(),
+			Payload:       json.RawMessage(d.Payload),
+			History:       history,
+		})
+	}
+	return res
+}
@@ -1,5 +1,7 @@
 package rest
 
+import "encoding/json"
+
 // ChatMessageResponse は、REST APIで返すチャットメッセージのレスポンス形式です。
 type ChatMessageResponse struct {
 	MessageID string `json:"message_id"`
@@ -8,4 +10,159 @@ type ChatMessageResponse struct {
 	Content   string `json:"content"`
 	CreatedAt int64  `json:"created_at"` // Unix timestamp
 	UpdatedAt int64  `json:"updated_at"` // Unix timestamp
+	IsHidden  bool   `json:"is_hidden"`  // 通報により非表示になっている場合はtrue（Contentは空で返す）
+	Version   int64  `json:"version"`    // 編集・削除時にexpected_versionとして送るバージョン
+
+	AttachmentIDs []string `json:"attachment_ids"` // 添付ファイルのID。削除済み・非表示のメッセージでは空
+}
+
+// AttachmentResponse は、添付ファイルのアップロード結果のレスポンス形式です。
+// メッセージ送信時にAttachmentIDをattachment_idsに指定して添付します。
+type AttachmentResponse struct {
+	AttachmentID string `json:"attachment_id"`
+	TipID        string `json:"tip_id"`
+	FileName     string `json:"file_name"`
+	ContentType  string `json:"content_type"` // 中身から判定したMIMEタイプ
+	Size         int64  `json:"size"`         // バイト数
+	CreatedAt    int64  `json:"created_at"`   // Unix timestamp
+}
+
+// CreateReportRequest は、メッセージ通報のリクエスト形式です。
+type CreateReportRequest struct {
+	MessageID string `json:"message_id"`
+	Reason    string `json:"reason"` // "spam", "harassment", "inappropriate", "other"
+	Detail    string `json:"detail"` // 任意の補足説明
+}
+
+// ResolveReportRequest は、モデレーターによる通報解決のリクエスト形式です。
+type ResolveReportRequest struct {
+	Action string `json:"action"` // "dismiss" または "delete"
+}
+
+// ReportResponse は、モデレーター向けの通報一覧で返す通報のレスポンス形式です。
+// 判断材料として通報対象のメッセージと、そのメッセージに対する通報数を含めます。
+type ReportResponse struct {
+	ReportID    string                  `json:"report_id"`
+	ReporterID  string                  `json:"reporter_id"`
+	Reason      string                  `json:"reason"`
+	Detail      string                  `json:"detail"`
+	Status      string                  `json:"status"`
+	CreatedAt   int64                   `json:"created_at"` // Unix timestamp
+	ReportCount int                     `json:"report_count"`
+	Message     *ReportedMessageContext `json:"message"`
+}
+
+// ReportedMessageContext は、通報対象のメッセージの文脈です。非表示中でもモデレーター向けにContentを返します。
+type ReportedMessageContext struct {
+	MessageID string `json:"message_id"`
+	TipID     string `json:"tip_id"`
+	UserID    string `json:"user_id"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"` // Unix timestamp
+	IsHidden  bool   `json:"is_hidden"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// FilterLogResponse は、モデレーター向けに返すコンテンツフィルターの発動記録のレスポンス形式です。
+type FilterLogResponse struct {
+	MessageID  string `json:"message_id"`
+	TipID      string `json:"tip_id"`
+	UserID     string `json:"user_id"`
+	FilterName string `json:"filter_name"` // 発動したフィルター
+	Action     string `json:"action"`      // "reject", "mask", "flag"
+	Reason     string `json:"reason"`
+	CreatedAt  int64  `json:"created_at"` // Unix timestamp
+}
+
+// MentionResponse は、自分がメンションされたメッセージのレスポンス形式です。
+type MentionResponse struct {
+	MessageID   string `json:"message_id"`
+	TipID       string `json:"tip_id"`
+	MentionedBy string `json:"mentioned_by"` // メンションしたユーザーのID
+	Content     string `json:"content"`      // 非表示になっている場合は空
+	IsHidden    bool   `json:"is_hidden"`
+	CreatedAt   int64  `json:"created_at"` // Unix timestamp
+}
+
+// MarkReadRequest は、既読位置更新のリクエスト形式です。
+type MarkReadRequest struct {
+	MessageID string `json:"message_id"` // このメッセージまでを既読にする
+}
+
+// UnreadCountResponse は、Tipごとの未読数のレスポンス形式です。
+type UnreadCountResponse struct {
+	TipID  string `json:"tip_id"`
+	Unread int    `json:"unread"`
+}
+
+// PinResponse は、Tip内のピン留めのレスポンス形式です。
+type PinResponse struct {
+	Position int                  `json:"position"`  // ピン留めの並び順
+	PinnedBy string               `json:"pinned_by"` // ピン留めしたユーザーのID
+	PinnedAt int64                `json:"pinned_at"` // Unix timestamp
+	Message  *ChatMessageResponse `json:"message"`
+}
+
+// CreateWebhookRequest は、Webhook登録のリクエスト形式です。
+type CreateWebhookRequest struct {
+	URL   string  `json:"url"`              // イベントをPOSTする先（http/https）
+	TipID *string `json:"tip_id,omitempty"` // 対象のTip。省略すると全Tipのイベントを受け取る
+}
+
+// WebhookResponse は、Webhookのレスポンス形式です。
+// 署名用の鍵は登録時のレスポンスでだけ返します。
+type WebhookResponse struct {
+	WebhookID string  `json:"webhook_id"`
+	TipID     *string `json:"tip_id"` // 全Tip対象ならnull
+	URL       string  `json:"url"`
+	Secret    string  `json:"secret,omitempty"` // X-Tipstar-Signatureの検証に使うHMAC-SHA256の鍵
+	CreatedBy string  `json:"created_by"`
+	CreatedAt int64   `json:"created_at"` // Unix timestamp
+}
+
+// WebhookDeliveryResponse は、Webhookへの配信のレスポンス形式です。
+type WebhookDeliveryResponse struct {
+	DeliveryID    int64                     `json:"delivery_id"`
+	EventID       int64                     `json:"event_id"`
+	EventType     string                    `json:"event_type"` // "message.sent", "message.edited", "message.deleted", "message.hidden", "message.unhidden", "message.unpinned"
+	Status        string                    `json:"status"`     // "pending", "succeeded", "dead"
+	Attempts      int                       `json:"attempts"`
+	NextAttemptAt int64                     `json:"next_attempt_at"` // Unix timestamp（pendingの場合のみ意味を持つ）
+	CreatedAt     int64                     `json:"created_at"`      // Unix timestamp
+	Payload       json.RawMessage           `json:"payload"`         // 送信した（する）本文
+	History       []*WebhookAttemptResponse `json:"history"`
+}
+
+// WebhookAttemptResponse は、Webhookへの配信の1回の試行結果のレスポンス形式です。
+type WebhookAttemptResponse struct {
+	AttemptNo   int    `json:"attempt_no"`
+	StatusCode  int    `json:"status_code"`  // 接続できなかった場合は0
+	Error       string `json:"error"`        // 成功なら空
+	AttemptedAt int64  `json:"attempted_at"` // Unix timestamp
+}
+
+// ヘルスチェックのレスポンスのstatusの値
+const (
+	healthStatusOK       = "ok"
+	healthStatusFail     = "fail"
+	healthStatusDraining = "draining"
+)
+
+// HealthResponse は、死活監視（/healthz）のレスポンス形式です。
+type HealthResponse struct {
+	Status string `json:"status"` // 応答できれば常に"ok"
+}
+
+// ReadinessResponse は、準備完了の確認（/readyz）のレスポンス形式です。
+type ReadinessResponse struct {
+	Status   string                          `json:"status"`   // "ok", "fail"（チェックの失敗）, "draining"（シャットダウン中）
+	Draining bool                            `json:"draining"` // シャットダウン中ならtrue
+	Checks   map[string]*HealthCheckResponse `json:"checks"`   // チェック名ごとの結果
+}
+
+// HealthCheckResponse は、準備完了の確認の個々のチェックの結果です。
+type HealthCheckResponse struct {
+	Status    string  `json:"status"`          // "ok" または "fail"
+	LatencyMs float64 `json:"latency_ms"`      // チェックにかかった時間（ミリ秒）
+	Error     string  `json:"error,omitempty"` // 失敗した場合の理由
 }
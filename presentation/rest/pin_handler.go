@@ -0,0 +1,49 @@
+package rest
+
+// ここではHTTP経由（Rest API）のピン留め系のリクエストのハンドリングを行う
+// ピン留めとピン留め解除はWebSocket経由で行う
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+type PinHandler struct {
+	uc usecase.PinUsecase
+}
+
+// ピン留めのユースケースを注入するコンストラクタ関数
+func NewPinHandler(uc usecase.PinUsecase) *PinHandler {
+	return &PinHandler{uc: uc}
+}
+
+// Tip内のピン留め一覧取得のハンドラー（GET /messages/{tipID}/pins）
+func (h *PinHandler) ListPins(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	tipID := chi.URLParam(r, "tipID")
+	if tipID == "" {
+		http.Error(w, "tipIDが必要です", http.StatusBadRequest)
+		return
+	}
+	pins, err := h.uc.ListPins(r.Context(), domain.TipID(tipID), domain.UserID(userID))
+	if errors.Is(err, domain.ErrTipAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "ピン留めの取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToPinsResponse(pins)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,80 @@
+package rest
+
+// ここではHTTP経由（Rest API）の既読位置と未読数のリクエストのハンドリングを行う
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+// HTTP経由で更新された既読位置を、WebSocketで接続中のクライアントに届けるためのインターフェース
+// 具体的な実装はwebsocketパッケージのHub
+type ReadCursorPublisher interface {
+	PublishRead(cursor *domain.ReadCursor)
+}
+
+type ReadCursorHandler struct {
+	uc        usecase.ReadCursorUsecase
+	publisher ReadCursorPublisher
+}
+
+// 既読位置のユースケースと、ブロードキャスト用のpublisherを注入するコンストラクタ関数
+func NewReadCursorHandler(uc usecase.ReadCursorUsecase, publisher ReadCursorPublisher) *ReadCursorHandler {
+	return &ReadCursorHandler{uc: uc, publisher: publisher}
+}
+
+// 既読位置更新のハンドラー（POST /messages/{tipID}/read）
+// WebSocketのmark_readと同じく、既読位置が進んだ場合のみRoomにブロードキャストする
+func (h *ReadCursorHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	tipID := chi.URLParam(r, "tipID")
+	if tipID == "" {
+		http.Error(w, "tipIDが必要です", http.StatusBadRequest)
+		return
+	}
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == "" {
+		http.Error(w, "message_idが必要です", http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := h.uc.MarkRead(r.Context(), domain.TipID(tipID), domain.UserID(userID), domain.MessageID(req.MessageID))
+	if errors.Is(err, domain.ErrTipAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "既読位置の更新に失敗: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cursor != nil {
+		h.publisher.PublishRead(cursor)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Tipごとの未読数取得のハンドラー（GET /users/me/unread）
+func (h *ReadCursorHandler) GetMyUnreadCounts(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	counts, err := h.uc.GetUnreadCounts(r.Context(), domain.UserID(userID))
+	if err != nil {
+		http.Error(w, "未読数の取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToUnreadCountsResponse(counts)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,148 @@
+package rest
+
+// ここではHTTP経由（Rest API）の通報とモデレーション系のリクエストのハンドリングを行う
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+// 通報による非表示・非表示解除・削除のブロードキャストは、アウトボックスのリレー経由で行われる
+type ReportHandler struct {
+	uc usecase.ReportUsecase
+}
+
+// 通報のユースケースを注入するコンストラクタ関数
+func NewReportHandler(uc usecase.ReportUsecase) *ReportHandler {
+	return &ReportHandler{uc: uc}
+}
+
+// メッセージ通報のハンドラー（POST /reports）
+func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "リクエストボディが不正です", http.StatusBadRequest)
+		return
+	}
+	if req.MessageID == "" {
+		http.Error(w, "message_idが必要です", http.StatusBadRequest)
+		return
+	}
+
+	// REST APIはTipに紐づかないので、対象のTipの照合はしない（アクセス権限はメッセージのTipで確認する）
+	err := h.uc.ReportMessage(
+		r.Context(),
+		"",
+		domain.ReportID(uuid.New().String()),
+		domain.MessageID(req.MessageID),
+		domain.UserID(userID),
+		domain.ReportReason(req.Reason),
+		req.Detail,
+	)
+	if errors.Is(err, domain.ErrAlreadyReported) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrTipAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "メッセージの通報に失敗: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// 未対応の通報一覧取得のハンドラー（GET /admin/reports）
+func (h *ReportHandler) ListPendingReports(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	reports, err := h.uc.ListPendingReports(r.Context(), domain.UserID(userID))
+	if errors.Is(err, domain.ErrNotModerator) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "通報の取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToReportsResponse(reports)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// フィルター発動記録の取得件数のデフォルトと上限
+const (
+	defaultFilterLogLimit = 100
+	maxFilterLogLimit     = 1000
+)
+
+// コンテンツフィルターの発動記録取得のハンドラー（GET /admin/filter-logs?limit=）
+func (h *ReportHandler) ListFilterLogs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	limit := defaultFilterLogLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxFilterLogLimit {
+			http.Error(w, "limitが不正です", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	logs, err := h.uc.ListFilterLogs(r.Context(), domain.UserID(userID), limit)
+	if errors.Is(err, domain.ErrNotModerator) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "フィルター発動記録の取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToFilterLogsResponse(logs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// 通報解決のハンドラー（POST /admin/reports/{reportID}/resolve）
+func (h *ReportHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	reportID := chi.URLParam(r, "reportID")
+	if reportID == "" {
+		http.Error(w, "reportIDが必要です", http.StatusBadRequest)
+		return
+	}
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "リクエストボディが不正です", http.StatusBadRequest)
+		return
+	}
+
+	err := h.uc.ResolveReport(r.Context(), domain.ReportID(reportID), domain.UserID(userID), domain.ReportAction(req.Action))
+	switch {
+	case errors.Is(err, domain.ErrNotModerator):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrReportNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrReportAlreadyResolved):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "通報の解決に失敗: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -4,9 +4,12 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/minminseo/tipstar-chat-api/domain"
 	"github.com/minminseo/tipstar-chat-api/usecase"
 )
 
@@ -20,13 +23,23 @@ func NewOnlyRestMessageHandler(uc usecase.OnlyRestUsecase) *OnlyRestMessageHandl
 }
 
 // チャット履歴一覧取得のハンドラー
+// 非公開のTipの履歴は参加者しか閲覧できないので、閲覧者をX-User-Idヘッダーで受け取る
 func (h *OnlyRestMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
 	tipID := chi.URLParam(r, "tipID")
 	if tipID == "" {
 		http.Error(w, "tipIDが必要です", http.StatusBadRequest)
 		return
 	}
-	messages, err := h.uc.GetAllMessages(r.Context(), tipID)
+	messages, err := h.uc.GetAllMessages(r.Context(), tipID, userID)
+	if errors.Is(err, domain.ErrTipAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, "メッセージの取得に失敗: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -35,3 +48,35 @@ func (h *OnlyRestMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// メンション一覧の取得件数のデフォルトと上限
+const (
+	defaultMentionLimit = 50
+	maxMentionLimit     = 200
+)
+
+// 自分がメンションされたメッセージ一覧取得のハンドラー（GET /users/me/mentions?limit=）
+func (h *OnlyRestMessageHandler) GetMyMentions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	limit := defaultMentionLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxMentionLimit {
+			http.Error(w, "limitが不正です", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	mentions, err := h.uc.GetMentions(r.Context(), userID, limit)
+	if err != nil {
+		http.Error(w, "メンションの取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToMentionsResponse(mentions)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,144 @@
+package rest
+
+// ここではHTTP経由（Rest API）のWebhook管理系のリクエストのハンドリングを行う（モデレーターのみ。権限チェックはユースケース層で行う）
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+// 配信一覧の取得件数のデフォルトと上限
+const (
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 500
+)
+
+type WebhookHandler struct {
+	uc usecase.WebhookUsecase
+}
+
+// Webhookのユースケースを注入するコンストラクタ関数
+func NewWebhookHandler(uc usecase.WebhookUsecase) *WebhookHandler {
+	return &WebhookHandler{uc: uc}
+}
+
+// Webhook登録のハンドラー（POST /admin/webhooks）
+// 署名用の鍵はサーバーで生成し、このレスポンスでだけ返す
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "リクエストボディが不正です", http.StatusBadRequest)
+		return
+	}
+	var tipID *domain.TipID
+	if req.TipID != nil && *req.TipID != "" {
+		id := domain.TipID(*req.TipID)
+		tipID = &id
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		http.Error(w, "署名用の鍵の生成に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.uc.RegisterWebhook(r.Context(), domain.WebhookID(uuid.New().String()), tipID, req.URL, secret, domain.UserID(userID))
+	switch {
+	case errors.Is(err, domain.ErrNotModerator):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrInvalidWebhookURL):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Webhookの登録に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ToWebhookResponse(webhook, true))
+}
+
+// Webhook一覧取得のハンドラー（GET /admin/webhooks）
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	webhooks, err := h.uc.ListWebhooks(r.Context(), domain.UserID(userID))
+	if errors.Is(err, domain.ErrNotModerator) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Webhookの取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToWebhooksResponse(webhooks)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Webhook削除のハンドラー（DELETE /admin/webhooks/{webhookID}）
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	webhookID := chi.URLParam(r, "webhookID")
+	err := h.uc.DeleteWebhook(r.Context(), domain.WebhookID(webhookID), domain.UserID(userID))
+	switch {
+	case errors.Is(err, domain.ErrNotModerator):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Webhookの削除に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Webhookへの配信一覧（試行の履歴付き）取得のハンドラー（GET /admin/webhooks/{webhookID}/deliveries?limit=）
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	webhookID := chi.URLParam(r, "webhookID")
+	limit := defaultWebhookDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxWebhookDeliveryLimit {
+			http.Error(w, "limitが不正です", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.uc.ListDeliveries(r.Context(), domain.WebhookID(webhookID), domain.UserID(userID), limit)
+	switch {
+	case errors.Is(err, domain.ErrNotModerator):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "配信の取得に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := ToWebhookDeliveriesResponse(deliveries)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// 署名用の鍵（32バイトの乱数の16進数表記）を生成する
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
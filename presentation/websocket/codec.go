@@ -0,0 +1,123 @@
+package websocket
+
+// WebSocketのフレームのエンコード形式（コーデック）
+// クライアントは接続時にSec-WebSocket-Protocolでエンコード形式を選ぶ（選ばなかった場合はJSON）
+// モバイルクライアント向けに、JSONより小さいバイナリのMessagePackも選べる
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Sec-WebSocket-Protocolで指定するサブプロトコル名
+const (
+	SubprotocolJSON    = "chat.json.v1"
+	SubprotocolMsgpack = "chat.msgpack.v1"
+)
+
+// フレームのエンコードとデコードを行うインターフェース
+// リクエスト・レスポンスのモデル（model.go）はjsonタグだけを持ち、どのコーデックでも同じフィールド名になる
+type Codec interface {
+	// 対応するサブプロトコル名
+	Name() string
+	// WebSocketのフレームの種類（websocket.TextMessageまたはwebsocket.BinaryMessage）
+	MessageType() int
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	// エンコード済みのフレームを、配列1つとして書き込む（batchを合意したクライアント向け）
+	WriteBatch(w io.Writer, frames [][]byte) error
+}
+
+var (
+	jsonCodec    Codec = jsonFrameCodec{}
+	msgpackCodec Codec = msgpackFrameCodec{}
+)
+
+// サーバーが対応するサブプロトコル。クライアントが複数指定した場合はこの順で優先する
+var supportedSubprotocols = []string{SubprotocolJSON, SubprotocolMsgpack}
+
+// 昇格時に合意したサブプロトコルのコーデックを返す。サブプロトコルを指定しなかった（空文字）場合はJSON
+func codecForSubprotocol(subprotocol string) Codec {
+	if subprotocol == SubprotocolMsgpack {
+		return msgpackCodec
+	}
+	return jsonCodec
+}
+
+type jsonFrameCodec struct{}
+
+func (jsonFrameCodec) Name() string {
+	return SubprotocolJSON
+}
+
+func (jsonFrameCodec) MessageType() int {
+	return websocket.TextMessage
+}
+
+func (jsonFrameCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonFrameCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// 各フレームは1つのJSONの値なので、カンマで区切って[]で囲めばJSONの配列になる
+func (jsonFrameCodec) WriteBatch(w io.Writer, frames [][]byte) error {
+	sep := []byte("[")
+	for _, frame := range frames {
+		if _, err := w.Write(sep); err != nil {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		sep = []byte(",")
+	}
+	_, err := w.Write([]byte("]"))
+	return err
+}
+
+type msgpackFrameCodec struct{}
+
+func (msgpackFrameCodec) Name() string {
+	return SubprotocolMsgpack
+}
+
+func (msgpackFrameCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+// フィールド名とomitemptyはjsonタグから取る
+func (msgpackFrameCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackFrameCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// MessagePackの配列は要素数のヘッダーの後に要素を並べるので、エンコード済みのフレームをそのまま続けて書ける
+func (msgpackFrameCodec) WriteBatch(w io.Writer, frames [][]byte) error {
+	if err := msgpack.NewEncoder(w).EncodeArrayLen(len(frames)); err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package websocket
+
+// WebSocketのフレームのpermessage-deflate（RFC 7692）による圧縮
+// 再送や履歴を含む大きなフレームはよく縮むので、モバイルの通信量を減らすために圧縮する
+// 圧縮するかどうかはフレームごとに決め、しきい値より小さいフレームは圧縮しない
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 圧縮の設定
+type CompressionConfig struct {
+	Enabled   bool // クライアントが対応していればpermessage-deflateを合意する
+	Level     int  // 圧縮レベル（compress/flateのレベル）
+	Threshold int  // このバイト数未満のフレームは圧縮しない
+}
+
+// クライアントがSec-WebSocket-Extensionsでpermessage-deflateを提示しているかどうか
+// gorilla/websocketはEnableCompressionがtrueで、クライアントが提示していれば合意する
+func offersDeflate(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, ext := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(ext, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 圧縮したフレームの、圧縮前と圧縮後（フレームヘッダー込み）のサイズを記録する
+func observeCompression(payloadBytes int, wireBytes int64) {
+	compressionInputBytes.Add(float64(payloadBytes))
+	compressionOutputBytes.Add(float64(wireBytes))
+	if payloadBytes > 0 {
+		compressionRatio.Observe(float64(wireBytes) / float64(payloadBytes))
+	}
+}
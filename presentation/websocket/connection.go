@@ -2,21 +2,153 @@ package websocket
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
 )
 
 // 各接続クライアントのWebsocket接続を管理する構造体
 type Connection struct {
-	Conn       *websocket.Conn // 実際のWebSocket接続オブジェクト
-	UserID     string          // 接続クライアントを識別するためのユーザーID
-	Send       chan []byte     // 接続先へのブロードキャスト用チャネル
-	LastActive time.Time       // 最後にデータの送受信があった時刻
-	Ctx        context.Context // HTTPリクエストのContextを継承するフィールド
-	mu         sync.Mutex      // ブロードキャスト時の排他制御用
+	ID         string                          // ログの相関用に接続ごとに振るID
+	fixedTipID string                          // /ws/{tipID}で接続した場合のtipID。/wsで接続した場合は空（購読で参加するTipを選ぶ）
+	Conn       *websocket.Conn                 // 実際のWebSocket接続オブジェクト
+	UserID     string                          // 接続クライアントを識別するためのユーザーID
+	Send       chan []byte                     // 接続先へのブロードキャスト用チャネル
+	LastActive time.Time                       // 最後にデータの送受信があった時刻
+	Ctx        context.Context                 // HTTPリクエストのContextを継承するフィールド
+	codec      Codec                           // 昇格時にSec-WebSocket-Protocolで合意したフレームのエンコード形式
+	session    atomic.Pointer[protocolSession] // helloで合意したプロトコル。helloを受け取るまではnil（v1）
+	wire       *wireConn                       // Hijackした下の接続（圧縮率を数える）。Upgrader以外で昇格した場合はnil
+	config     ConnectionConfig                // 送信のバッチの設定
+	mu         sync.Mutex                      // 書き込み時の排他制御用（データフレームと、ReadPumpから返す制御フレーム）
+}
+
+// Connectionの設定
+type ConnectionConfig struct {
+	SendBufferSize int           // 送信チャネルのバッファ数
+	BatchMaxDelay  time.Duration // 最初のフレームを取り出してから、後続のフレームをまとめるために待つ最大時間（0なら溜まっている分だけまとめる）
+	BatchMaxFrames int           // 1回の書き込みにまとめる最大のフレーム数
+}
+
+// 昇格済みのWebSocket接続からConnectionを生成する
+// ctxにはHTTPリクエストのContextを渡す。接続中のログに付けるtip_id, user_id, connection_idをここでContextに入れる
+// tipIDには/ws/{tipID}で接続した場合のtipIDを、/wsで接続した場合は空文字を渡す
+// フレームのコーデックは昇格時に合意したサブプロトコルから決める
+func NewConnection(ctx context.Context, conn *websocket.Conn, userID string, tipID string, config ConnectionConfig) *Connection {
+	id := generateUUID()
+	if tipID != "" {
+		ctx = logging.WithTipID(ctx, tipID)
+	}
+	ctx = logging.WithUserID(ctx, userID)
+	ctx = logging.WithConnectionID(ctx, id)
+	c := &Connection{
+		ID:         id,
+		fixedTipID: tipID,
+		Conn:       conn,
+		UserID:     userID,
+		Send:       make(chan []byte, config.SendBufferSize),
+		LastActive: time.Now(),
+		Ctx:        ctx,
+		codec:      codecForSubprotocol(conn.Subprotocol()),
+		wire:       hijackedWireConn(conn),
+		config:     config,
+	}
+	conn.SetPingHandler(c.replyPing)
+	conn.SetCloseHandler(c.replyClose)
+	return c
+}
+
+// 制御フレームの書き込みの期限（gorilla/websocketの既定のハンドラーと同じ）
+const controlWriteWait = time.Second
+
+// pingにpongを返す（ReadPumpのゴルーチンから呼ばれる）
+// 既定のハンドラーはWritePumpと無関係に書き込むので、WritePumpと同じmuで排他してデータフレームの書き込みの間に割り込ませない
+func (c *Connection) replyPing(appData string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.Conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(controlWriteWait))
+	var netErr net.Error
+	if errors.Is(err, websocket.ErrCloseSent) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return nil // 切断中やpongが書き込めなかった場合も、読み取りは続ける
+	}
+	return err
+}
+
+// closeに同じステータスコードのcloseを返す（ReadPumpのゴルーチンから呼ばれる）
+func (c *Connection) replyClose(code int, text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(controlWriteWait))
+	return nil
+}
+
+// Upgraderで昇格した接続ならHijackしたwireConnを返す
+func hijackedWireConn(conn *websocket.Conn) *wireConn {
+	if wc, ok := conn.NetConn().(*wireConn); ok {
+		return wc
+	}
+	return nil
+}
+
+// /ws/{tipID}で1つのTipに固定された接続ならそのtipIDを、/wsで接続した場合は空文字を返す
+func (c *Connection) FixedTipID() string {
+	return c.fixedTipID
+}
+
+// Room.Broadcastから呼ばれる。チャネルがブロックしている場合は送信しない
+func (c *Connection) deliver(frame roomFrame) bool {
+	select {
+	case c.Send <- frame.data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Connection) memberUserID() string {
+	return c.UserID
+}
+
+func (c *Connection) close() {
+	c.Conn.Close()
+}
+
+func (c *Connection) frameCodec() Codec {
+	return c.codec
+}
+
+func (c *Connection) frameVersion() int {
+	return c.ProtocolVersion()
+}
+
+// helloで合意したプロトコルのバージョン。helloを受け取っていなければv1
+func (c *Connection) ProtocolVersion() int {
+	if s := c.session.Load(); s != nil {
+		return s.version
+	}
+	return ProtocolV1
+}
+
+// helloで合意した機能かどうか
+func (c *Connection) HasCapability(capability string) bool {
+	s := c.session.Load()
+	return s != nil && s.capabilities[capability]
+}
+
+// helloで合意した内容を記録する。既に合意済みの場合は何もせずfalseを返す
+// ReadPumpとRoom.Broadcastから並行して読まれるので、一度だけ差し替える
+func (c *Connection) startSession(version int, capabilities []string) bool {
+	s := &protocolSession{version: version, capabilities: make(map[string]bool, len(capabilities))}
+	for _, capability := range capabilities {
+		s.capabilities[capability] = true
+	}
+	return c.session.CompareAndSwap(nil, s)
 }
 
 // Connectionに紐づくContextを取得するメソッド
@@ -24,17 +156,34 @@ func (c *Connection) Context() context.Context {
 	return c.Ctx
 }
 
+// この接続クライアントにだけメッセージを送信する（ackなど送信者本人への応答用）
+// messageはmodel.goの応答用モデルで、接続のプロトコルのバージョンに合わせた形にしてコーデックでエンコードする
+// Room.Broadcastと同じく、チャネルがブロックしている場合は送信しない
+func (c *Connection) Reply(message any) {
+	bMsg, err := c.codec.Marshal(adaptFrame(c.ProtocolVersion(), 0, message))
+	if err != nil {
+		slog.ErrorContext(c.Ctx, "Reply: 応答メッセージのエンコードに失敗", "error", err)
+		return
+	}
+	select {
+	case c.Send <- bMsg:
+	default:
+		droppedFrames.WithLabelValues("reply").Inc()
+		slog.WarnContext(c.Ctx, "Reply: 送信チャネルが詰まっているためメッセージを破棄")
+	}
+}
+
 // 接続クライアントからのメッセージを読取るための関数（ループを使ってこれを実現する）
 // 外部から渡されたhandler（コールバック）を呼び出す
 func (c *Connection) ReadPump(handler func(msg []byte, c *Connection)) {
 	// 無限ループさせてクライアントからのメッセージを受信し続ける
 	// クライアント側が切断した場合、err != nilはtrueになり、ループを抜ける
-	// その後router.goでLeaveメソッドが実行される
+	// その後router.goでHub.Disconnectが実行される
 	for {
 		_, message, err := c.Conn.ReadMessage()
 
 		if err != nil {
-			log.Printf("ReadPump: メッセージ読取りエラー: %v", err)
+			slog.InfoContext(c.Ctx, "ReadPump: メッセージ読取りエラー", "error", err)
 			break
 		}
 		c.LastActive = time.Now() // アクティビティ更新
@@ -44,19 +193,113 @@ func (c *Connection) ReadPump(handler func(msg []byte, c *Connection)) {
 
 // 送信ループでSendチャネルに流し込まれるメッセージを取り出す→ブロードキャスト
 // Sendチャネルにブロードキャスト用メッセージが送信されたら（代入されたら）、接続先（c.Conn）に書き込みクライアントへブロードキャスト
+// 賑やかなRoomでフレームごとに書き込む（システムコールを呼ぶ）と重いので、batchを合意したクライアントにはSendに溜まっているフレームをまとめて書き込む
 func (c *Connection) WritePump() {
 	defer func() {
 		c.Conn.Close()
 	}()
+	batch := make([][]byte, 0, c.config.BatchMaxFrames)
 	for msg := range c.Send {
+		batch = c.collectBatch(append(batch[:0], msg))
 
 		// 書き込み時は排他制御する
 		// 排他制御しないと、同一の共有リソースに対して同時に書き込みをしてしまいデータ競合が起こる
 		c.mu.Lock()
-		err := c.Conn.WriteMessage(websocket.TextMessage, msg)
+		err := c.writeBatch(batch)
 		c.mu.Unlock()
 		if err != nil {
 			break
 		}
 	}
 }
+
+// Sendに届いたフレームを、BatchMaxFramesに達するかBatchMaxDelayが経過するまでbatchに追加する
+// 最初のフレームが届いてからBatchMaxDelayより長くは待たないので、これが配信の遅延の上限になる
+func (c *Connection) collectBatch(batch [][]byte) [][]byte {
+	var deadline <-chan time.Time
+	if c.config.BatchMaxDelay > 0 {
+		timer := time.NewTimer(c.config.BatchMaxDelay)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for len(batch) < c.config.BatchMaxFrames {
+		// 溜まっている分は待たずに取り出す
+		select {
+		case msg := <-c.Send:
+			batch = append(batch, msg)
+			continue
+		default:
+		}
+		if deadline == nil {
+			return batch
+		}
+		select {
+		case msg := <-c.Send:
+			batch = append(batch, msg)
+		case <-deadline:
+			return batch
+		}
+	}
+	return batch
+}
+
+// まとめたフレームを書き込む
+// helloでbatchを合意したクライアントには配列1つのフレームにして送り、それ以外のクライアントにはフレームを1つずつ送る
+func (c *Connection) writeBatch(batch [][]byte) error {
+	writeBatchFrames.Observe(float64(len(batch)))
+	if len(batch) > 1 && c.HasCapability(CapabilityBatch) {
+		return c.writeArrayFrame(batch)
+	}
+	for _, msg := range batch {
+		if err := c.writeFrame(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// フレームを1つ書き込む
+func (c *Connection) writeFrame(msg []byte) error {
+	return c.writeCompressible(len(msg), func() error {
+		return c.Conn.WriteMessage(c.codec.MessageType(), msg)
+	})
+}
+
+// まとめたフレームを、配列1つのフレームとしてNextWriterで書き込む（各フレームはエンコード済みなので、エンコードし直さない）
+func (c *Connection) writeArrayFrame(batch [][]byte) error {
+	size := 0
+	for _, msg := range batch {
+		size += len(msg)
+	}
+	return c.writeCompressible(size, func() error {
+		w, err := c.Conn.NextWriter(c.codec.MessageType())
+		if err != nil {
+			return err
+		}
+		if err := c.codec.WriteBatch(w, batch); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	})
+}
+
+// permessage-deflateを合意した接続では、しきい値以上のフレームだけを圧縮する
+// 書き込み（pong・closeの制御フレームを含む）はmuで排他しているので、書き込み前後のwireConnのバイト数の差がこのフレームの圧縮後のサイズになる
+func (c *Connection) writeCompressible(size int, write func() error) error {
+	if c.wire == nil || !c.wire.deflate {
+		return write()
+	}
+	compress := size >= c.wire.threshold
+	c.Conn.EnableWriteCompression(compress)
+	before := c.wire.written.Load()
+	if err := write(); err != nil {
+		return err
+	}
+	if compress {
+		observeCompression(size, c.wire.written.Load()-before)
+	} else {
+		compressionSkippedFrames.Inc()
+	}
+	return nil
+}
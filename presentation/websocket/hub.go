@@ -1,50 +1,201 @@
 package websocket
 
 import (
-	"log"
+	"errors"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// 購読数の上限に達している接続がさらに購読しようとした場合のエラー
+var ErrSubscriptionLimit = errors.New("購読できるTipの数の上限に達しています")
+
 // 全てのRoomを管理するHub構造体
 // 各RoomはtipIDをキーとして持ち、Hub経由で動的に送信と受信を行う
+// 1つの接続が複数のRoomに参加できるので、接続ごとに参加しているRoomもHubで管理する
+// 接続はWebSocketの接続とSSEの購読者の両方（RoomMember）
 type Hub struct {
-	Rooms map[string]*Room // キーは各Roomに対応するtipID
-	mu    sync.RWMutex
+	Rooms   map[string]*Room                // キーは各Roomに対応するtipID
+	members map[RoomMember]map[string]*Room // 接続ごとに参加しているRoom（キーはtipID）
+	mu      sync.RWMutex
+	running atomic.Bool // Runのループが動いている間true（準備完了の確認用）
+	config  HubConfig
+}
+
+// HubとRoom、Connectionの設定
+type HubConfig struct {
+	RoomIdleTimeout  time.Duration // この時間アクティビティが無いRoomの接続を閉じる
+	SweepInterval    time.Duration // アイドリングしたRoomを掃除する間隔
+	SendBufferSize   int           // 接続ごとの送信チャネルのバッファ数
+	MaxSubscriptions int           // 1つの接続が同時に購読できるTipの数
+	BatchMaxDelay    time.Duration // 接続の送信で、後続のフレームをまとめるために待つ最大時間
+	BatchMaxFrames   int           // 接続の送信で、1回の書き込みにまとめる最大のフレーム数
 }
 
 // Hubをインスタンス化する関数
-func NewHub() *Hub {
+func NewHub(config HubConfig) *Hub {
 	return &Hub{
-		Rooms: make(map[string]*Room),
+		Rooms:   make(map[string]*Room),
+		members: make(map[RoomMember]map[string]*Room),
+		config:  config,
 	}
 }
 
+// 接続ごとの送信チャネルのバッファ数（SSEとロングポーリングの購読者の生成時とwelcomeで使う）
+func (h *Hub) SendBufferSize() int {
+	return h.config.SendBufferSize
+}
+
+// Connectionの設定（Connectionの生成時に使う）
+func (h *Hub) ConnectionConfig() ConnectionConfig {
+	return ConnectionConfig{
+		SendBufferSize: h.config.SendBufferSize,
+		BatchMaxDelay:  h.config.BatchMaxDelay,
+		BatchMaxFrames: h.config.BatchMaxFrames,
+	}
+}
+
+// 1つの接続が同時に購読できるTipの数（welcomeで返す）
+func (h *Hub) MaxSubscriptions() int {
+	return h.config.MaxSubscriptions
+}
+
 // tipIDに対応するRoomを取得し、そのRoomが存在しなければ新しくインスタンス化しHubの管理下（Roomsマップ）に登録
-func (h *Hub) GetRoom(tipID string) *Room {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// Roomを作るのは接続がそのTipに参加する時（Subscribe）だけにし、クライアントが送ってきた任意のtipIDで空のRoomが増えないようにする
+// h.muをロックした状態で呼び出す
+func (h *Hub) getRoomLocked(tipID string) *Room {
 	room, ok := h.Rooms[tipID]
 	if !ok {
-		room = NewRoom(tipID)
+		room = NewRoom(tipID, h.config.RoomIdleTimeout)
 		h.Rooms[tipID] = room
 	}
 	return room
 }
 
-// CheckIdleConnections関数を呼び出し、5分毎にアイドリング状態のRoomをチェックしConnectionが0なったRoomを削除
+// 接続をHubの管理下に登録する（まだどのRoomにも参加していない状態）
+func (h *Hub) Register(c RoomMember) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.members[c]; !ok {
+		h.members[c] = make(map[string]*Room)
+	}
+}
+
+// 接続をtipIDのRoomに参加させる。既に参加している場合は何もしない
+// 購読数の上限に達している場合はErrSubscriptionLimitを返す
+func (h *Hub) Subscribe(c RoomMember, tipID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rooms, ok := h.members[c]
+	if !ok {
+		rooms = make(map[string]*Room)
+		h.members[c] = rooms
+	}
+	if _, ok := rooms[tipID]; ok {
+		return nil
+	}
+	if len(rooms) >= h.config.MaxSubscriptions {
+		return ErrSubscriptionLimit
+	}
+	room := h.getRoomLocked(tipID)
+	room.Join(c)
+	rooms[tipID] = room
+	return nil
+}
+
+// 接続をtipIDのRoomから外す。参加していなかった場合はfalseを返す
+// 空になったRoomはRunの掃除で削除する
+func (h *Hub) Unsubscribe(c RoomMember, tipID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.members[c][tipID]
+	if !ok {
+		return false
+	}
+	room.Leave(c)
+	delete(h.members[c], tipID)
+	return true
+}
+
+// 接続がtipIDのRoomに参加しているかどうか
+func (h *Hub) IsSubscribed(c RoomMember, tipID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.members[c][tipID]
+	return ok
+}
+
+// 接続が参加しているRoomのtipID（昇順）
+func (h *Hub) Subscriptions(c RoomMember) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	tipIDs := make([]string, 0, len(h.members[c]))
+	for tipID := range h.members[c] {
+		tipIDs = append(tipIDs, tipID)
+	}
+	sort.Strings(tipIDs)
+	return tipIDs
+}
+
+// 切断された接続を全てのRoomから外し、Hubの管理下から削除してCloseする
+func (h *Hub) Disconnect(c RoomMember) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, room := range h.members[c] {
+		room.Leave(c)
+	}
+	delete(h.members, c)
+	c.close()
+}
+
+// 引数のユーザーがtipIDのRoomに接続しているかどうか。Roomが存在しなければfalse
+func (h *Hub) TipHasUser(tipID string, userID string) bool {
+	h.mu.RLock()
+	room, ok := h.Rooms[tipID]
+	h.mu.RUnlock()
+	return ok && room.HasUser(userID)
+}
+
+// Hubが管理しているRoom数（メトリクス用）
+func (h *Hub) RoomCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.Rooms)
+}
+
+// Hubに登録されている接続数（メトリクス用）。複数のRoomに参加している接続も1つと数える。SSEの購読者も含む
+func (h *Hub) ConnectionCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.members)
+}
+
+// Runのループが動いているかどうか（準備完了の確認用）
+func (h *Hub) IsRunning() bool {
+	return h.running.Load()
+}
+
+// CheckIdleConnections関数を呼び出し、SweepInterval毎にアイドリング状態のRoomをチェックしConnectionが0なったRoomを削除
+// 閉じるのは/ws/{tipID}で1つのTipに固定された接続だけ。/wsで複数のTipを購読している接続は、他のTipのために開いたままにする
 func (h *Hub) Run() {
-	ticker := time.NewTicker(5 * time.Minute)
+	h.running.Store(true)
+	defer h.running.Store(false)
+	ticker := time.NewTicker(h.config.SweepInterval)
 	defer ticker.Stop()
 	for range ticker.C {
 		h.mu.Lock()
 		for tipID, room := range h.Rooms {
-			room.CheckIdleConnections()
+			for _, c := range room.CheckIdleConnections() {
+				delete(h.members[c], tipID)
+				c.close()
+			}
 			if room.IsEmpty() {
 				delete(h.Rooms, tipID)
 			}
 		}
 		h.mu.Unlock()
-		log.Println("Hub: 5分間アイドリングしたRoomを削除")
+		slog.Debug("Hub: アイドリングしたRoomを削除")
 	}
 }
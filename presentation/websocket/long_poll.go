@@ -0,0 +1,168 @@
+package websocket
+
+// WebSocketもSSEも使えないクライアント（組み込み機器やCLIのボット等）向けのロングポーリング
+// 購読者（StreamSubscriber）をRoomMemberとしてHubに登録し、WebSocketのブロードキャストと同じイベントで起こされる
+
+/*
+処理の流れ
+1. Tipの履歴を閲覧できるか確認し、購読者をHubに登録してTipのRoomに参加させる（ここからライブのイベントが溜まり始める）
+2. sinceがあれば、それより後のイベントをアウトボックスから取得し、あればすぐに返す（前回の応答から次のリクエストまでの取りこぼし）
+3. 無ければ、Roomにイベントがブロードキャストされるかタイムアウトするまで待ち、届いたイベントを返す
+4. クライアントは応答のcursorを次のリクエストのsinceに指定する
+   イベントのidとcursorにはリレーがコミット順に振った連番を使うので、sinceより後にコミットされたイベントは全て連番も大きい
+
+sinceを省略した場合はリクエストの時点から待つので、それまでの履歴はGET /messages/{tipID}で取得しておく。
+
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+// ロングポーリングの設定
+type LongPollConfig struct {
+	Timeout   time.Duration // イベントが届かない場合にリクエストを保留しておく時間の上限
+	MaxEvents int           // 1回の応答で返すイベント数の上限
+}
+
+type LongPollHandler struct {
+	uc           usecase.EventStreamUsecase
+	hub          *Hub
+	config       LongPollConfig
+	shutdown     chan struct{} // サーバーの停止時に閉じ、保留中のリクエストに空の応答を返させる
+	shutdownOnce sync.Once
+}
+
+// ユースケースとHubを注入するコンストラクタ関数
+func NewLongPollHandler(uc usecase.EventStreamUsecase, hub *Hub, config LongPollConfig) *LongPollHandler {
+	return &LongPollHandler{
+		uc:       uc,
+		hub:      hub,
+		config:   config,
+		shutdown: make(chan struct{}),
+	}
+}
+
+// 保留中のリクエストに応答させる（http.Server.RegisterOnShutdownに登録する）
+func (h *LongPollHandler) Shutdown() {
+	h.shutdownOnce.Do(func() { close(h.shutdown) })
+}
+
+// 新しいイベントの待ち受けのハンドラー（GET /messages/{tipID}/poll?since=）
+// タイムアウトした場合はeventsが空の応答を返す
+func (h *LongPollHandler) Poll(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	tipID := chi.URLParam(r, "tipID")
+	if tipID == "" {
+		http.Error(w, "tipIDが必要です", http.StatusBadRequest)
+		return
+	}
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "sinceが不正です", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+	err := h.uc.AuthorizeJoin(r.Context(), domain.TipID(tipID), domain.UserID(userID))
+	if errors.Is(err, domain.ErrTipAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Tipへのアクセス権限の確認に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// アウトボックスを確認するより先にRoomに参加して、確認中に発生したイベントも取りこぼさないようにする
+	sub := newStreamSubscriber(r.Context(), userID, tipID, h.hub.SendBufferSize())
+	h.hub.Register(sub)
+	if err := h.hub.Subscribe(sub, tipID); err != nil {
+		h.hub.Disconnect(sub)
+		http.Error(w, "Roomへの参加に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer h.hub.Disconnect(sub)
+
+	// cursorは返したイベントの連番の最大値。ライブでこれ以下のイベントが届いたら取得済みなので返さない
+	resp := &PollResponse{Events: []PollEvent{}, Cursor: since}
+	if since > 0 {
+		events, err := h.uc.FetchEventsAfter(sub.Ctx, domain.TipID(tipID), since, h.config.MaxEvents)
+		if err != nil {
+			http.Error(w, "イベントの取得に失敗: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, event := range events {
+			data, err := encodeOutboxEvent(event)
+			if err != nil {
+				slog.WarnContext(sub.Ctx, "LongPoll: 返せないイベントを飛ばします", "event_id", event.ID, "event_seq", event.Seq, "error", err)
+			} else {
+				resp.Events = append(resp.Events, PollEvent{ID: event.Seq, Data: data})
+			}
+			resp.Cursor = max(resp.Cursor, event.Seq)
+		}
+	}
+	if len(resp.Events) == 0 {
+		h.wait(r, sub, resp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Roomにイベントが届くかタイムアウトするまで待ち、届いたイベントをrespに入れる
+// 最初のイベントで起こされたら、その時点でチャネルに溜まっているイベントもまとめて返す
+func (h *LongPollHandler) wait(r *http.Request, sub *StreamSubscriber, resp *PollResponse) {
+	timeout := time.NewTimer(h.config.Timeout)
+	defer timeout.Stop()
+	for len(resp.Events) == 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.done:
+			return
+		case <-h.shutdown:
+			return
+		case <-timeout.C:
+			return
+		case frame := <-sub.frames:
+			appendPollEvent(resp, frame)
+		}
+	}
+	for len(resp.Events) < h.config.MaxEvents {
+		select {
+		case frame := <-sub.frames:
+			appendPollEvent(resp, frame)
+		default:
+			return
+		}
+	}
+}
+
+// アウトボックスから取得済みのイベント（連番がcursor以下）は飛ばす
+// cursorは最後に受け取ったものではなく、受け取った連番の最大値にする
+func appendPollEvent(resp *PollResponse, frame roomFrame) {
+	if frame.eventID != 0 && frame.eventID <= resp.Cursor {
+		return
+	}
+	resp.Events = append(resp.Events, PollEvent{ID: frame.eventID, Data: frame.data})
+	resp.Cursor = max(resp.Cursor, frame.eventID)
+}
@@ -9,14 +9,23 @@ import (
 
 // ユーザーIDは、WSRequestMessage 内のものではなく、接続時に取得した conn.UserID を使用する。
 func ToSendDomainFromWSRequest(req *WSRequestMessage, connUserID string) (*domain.Message, error) {
-	return domain.NewMessage(
+	var attachmentIDs []domain.AttachmentID
+	for _, id := range req.AttachmentIDs {
+		attachmentIDs = append(attachmentIDs, domain.AttachmentID(id))
+	}
+	msg, err := domain.NewMessage(
 		domain.MessageID(generateUUID()), // 新規送信なので新たに生成
 		domain.TipID(req.TipID),
 		domain.UserID(connUserID), // ヘッダーからのユーザーIDを使用
 		req.Content,
-
+		attachmentIDs,
 		true, // 送信者は自分としてフラグを立てる
 	)
+	if err != nil {
+		return nil, err
+	}
+	msg.ClientMsgID = req.ClientMsgID
+	return msg, nil
 }
 
 // user_id は引数 connUserID から取得します。MessageID 必須。
@@ -54,6 +63,21 @@ func ToBroadcastMessage(msg *domain.Message) *WSBroadcastMessage {
 		TipID:     string(msg.TipID),
 		Content:   msg.Content,
 		Timestamp: ts,
+		Mentions:  toUserIDStrings(msg.Mentions),
+		Version:   msg.Version,
+
+		AttachmentIDs: toAttachmentIDStrings(msg.AttachmentIDs),
+	}
+}
+
+func ToAckMessage(msg *domain.Message, duplicate bool) *AckMessage {
+	return &AckMessage{
+		Type:        "ack",
+		ClientMsgID: msg.ClientMsgID,
+		MessageID:   string(msg.ID),
+		TipID:       string(msg.TipID),
+		Timestamp:   msg.CreatedAt.Unix(),
+		Duplicate:   duplicate,
 	}
 }
 
@@ -64,6 +88,19 @@ func ToEditBroadcastMessage(msg *domain.Message) *EditBroadcastMessage {
 		TipID:      string(msg.TipID),
 		NewContent: msg.Content, // 編集後の内容。必要に応じて更新済みの値を利用
 		EditedAt:   msg.UpdatedAt.Unix(),
+		Mentions:   toUserIDStrings(msg.Mentions),
+		Version:    msg.Version,
+	}
+}
+
+func ToConflictMessage(operation string, tipID string, conflict *domain.VersionConflictError) *ConflictMessage {
+	return &ConflictMessage{
+		Type:           "conflict",
+		Operation:      operation,
+		MessageID:      string(conflict.MessageID),
+		TipID:          tipID,
+		CurrentVersion: conflict.CurrentVersion,
+		CurrentContent: conflict.CurrentContent,
 	}
 }
 
@@ -80,6 +117,108 @@ func ToDeleteBroadcastMessage(msg *domain.Message) *DeleteBroadcastMessage {
 	}
 }
 
+func ToHideBroadcastMessage(msg *domain.Message) *HideBroadcastMessage {
+	var hiddenAt int64
+	if msg.HiddenAt != nil {
+		hiddenAt = msg.HiddenAt.Unix()
+	}
+	return &HideBroadcastMessage{
+		Type:      "hide",
+		MessageID: string(msg.ID),
+		TipID:     string(msg.TipID),
+		HiddenAt:  hiddenAt,
+	}
+}
+
+func ToUnhideBroadcastMessage(msg *domain.Message) *UnhideBroadcastMessage {
+	return &UnhideBroadcastMessage{
+		Type:      "unhide",
+		MessageID: string(msg.ID),
+		TipID:     string(msg.TipID),
+		Content:   msg.Content,
+	}
+}
+
+func ToReadBroadcastMessage(cursor *domain.ReadCursor) *ReadBroadcastMessage {
+	return &ReadBroadcastMessage{
+		Type:      "read",
+		TipID:     string(cursor.TipID),
+		UserID:    string(cursor.UserID),
+		MessageID: string(cursor.LastReadMessageID),
+		ReadAt:    cursor.UpdatedAt.Unix(),
+	}
+}
+
+func ToPinBroadcastMessage(pin *domain.Pin) *PinBroadcastMessage {
+	return &PinBroadcastMessage{
+		Type:      "pin",
+		TipID:     string(pin.TipID),
+		MessageID: string(pin.MessageID),
+		Position:  pin.Position,
+		PinnedBy:  string(pin.PinnedBy),
+		PinnedAt:  pin.PinnedAt.Unix(),
+	}
+}
+
+func ToUnpinBroadcastMessage(tipID domain.TipID, messageID domain.MessageID) *UnpinBroadcastMessage {
+	return &UnpinBroadcastMessage{
+		Type:      "unpin",
+		TipID:     string(tipID),
+		MessageID: string(messageID),
+	}
+}
+
+func toUserIDStrings(ids []domain.UserID) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, string(id))
+	}
+	return res
+}
+
+func toAttachmentIDStrings(ids []domain.AttachmentID) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, string(id))
+	}
+	return res
+}
+
 func generateUUID() string {
 	return uuid.New().String()
 }
+
+func ToSubscriptionMessage(messageType string, tipID string, subscriptions []string) *SubscriptionMessage {
+	return &SubscriptionMessage{
+		Type:          messageType,
+		TipID:         tipID,
+		Subscriptions: subscriptions,
+	}
+}
+
+func ToErrorMessage(operation string, tipID string, code string, message string) *ErrorMessage {
+	return &ErrorMessage{
+		Type:      "error",
+		Operation: operation,
+		TipID:     tipID,
+		Code:      code,
+		Message:   message,
+	}
+}
+
+func ToWelcomeMessage(sessionID string, version int, capabilities []string, limits WelcomeLimits, serverVersion string) *WelcomeMessage {
+	return &WelcomeMessage{
+		Type:          "welcome",
+		Version:       version,
+		SessionID:     sessionID,
+		Capabilities:  capabilities,
+		Limits:        limits,
+		ServerVersion: serverVersion,
+	}
+}
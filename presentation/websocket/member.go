@@ -0,0 +1,25 @@
+package websocket
+
+// Roomに参加してブロードキャストを受け取るもの
+// WebSocketの接続（*Connection）と、SSEやロングポーリングの購読者（*StreamSubscriber）がある
+// メソッドを非公開にしているので、このパッケージの外では実装できない
+type RoomMember interface {
+	// ブロードキャストを送信キューに入れる。キューが詰まっていて入れられなければfalseを返す
+	deliver(frame roomFrame) bool
+	// Roomに参加しているユーザーのID（メンションの通知先の判定用）
+	memberUserID() string
+	// 1つのTipに固定されていればそのtipID、そうでなければ空文字（固定されたメンバーだけがアイドリングしたRoomの掃除で閉じられる）
+	FixedTipID() string
+	// Hubから外したあとに接続を閉じる
+	close()
+	// ブロードキャストをエンコードするコーデック（Roomはコーデックごとに1回だけエンコードする）
+	frameCodec() Codec
+	// フレームの形を決めるプロトコルのバージョン
+	frameVersion() int
+}
+
+// Roomがメンバーに配るブロードキャスト1つ分
+type roomFrame struct {
+	eventID int64  // アウトボックスのイベントの連番（SSEのidに使う）。アウトボックスを経由しないブロードキャストは0
+	data    []byte // メンバーのコーデックでエンコードしたブロードキャスト
+}
@@ -0,0 +1,76 @@
+package websocket
+
+// WebSocketのメトリクス（/metricsで公開する）
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Room.Broadcastで全クライアントのSendチャネルに流し込むまでにかかった時間
+	broadcastDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tipstar_ws_broadcast_duration_seconds",
+		Help:    "Room内の全クライアントへのブロードキャストのファンアウトにかかった時間",
+		Buckets: []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+	})
+
+	// Sendチャネルが詰まっていて送れなかったフレーム数
+	droppedFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tipstar_ws_dropped_frames_total",
+		Help: "Sendチャネルが詰まっていたために破棄したフレーム数",
+	}, []string{"source"}) // "broadcast"（Room.Broadcast）または "reply"（Connection.Reply）
+
+	// permessage-deflateで圧縮したフレームの、圧縮後のサイズ（フレームヘッダー込み）÷圧縮前のサイズ
+	compressionRatio = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tipstar_ws_compression_ratio",
+		Help:    "圧縮したフレームの圧縮後のサイズと圧縮前のサイズの比",
+		Buckets: []float64{.05, .1, .2, .3, .4, .5, .6, .7, .8, .9, 1, 1.1},
+	})
+
+	// 全体の圧縮率はcompression_output_bytes÷compression_input_bytesで求める
+	compressionInputBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tipstar_ws_compression_input_bytes_total",
+		Help: "圧縮したフレームの圧縮前のバイト数",
+	})
+	compressionOutputBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tipstar_ws_compression_output_bytes_total",
+		Help: "圧縮したフレームの圧縮後のバイト数（フレームヘッダー込み）",
+	})
+
+	// permessage-deflateを合意した接続で、しきい値未満のため圧縮しなかったフレーム数
+	compressionSkippedFrames = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tipstar_ws_compression_skipped_frames_total",
+		Help: "しきい値未満のため圧縮しなかったフレーム数",
+	})
+
+	// WritePumpが1回の書き込みにまとめたフレーム数
+	writeBatchFrames = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tipstar_ws_write_batch_frames",
+		Help:    "WritePumpが1回の書き込みにまとめたフレーム数",
+		Buckets: []float64{1, 2, 4, 8, 16, 32, 64, 128},
+	})
+
+	// Room.Broadcastでのエンコード回数（Roomにいるクライアントのフレームの形式の種類数だけ増える）
+	broadcastEncodes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tipstar_ws_broadcast_encodes_total",
+		Help: "ブロードキャストをコーデックごとにエンコードした回数",
+	}, []string{"codec"})
+)
+
+// Hubの接続数とRoom数のメトリクスを登録する（スクレイプのたびにHubから数える）
+func RegisterHubMetrics(reg prometheus.Registerer, hub *Hub) error {
+	connections := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tipstar_ws_active_connections",
+		Help: "接続中のWebSocketクライアント数",
+	}, func() float64 { return float64(hub.ConnectionCount()) })
+	rooms := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tipstar_ws_rooms",
+		Help: "Hubが管理しているRoom数",
+	}, func() float64 { return float64(hub.RoomCount()) })
+
+	if err := reg.Register(connections); err != nil {
+		return err
+	}
+	return reg.Register(rooms)
+}
@@ -1,40 +1,183 @@
 package websocket
 
+import "encoding/json"
+
 // WSRequestMessage は、クライアントから送信されるWebSocketリクエストメッセージのモデルです。
-// 新規送信、編集、削除いずれの場合も、この形式で受信します。
-// 例：type "send", "edit", "delete"
+// 新規送信、編集、削除、通報、既読、ピン留めいずれの場合も、この形式で受信します。
+// 例：type "hello", "send", "edit", "delete", "report", "mark_read", "pin", "unpin", "subscribe", "unsubscribe"
+// subscribe・unsubscribeは/wsで接続した場合に、tip_idのTipの購読を開始・終了します。
+// helloは接続直後に1回だけ送り、プロトコルのバージョンと使いたい機能をサーバーと合意します（送らない場合はv1）。
 type WSRequestMessage struct {
-	Type      string `json:"type"`       // "send", "edit", "delete"
-	MessageID string `json:"message_id"` // 新規の場合は空。編集・削除の場合は既存のID
-	TipID     string `json:"tip_id"`     // 対象チャットルームのID
-	Content   string `json:"content"`    // メッセージ内容（送信の場合はメッセージ全文、編集の場合は新しい内容。削除では無視）
-	UserID    string `json:"user_id"`    // クライアントから送信されるユーザーID
+	Type      string `json:"type"`             // "hello", "send", "edit", "delete", "report", "mark_read", "pin", "unpin", "subscribe", "unsubscribe"
+	MessageID string `json:"message_id"`       // 新規の場合は空。それ以外の場合は対象の既存のID
+	TipID     string `json:"tip_id"`           // 対象チャットルームのID
+	Content   string `json:"content"`          // メッセージ内容（送信の場合はメッセージ全文、編集の場合は新しい内容、通報の場合は補足説明。削除では無視）
+	UserID    string `json:"user_id"`          // クライアントから送信されるユーザーID
+	Reason    string `json:"reason,omitempty"` // 通報理由のカテゴリ（"spam", "harassment", "inappropriate", "other"）。通報以外では無視
+
+	AttachmentIDs []string `json:"attachment_ids,omitempty"` // 送信の場合に添付するアップロード済みファイルのID。送信以外では無視
+	ClientMsgID   string   `json:"client_msg_id,omitempty"`  // 送信の場合にクライアントが生成する識別子。同じ値で再送しても二重に保存されない。送信以外では無視
+
+	ExpectedVersion int64 `json:"expected_version,omitempty"` // 編集・削除の場合に、クライアントが持っている対象メッセージのバージョン。省略時は検証しない
+
+	Version      int      `json:"version,omitempty"`      // helloの場合に、クライアントが話すプロトコルのバージョン。hello以外では無視
+	Capabilities []string `json:"capabilities,omitempty"` // helloの場合に、クライアントが使いたい機能（"subscribe", "pins", "read_receipts", "reports", "batch"）。hello以外では無視
+}
+
+// WelcomeMessage は、helloへの応答として合意した内容を送信者本人にだけ返す際に使用するモデルです。
+// v2以上で合意した場合は、このフレームからWSFrameV2に包んで送ります。
+type WelcomeMessage struct {
+	Type          string        `json:"type"`           // 固定で "welcome"
+	Version       int           `json:"version"`        // 合意したプロトコルのバージョン（クライアントが要求したものより古い場合がある）
+	SessionID     string        `json:"session_id"`     // 接続ごとのID（サーバーのログのconnection_idと同じ）
+	Capabilities  []string      `json:"capabilities"`   // 要求された機能のうち、サーバーが対応しているもの
+	Limits        WelcomeLimits `json:"limits"`         // この接続の制限
+	ServerVersion string        `json:"server_version"` // サーバーのバージョン
+}
+
+// WelcomeLimits は、welcomeで返す接続の制限のモデルです。
+type WelcomeLimits struct {
+	MaxSubscriptions int `json:"max_subscriptions"` // /wsで同時に購読できるTipの数
+	SendBufferSize   int `json:"send_buffer_size"`  // 送信キューの長さ。受信が追いつかずに溢れたフレームは破棄される
+}
+
+// WSFrameV2 は、プロトコルv2でサーバーからのフレームを包むモデルです。
+// dataにはv1で送るのと同じモデルが入ります。
+type WSFrameV2 struct {
+	EventID int64 `json:"event_id,omitempty"` // アウトボックスのイベントの連番（コミット順）。アウトボックスを経由しないフレーム（既読・ピン留め・応答等）は省略
+	Data    any   `json:"data"`               // v1で送るフレーム
+}
+
+// AckMessage は、送信が保存されたことを送信者本人にだけ返す際に使用するモデルです。
+// 再送（client_msg_idが保存済みのものと同じ送信）の場合は、元のメッセージのIDを返します。
+type AckMessage struct {
+	Type        string `json:"type"`                    // 固定で "ack"
+	ClientMsgID string `json:"client_msg_id,omitempty"` // リクエストに含まれていたclient_msg_id
+	MessageID   string `json:"message_id"`              // 保存されたメッセージID
+	TipID       string `json:"tip_id"`                  // チャットルームのID
+	Timestamp   int64  `json:"timestamp"`               // Unixタイムスタンプ（作成時刻）
+	Duplicate   bool   `json:"duplicate"`               // 再送で、新しく保存しなかった場合はtrue
+}
+
+// SubscriptionMessage は、購読の開始・終了の結果を操作したクライアントにだけ返す際に使用するモデルです。
+type SubscriptionMessage struct {
+	Type          string   `json:"type"`          // "subscribed" または "unsubscribed"
+	TipID         string   `json:"tip_id"`        // 購読を開始・終了したTipのID
+	Subscriptions []string `json:"subscriptions"` // 操作後に購読しているTipのID
+}
+
+// ErrorMessage は、リクエストを処理できなかったことを操作したクライアントにだけ返す際に使用するモデルです。
+type ErrorMessage struct {
+	Type      string `json:"type"`             // 固定で "error"
+	Operation string `json:"operation"`        // 失敗したリクエストのtype
+	TipID     string `json:"tip_id,omitempty"` // リクエストに含まれていたtip_id
+	Code      string `json:"code"`             // エラーの種類（"invalid_request", "unsupported_version", "fixed_tip", "subscription_limit", "not_subscribed", "tip_mismatch", "forbidden"）
+	Message   string `json:"message"`          // エラーの説明
 }
 
 // WSBroadcastMessage は、サーバーがクライアントに送信するWebSocketレスポンスの基本モデルです。
 type WSBroadcastMessage struct {
-	Type      string `json:"type"`       // "send", "catchup" など（新規送信やキャッチアップ用）
-	MessageID string `json:"message_id"` // メッセージID
-	TipID     string `json:"tip_id"`     // 対象チャットルームのID
-	Content   string `json:"content"`    // メッセージ内容
-	Timestamp int64  `json:"timestamp"`  // Unixタイムスタンプ（作成時刻）
+	Type      string   `json:"type"`               // "send", "catchup" など（新規送信やキャッチアップ用）
+	MessageID string   `json:"message_id"`         // メッセージID
+	TipID     string   `json:"tip_id"`             // 対象チャットルームのID
+	Content   string   `json:"content"`            // メッセージ内容
+	Timestamp int64    `json:"timestamp"`          // Unixタイムスタンプ（作成時刻）
+	Mentions  []string `json:"mentions,omitempty"` // メッセージ内でメンションされたユーザーID
+	Version   int64    `json:"version"`            // メッセージのバージョン（編集・削除時にexpected_versionとして送る）
+
+	AttachmentIDs []string `json:"attachment_ids,omitempty"` // 添付ファイルのID（GET /attachments/{attachmentID}でダウンロードする）
 }
 
 // --- 以下、編集と削除のブロードキャスト用の構造体 ---
 
 // EditBroadcastMessage は、編集結果を WebSocket ブロードキャストする際に使用するモデルです。
 type EditBroadcastMessage struct {
-	Type       string `json:"type"`        // 固定で "edit"
-	MessageID  string `json:"message_id"`  // 編集対象のメッセージID
-	TipID      string `json:"tip_id"`      // チャットルームのID
-	NewContent string `json:"new_content"` // 編集後の新しい内容
-	EditedAt   int64  `json:"edited_at"`   // Unix タイムスタンプ（更新時刻）
+	Type       string   `json:"type"`               // 固定で "edit"
+	MessageID  string   `json:"message_id"`         // 編集対象のメッセージID
+	TipID      string   `json:"tip_id"`             // チャットルームのID
+	NewContent string   `json:"new_content"`        // 編集後の新しい内容
+	EditedAt   int64    `json:"edited_at"`          // Unix タイムスタンプ（更新時刻）
+	Mentions   []string `json:"mentions,omitempty"` // 編集後の内容でメンションされているユーザーID
+	Version    int64    `json:"version"`            // 編集後のバージョン
+}
+
+// ConflictMessage は、編集・削除がバージョンの不一致で失敗したことを操作したクライアントにだけ返す際に使用するモデルです。
+// クライアントは現在の内容を表示し、current_versionをexpected_versionとして送り直します。
+type ConflictMessage struct {
+	Type           string `json:"type"`            // 固定で "conflict"
+	Operation      string `json:"operation"`       // 失敗した操作（"edit", "delete"）
+	MessageID      string `json:"message_id"`      // 対象のメッセージID
+	TipID          string `json:"tip_id"`          // チャットルームのID
+	CurrentVersion int64  `json:"current_version"` // サーバー上の現在のバージョン
+	CurrentContent string `json:"current_content"` // サーバー上の現在の内容
 }
 
 // DeleteBroadcastMessage は、削除結果を WebSocket ブロードキャストする際に使用するモデルです。
+// 削除されたメッセージがピン留めされていた場合はピン留めも解除されているので、クライアントはピン留め一覧からも取り除きます。
 type DeleteBroadcastMessage struct {
 	Type      string `json:"type"`       // 固定で "delete"
 	MessageID string `json:"message_id"` // 削除対象のメッセージID
 	TipID     string `json:"tip_id"`     // チャットルームのID
 	DeletedAt int64  `json:"deleted_at"` // Unix タイムスタンプ（削除時刻）
 }
+
+// --- 以下、通報による非表示と非表示解除のブロードキャスト用の構造体 ---
+
+// HideBroadcastMessage は、通報数が閾値に達してメッセージが自動非表示になったことをブロードキャストする際に使用するモデルです。
+type HideBroadcastMessage struct {
+	Type      string `json:"type"`       // 固定で "hide"
+	MessageID string `json:"message_id"` // 非表示になったメッセージID
+	TipID     string `json:"tip_id"`     // チャットルームのID
+	HiddenAt  int64  `json:"hidden_at"`  // Unix タイムスタンプ（非表示になった時刻）
+}
+
+// UnhideBroadcastMessage は、モデレーターが通報を却下してメッセージの非表示が解除されたことをブロードキャストする際に使用するモデルです。
+type UnhideBroadcastMessage struct {
+	Type      string `json:"type"`       // 固定で "unhide"
+	MessageID string `json:"message_id"` // 非表示が解除されたメッセージID
+	TipID     string `json:"tip_id"`     // チャットルームのID
+	Content   string `json:"content"`    // 再表示するメッセージ内容
+}
+
+// ReadBroadcastMessage は、ユーザーの既読位置が進んだことをブロードキャストする際に使用するモデルです。
+// クライアントはこれを使って「既読」表示を行います。
+type ReadBroadcastMessage struct {
+	Type      string `json:"type"`       // 固定で "read"
+	TipID     string `json:"tip_id"`     // チャットルームのID
+	UserID    string `json:"user_id"`    // 既読にしたユーザーのID
+	MessageID string `json:"message_id"` // 既読位置のメッセージID
+	ReadAt    int64  `json:"read_at"`    // Unix タイムスタンプ（既読にした時刻）
+}
+
+// --- 以下、ピン留めとピン留め解除のブロードキャスト用の構造体 ---
+
+// PinBroadcastMessage は、メッセージがピン留めされたことをブロードキャストする際に使用するモデルです。
+type PinBroadcastMessage struct {
+	Type      string `json:"type"`       // 固定で "pin"
+	TipID     string `json:"tip_id"`     // チャットルームのID
+	MessageID string `json:"message_id"` // ピン留めされたメッセージID
+	Position  int    `json:"position"`   // ピン留めの並び順
+	PinnedBy  string `json:"pinned_by"`  // ピン留めしたユーザーのID
+	PinnedAt  int64  `json:"pinned_at"`  // Unix タイムスタンプ（ピン留めした時刻）
+}
+
+// UnpinBroadcastMessage は、メッセージのピン留めが解除されたことをブロードキャストする際に使用するモデルです。
+type UnpinBroadcastMessage struct {
+	Type      string `json:"type"`       // 固定で "unpin"
+	TipID     string `json:"tip_id"`     // チャットルームのID
+	MessageID string `json:"message_id"` // ピン留めが解除されたメッセージID
+}
+
+// --- 以下、ロングポーリングの応答用の構造体 ---
+
+// PollResponse は、ロングポーリング（GET /messages/{tipID}/poll）の応答で使用するモデルです。
+type PollResponse struct {
+	Events []PollEvent `json:"events"` // 届いたイベント（タイムアウトした場合は空）
+	Cursor int64       `json:"cursor"` // 次のリクエストのsinceに指定する値（受け取ったイベントの連番の最大値）
+}
+
+// PollEvent は、ロングポーリングで返すイベント1つ分のモデルです。
+type PollEvent struct {
+	ID   int64           `json:"id,omitempty"` // アウトボックスのイベントの連番（コミット順）。既読・ピン留め等のアウトボックスを経由しないイベントは省略
+	Data json.RawMessage `json:"data"`         // WebSocketでブロードキャストされるのと同じJSON
+}
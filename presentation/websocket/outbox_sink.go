@@ -0,0 +1,72 @@
+package websocket
+
+// アウトボックスのイベントを、該当Roomの接続クライアントにブロードキャストするシンク
+// 送信・編集・削除・非表示・非表示解除（と削除に伴うピン留め解除）のブロードキャストはハンドラーから直接行わず、永続化と同じトランザクションで書き込まれたイベントをリレー経由でここに流す
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+type HubEventSink struct {
+	hub *Hub
+}
+
+func NewHubEventSink(hub *Hub) domain.EventSink {
+	return &HubEventSink{hub: hub}
+}
+
+func (s *HubEventSink) Name() string {
+	return "hub"
+}
+
+// Roomに誰も接続していない場合は何もしない（エラーにはしない）
+// 同じイベントが再配信される場合があるので、クライアントはmessage_idとversionで重複を取り除く
+// SSEの購読者にはイベントの連番も届く（再接続時のLast-Event-IDになる）
+// リレーは連番の順にこのシンクへ届けるので、Roomにも連番の順にブロードキャストされる
+func (s *HubEventSink) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	msg := event.Message
+	wsResp, err := toOutboxBroadcastMessage(event)
+	if err != nil {
+		slog.WarnContext(ctx, "HubEventSink: 予期しないイベントの種類です", "type", event.Type, logging.KeyTipID, string(msg.TipID), logging.KeyMessageID, string(msg.ID))
+		return nil
+	}
+	s.hub.BroadcastEventToTip(string(msg.TipID), event.Seq, wsResp)
+	return nil
+}
+
+var errUnknownEventType = errors.New("予期しないイベントの種類です")
+
+// アウトボックスのイベントを、Roomにブロードキャストするモデルにする
+func toOutboxBroadcastMessage(event *domain.OutboxEvent) (any, error) {
+	msg := event.Message
+	switch event.Type {
+	case domain.OutboxEventMessageSent:
+		return ToBroadcastMessage(msg), nil
+	case domain.OutboxEventMessageEdited:
+		return ToEditBroadcastMessage(msg), nil
+	case domain.OutboxEventMessageDeleted:
+		return ToDeleteBroadcastMessage(msg), nil
+	case domain.OutboxEventMessageHidden:
+		return ToHideBroadcastMessage(msg), nil
+	case domain.OutboxEventMessageUnhidden:
+		return ToUnhideBroadcastMessage(msg), nil
+	case domain.OutboxEventMessageUnpinned:
+		return ToUnpinBroadcastMessage(msg.TipID, msg.ID), nil
+	default:
+		return nil, errUnknownEventType
+	}
+}
+
+// アウトボックスのイベントを、JSONのクライアントにブロードキャストするのと同じJSONにする（SSEとロングポーリングの再送で使う）
+func encodeOutboxEvent(event *domain.OutboxEvent) ([]byte, error) {
+	wsResp, err := toOutboxBroadcastMessage(event)
+	if err != nil {
+		return nil, err
+	}
+	return jsonCodec.Marshal(wsResp)
+}
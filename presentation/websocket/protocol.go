@@ -0,0 +1,87 @@
+package websocket
+
+// WebSocketのプロトコルのバージョンと機能の合意
+// クライアントは接続直後にhelloでバージョンと使いたい機能を送り、サーバーはwelcomeで合意した内容とセッションの情報を返す
+// helloを送らないクライアントはv1として扱うので、既存のクライアントはそのまま動く
+
+/*
+バージョンごとの違い（クライアントからのリクエストの形はどのバージョンでも同じ）
+- v1: サーバーからのフレームはmodel.goのモデルをそのまま送る
+- v2: サーバーからのフレームをWSFrameV2に包み、アウトボックスのイベントIDを付ける
+
+同じRoomにv1とv2のクライアントが混在するので、Roomはバージョンごとのアダプターでフレームの形を変えてからエンコードする
+
+*/
+
+// プロトコルのバージョン
+const (
+	ProtocolV1            = 1
+	ProtocolV2            = 2
+	latestProtocolVersion = ProtocolV2
+)
+
+// helloで合意できる機能
+const (
+	CapabilitySubscribe    = "subscribe"     // /wsでの複数Tipの購読（subscribe・unsubscribe）
+	CapabilityPins         = "pins"          // ピン留め（pin・unpin）
+	CapabilityReadReceipts = "read_receipts" // 既読位置の共有（mark_read）
+	CapabilityReports      = "reports"       // 通報（report）
+	CapabilityBatch        = "batch"         // 送信キューに溜まった複数のフレームを、配列1つのフレームにまとめて受け取る
+)
+
+// サーバーが対応している機能（welcomeではクライアントが指定したもののうち、ここにあるものだけを返す）
+var supportedCapabilities = []string{CapabilitySubscribe, CapabilityPins, CapabilityReadReceipts, CapabilityReports, CapabilityBatch}
+
+// helloで合意した内容。接続ごとに1回だけ決まる
+type protocolSession struct {
+	version      int
+	capabilities map[string]bool
+}
+
+// サーバーからのフレームの形をプロトコルのバージョンに合わせるアダプター
+type frameAdapter func(eventID int64, message any) any
+
+var frameAdapters = map[int]frameAdapter{
+	ProtocolV1: adaptFrameV1,
+	ProtocolV2: adaptFrameV2,
+}
+
+func adaptFrameV1(eventID int64, message any) any {
+	return message
+}
+
+func adaptFrameV2(eventID int64, message any) any {
+	return &WSFrameV2{EventID: eventID, Data: message}
+}
+
+// バージョンに対応するアダプターでフレームの形を変える
+func adaptFrame(version int, eventID int64, message any) any {
+	adapter, ok := frameAdapters[version]
+	if !ok {
+		adapter = adaptFrameV1
+	}
+	return adapter(eventID, message)
+}
+
+// クライアントが要求したバージョンから使うバージョンを決める。サーバーより新しいバージョンを要求された場合はサーバーの最新にする
+// v1より古いバージョン（0や負の値）は合意できないのでfalseを返す
+func negotiateProtocolVersion(requested int) (int, bool) {
+	if requested < ProtocolV1 {
+		return 0, false
+	}
+	return min(requested, latestProtocolVersion), true
+}
+
+// クライアントが要求した機能のうち、サーバーが対応しているものを返す
+func negotiateCapabilities(requested []string) []string {
+	accepted := make([]string, 0, len(requested))
+	for _, c := range supportedCapabilities {
+		for _, r := range requested {
+			if r == c {
+				accepted = append(accepted, c)
+				break
+			}
+		}
+	}
+	return accepted
+}
@@ -0,0 +1,37 @@
+package websocket
+
+// WebSocket以外（HTTP経由のリクエスト）で発生したメッセージの状態変化を、該当Roomの接続クライアントに届けるためのメソッド
+// REST側のハンドラーはこのメソッドを持つインターフェースにだけ依存する
+
+import (
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// tipIDに対応するRoomが存在する場合のみブロードキャストする
+// 誰も接続していないtipIDに対してRoomを新しく作らない
+// messageはmodel.goのブロードキャスト用モデル（エンコードはRoomがクライアントのコーデックごとに行う）
+func (h *Hub) BroadcastToTip(tipID string, message any) {
+	h.mu.RLock()
+	room, ok := h.Rooms[tipID]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+	room.Broadcast(message)
+}
+
+// アウトボックスのイベントを、tipIDに対応するRoomが存在する場合のみブロードキャストする
+func (h *Hub) BroadcastEventToTip(tipID string, eventID int64, message any) {
+	h.mu.RLock()
+	room, ok := h.Rooms[tipID]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+	room.BroadcastEvent(eventID, message)
+}
+
+// 既読位置の更新をブロードキャスト
+func (h *Hub) PublishRead(cursor *domain.ReadCursor) {
+	h.BroadcastToTip(string(cursor.TipID), ToReadBroadcastMessage(cursor))
+}
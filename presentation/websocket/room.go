@@ -1,72 +1,115 @@
 package websocket
 
 import (
+	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
 )
 
 // 各Tipに対応するチャットルームを管理する構造体
+// メンバーはWebSocketの接続とSSEの購読者（RoomMember）
 type Room struct {
 	TipID        string
-	Clients      map[*Connection]bool
+	Clients      map[RoomMember]bool
 	mu           sync.RWMutex
 	LastActivity time.Time     // 最後のアクティビティ時刻
-	idleDuration time.Duration // 5分間何もアクティビティがないかどうか判定するためのフィールド
+	idleDuration time.Duration // この時間何もアクティビティがないかどうか判定するためのフィールド
 }
 
 // tipIDに対応するRoomインスタンスを生成
-func NewRoom(tipId string) *Room {
+func NewRoom(tipId string, idleDuration time.Duration) *Room {
 	return &Room{
 		TipID:        tipId,
-		Clients:      make(map[*Connection]bool),
+		Clients:      make(map[RoomMember]bool),
 		LastActivity: time.Now(),
-		idleDuration: 5 * time.Minute,
+		idleDuration: idleDuration,
 	}
 }
 
-// 引数で渡されたConnectionをRoomに追加し、参加時刻をLastActivityに記録
-func (r *Room) Join(c *Connection) {
+// 引数で渡されたメンバーをRoomに追加し、参加時刻をLastActivityに記録
+func (r *Room) Join(c RoomMember) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.Clients[c] = true // ConnectionをRoomのclientsマップに追加
 	r.LastActivity = time.Now()
 }
 
-// 引数で渡されたConnectionをRoomのClientsマップから削除しClose。この時の最後のアクティビティ時刻をLastActivityに記録
-func (r *Room) Leave(c *Connection) {
+// 引数で渡されたメンバーをRoomのClientsマップから削除する。この時の最後のアクティビティ時刻をLastActivityに記録
+// 接続のCloseは、全てのRoomから外したあとにHub.Disconnectで行う
+func (r *Room) Leave(c RoomMember) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.Clients[c]; ok {
-		delete(r.Clients, c)
-		c.Conn.Close()
-	}
+	delete(r.Clients, c)
 	r.LastActivity = time.Now()
 }
 
 // Roomに属する全クライアント（Connection）のSendチャネルにメッセージを送信する（代入する）。
-func (r *Room) Broadcast(message []byte) {
+// messageはmodel.goのブロードキャスト用モデル。エンコードは各クライアントのコーデックで行う
+func (r *Room) Broadcast(message any) {
+	r.broadcast(0, message)
+}
+
+// アウトボックスのイベントをブロードキャストする。eventIDはイベントの連番（domain.OutboxEvent.Seq）
+// SSEの購読者にはeventIDがidとして届き、再接続時の再送の起点になる
+func (r *Room) BroadcastEvent(eventID int64, message any) {
+	r.broadcast(eventID, message)
+}
+
+// フレームの形式（コーデックとプロトコルのバージョンの組）
+type frameFormat struct {
+	codec   string
+	version int
+}
+
+// JSONとMessagePack、v1とv2のクライアントが混在するので、クライアントごとではなく形式ごとに1回だけエンコードする
+func (r *Room) broadcast(eventID int64, message any) {
+	start := time.Now()
+	defer func() { broadcastDuration.Observe(time.Since(start).Seconds()) }()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	r.LastActivity = time.Now() // 最後のアクティビティ時刻を更新
+	encoded := make(map[frameFormat][]byte)
 	for client := range r.Clients {
-		select {
-		case client.Send <- message:
-		default:
+		codec := client.frameCodec()
+		format := frameFormat{codec: codec.Name(), version: client.frameVersion()}
+		data, ok := encoded[format]
+		if !ok {
+			var err error
+			if data, err = codec.Marshal(adaptFrame(format.version, eventID, message)); err != nil {
+				slog.Error("Room: ブロードキャスト用メッセージのエンコードに失敗", logging.KeyTipID, r.TipID, "codec", codec.Name(), "version", format.version, "error", err)
+			}
+			encoded[format] = data
+			broadcastEncodes.WithLabelValues(codec.Name()).Inc()
+		}
+		if data == nil {
+			continue
+		}
+		if !client.deliver(roomFrame{eventID: eventID, data: data}) {
 			// チャネルがブロックしている場合はスキップ
+			droppedFrames.WithLabelValues("broadcast").Inc()
 		}
 	}
 }
 
-// Roomが5分間何もアクティビティが無い場合（LastActivityからの経過時間がidleDurationを超えている場合）、全ConnectionをCloseし、Clientsマップから削除
-func (r *Room) CheckIdleConnections() {
+// RoomがidleDurationの間何もアクティビティが無い場合（LastActivityからの経過時間がidleDurationを超えている場合）、このTipに固定された接続をClientsマップから削除して返す
+// 返した接続のCloseと、Hubでの参加状態の削除は呼び出し側（Hub.Run）で行う
+func (r *Room) CheckIdleConnections() []RoomMember {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if time.Since(r.LastActivity) > r.idleDuration {
-		for client := range r.Clients {
-			client.Conn.Close()
+	if time.Since(r.LastActivity) <= r.idleDuration {
+		return nil
+	}
+	var removed []RoomMember
+	for client := range r.Clients {
+		if client.FixedTipID() == r.TipID {
 			delete(r.Clients, client)
+			removed = append(removed, client)
 		}
 	}
+	return removed
 }
 
 // ルーム内にConnectionが無い場合はtrueを返す（ルームないにクライアントがいないかどうかを確認する）
@@ -75,3 +118,15 @@ func (r *Room) IsEmpty() bool {
 	defer r.mu.RUnlock()
 	return len(r.Clients) == 0
 }
+
+// 引数のユーザーがRoomに接続しているかどうか（同じユーザーが複数接続している場合もある）
+func (r *Room) HasUser(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for client := range r.Clients {
+		if client.memberUserID() == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package websocket
+
+// WebSocketが使えない環境（WebSocketを通さないプロキシ等）向けに、RoomのブロードキャストをServer-Sent Eventsで届ける
+// SSEの購読者（StreamSubscriber）はWebSocketの接続と同じくRoomMemberとしてHubに登録するので、Room.Broadcastで同じイベントが届く
+// 受信専用なので、送信・編集・削除はREST APIやWebSocketで行う
+
+/*
+処理の流れ
+1. Tipの履歴を閲覧できるか確認し、購読者をHubに登録してTipのRoomに参加させる（ここからライブのイベントが溜まり始める）
+2. Last-Event-IDがあれば、それより後のイベントをアウトボックスから取得して送る（切断中の取りこぼしの再送）
+   イベントのidにはリレーがコミット順に振った連番を使うので、Last-Event-IDより後にコミットされたイベントは全て連番も大きい
+3. ライブのイベントを送り続ける。ライブのイベントも連番の順に届くので、最後に送った連番以下のイベントは重複として取り除く
+4. 一定間隔でハートビートのコメントを送り、プロキシにアイドル接続として切られないようにする
+
+*/
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/usecase"
+)
+
+// SSEの設定
+type SSEConfig struct {
+	HeartbeatInterval time.Duration // ハートビートのコメントを送る間隔
+	ReplayBatchSize   int           // Last-Event-ID以降のイベントをアウトボックスから1回に取得する件数
+}
+
+type SSEHandler struct {
+	uc           usecase.EventStreamUsecase
+	hub          *Hub
+	config       SSEConfig
+	shutdown     chan struct{} // サーバーの停止時に閉じ、全てのストリームを終わらせる
+	shutdownOnce sync.Once
+}
+
+// ユースケースとHubを注入するコンストラクタ関数
+func NewSSEHandler(uc usecase.EventStreamUsecase, hub *Hub, config SSEConfig) *SSEHandler {
+	return &SSEHandler{
+		uc:       uc,
+		hub:      hub,
+		config:   config,
+		shutdown: make(chan struct{}),
+	}
+}
+
+// 全てのストリームを終わらせる（http.Server.RegisterOnShutdownに登録する）
+// SSEのリクエストは終わらないので、これが無いとShutdownが処理中のリクエストとして待ち続けてしまう
+func (h *SSEHandler) Shutdown() {
+	h.shutdownOnce.Do(func() { close(h.shutdown) })
+}
+
+// Roomのイベントのストリームのハンドラー（GET /sse/{tipID}）
+// 再接続時はブラウザのEventSourceが付けるLast-Event-IDヘッダー（またはlast_event_idクエリ）以降のイベントから送る
+func (h *SSEHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		http.Error(w, "X-User-Id ヘッダーがありません", http.StatusUnauthorized)
+		return
+	}
+	tipID := chi.URLParam(r, "tipID")
+	if tipID == "" {
+		http.Error(w, "tipIDが必要です", http.StatusBadRequest)
+		return
+	}
+	lastEventID, err := parseLastEventID(r)
+	if err != nil {
+		http.Error(w, "Last-Event-IDが不正です", http.StatusBadRequest)
+		return
+	}
+	err = h.uc.AuthorizeJoin(r.Context(), domain.TipID(tipID), domain.UserID(userID))
+	if errors.Is(err, domain.ErrTipAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Tipへのアクセス権限の確認に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 再送より先にRoomに参加して、再送中に発生したイベントも取りこぼさないようにする
+	sub := newStreamSubscriber(r.Context(), userID, tipID, h.hub.SendBufferSize())
+	h.hub.Register(sub)
+	if err := h.hub.Subscribe(sub, tipID); err != nil {
+		h.hub.Disconnect(sub)
+		http.Error(w, "Roomへの参加に失敗: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer h.hub.Disconnect(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginxのバッファリングを無効にする
+	w.WriteHeader(http.StatusOK)
+	// ヘッダーをすぐにクライアントへ届ける（プロキシによってはボディが流れるまでレスポンスを返さない）
+	if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		slog.ErrorContext(sub.Ctx, "SSE: ストリーミングに対応していないResponseWriterです", "error", err)
+		return
+	}
+	slog.InfoContext(sub.Ctx, "SSE: 購読を開始", "last_event_id", lastEventID)
+
+	// 最後に送ったイベントの連番。ライブでこれ以下のイベントが届いたら再送済みなので送らない
+	sentSeq := lastEventID
+	if lastEventID > 0 {
+		if sentSeq, err = h.replay(sub, w, lastEventID); err != nil {
+			slog.InfoContext(sub.Ctx, "SSE: 取りこぼしたイベントの再送に失敗", "error", err)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.config.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			slog.InfoContext(sub.Ctx, "SSE: クライアントが切断")
+			return
+		case <-sub.done:
+			return
+		case <-h.shutdown:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case frame := <-sub.frames:
+			if frame.eventID != 0 && frame.eventID <= sentSeq {
+				continue
+			}
+			if err := writeSSEEvent(w, frame.eventID, frame.data); err != nil {
+				return
+			}
+			if frame.eventID != 0 {
+				sentSeq = frame.eventID
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// lastEventID（連番）より後のイベントをアウトボックスから取得して送り、最後に送ったイベントの連番を返す
+// 保持期間を過ぎて削除されたイベントは再送できない
+func (h *SSEHandler) replay(sub *StreamSubscriber, w http.ResponseWriter, lastEventID int64) (int64, error) {
+	for {
+		events, err := h.uc.FetchEventsAfter(sub.Ctx, domain.TipID(sub.tipID), lastEventID, h.config.ReplayBatchSize)
+		if err != nil {
+			return lastEventID, err
+		}
+		for _, event := range events {
+			data, err := encodeOutboxEvent(event)
+			if err != nil {
+				slog.WarnContext(sub.Ctx, "SSE: 再送できないイベントを飛ばします", "event_id", event.ID, "event_seq", event.Seq, "error", err)
+			} else if err := writeSSEEvent(w, event.Seq, data); err != nil {
+				return lastEventID, err
+			}
+			lastEventID = event.Seq
+		}
+		if len(events) < h.config.ReplayBatchSize {
+			return lastEventID, nil
+		}
+	}
+}
+
+// イベントを1つ書き込む。ブロードキャストのJSONは改行を含まないので1行のdataにする
+// idの無いイベント（アウトボックスを経由しない既読・ピン留め等）ではクライアントのLast-Event-IDは変わらない
+func writeSSEEvent(w http.ResponseWriter, eventID int64, data []byte) error {
+	if eventID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", eventID); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// Last-Event-IDヘッダー（無ければlast_event_idクエリ）を読む。どちらも無ければ0
+// EventSourceのポリフィルにはヘッダーを付けられないものがあるので、クエリでも受け付ける
+func parseLastEventID(r *http.Request) (int64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("Last-Event-IDが不正です")
+	}
+	return id, nil
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+// WebSocket以外（SSEとロングポーリング）でRoomのブロードキャストを受け取る購読者。1つのTipに固定される
+// WebSocketの接続と同じくRoomMemberとしてHubに登録し、届いたブロードキャストをチャネルに溜める
+type StreamSubscriber struct {
+	ID        string          // ログの相関用に購読者ごとに振るID
+	tipID     string          // 購読しているTip
+	UserID    string          // 購読者のユーザーID
+	frames    chan roomFrame  // Roomからのブロードキャストを溜めるチャネル
+	done      chan struct{}   // Hubから外されたら閉じる（アイドリングしたRoomの掃除等）
+	closeOnce sync.Once       // doneを一度だけ閉じるため
+	Ctx       context.Context // HTTPリクエストのContextを継承するフィールド
+}
+
+func newStreamSubscriber(ctx context.Context, userID string, tipID string, bufferSize int) *StreamSubscriber {
+	id := generateUUID()
+	ctx = logging.WithTipID(ctx, tipID)
+	ctx = logging.WithUserID(ctx, userID)
+	ctx = logging.WithConnectionID(ctx, id)
+	return &StreamSubscriber{
+		ID:     id,
+		tipID:  tipID,
+		UserID: userID,
+		frames: make(chan roomFrame, bufferSize),
+		done:   make(chan struct{}),
+		Ctx:    ctx,
+	}
+}
+
+func (s *StreamSubscriber) deliver(frame roomFrame) bool {
+	select {
+	case s.frames <- frame:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *StreamSubscriber) memberUserID() string {
+	return s.UserID
+}
+
+func (s *StreamSubscriber) FixedTipID() string {
+	return s.tipID
+}
+
+func (s *StreamSubscriber) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// SSEとロングポーリングはテキストで返すので常にJSON
+func (s *StreamSubscriber) frameCodec() Codec {
+	return jsonCodec
+}
+
+// SSEとロングポーリングはイベントIDをそれぞれの形式（SSEのid、ロングポーリングのid）で返すので、フレームはv1の形
+func (s *StreamSubscriber) frameVersion() int {
+	return ProtocolV1
+}
@@ -1,34 +1,47 @@
 package websocket
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/minminseo/tipstar-chat-api/presentation/origin"
 )
 
-// HTTP接続をWebSocket接続（双方向通信）に昇格させるためのUpgrater（構造体）を定義。
-// 昇格処理は下の方で実装（JWTを検証したあとに実行）。
-// wsUpgraderはwebsocketパッケージのUpgrader型の構造体リテラルによって直接インスタンス化
-var wsUpgrader = websocket.Upgrader{
-
-	// CheckOriginフィールドにオリジンチェックの結果を代入しUpgraderをインスタンス化する
-	// Websocket接続のオリジンチェック（クロスサイトWebSocketハイジャック対策というやつらしい）
-	CheckOrigin: func(r *http.Request) bool {
-
-		/*
-			// 環境変数からURLを取得
-			// Vercelデプロイ時に設定する必要ある。
-			// 開発してるうちはとりあえずhttp://localhost:3000とかにする。使用済みなら適宜変更
-			feURL := os.Getenv("FE_URL")
-
-			// HTTPリクエストのヘッダーからOriginを取得し、環境変数で指定されたURLと一致するか確認
-			return r.Header.Get("Origin") == feURL
-		*/
-		return true // 開発中はtrue（全オリジン許可）
-	},
+// HTTP接続をWebSocket接続（双方向通信）に昇格させるUpgrader
+// 昇格処理はUpgradeメソッドで実装（JWTを検証したあとに実行）。
+type Upgrader struct {
+	upgrader    websocket.Upgrader
+	origins     *origin.Allowlist // 接続を許可するOrigin
+	compression CompressionConfig // permessage-deflateの設定
 }
 
-func UpgradeHTTP(w http.ResponseWriter, r *http.Request) (*websocket.Conn, string, error) { // 返り値として接続情報、ユーザーID、エラーを返す
+func NewUpgrader(origins *origin.Allowlist, compression CompressionConfig) *Upgrader {
+	u := &Upgrader{origins: origins, compression: compression}
+	// Websocket接続のオリジンチェック（クロスサイトWebSocketハイジャック対策）
+	// 拒否した場合はgorilla/websocketが403を返す
+	u.upgrader.CheckOrigin = u.checkOrigin
+	// クライアントがSec-WebSocket-Protocolで指定したサブプロトコルのうち、対応しているものを応答で返す
+	// 対応していないものだけを指定した場合はサブプロトコル無しで昇格し、JSONでやり取りする
+	u.upgrader.Subprotocols = supportedSubprotocols
+	// クライアントがpermessage-deflateを提示していれば合意する（圧縮するかどうかはフレームごとにWritePumpで決める）
+	u.upgrader.EnableCompression = compression.Enabled
+	return u
+}
+
+// 別のサイトのページから利用者の認証情報付きで接続されるのを防ぐため、許可リストに無いOriginからの昇格を拒否する
+// Originヘッダーはブラウザが必ず付けるので、付いていない接続（ブラウザ以外のクライアント）はそのまま許可する
+func (u *Upgrader) checkOrigin(r *http.Request) bool {
+	o := r.Header.Get("Origin")
+	if o == "" || u.origins.Allowed(o) {
+		return true
+	}
+	slog.WarnContext(r.Context(), "WebSocket: 許可されていないOriginからの接続を拒否", "origin", o, "remote_addr", r.RemoteAddr)
+	return false
+}
+
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, string, error) { // 返り値として接続情報、ユーザーID、エラーを返す
 
 	// JWT認証なし
 	// ユーザーIDは "X-User-Id" ヘッダーから取得
@@ -39,9 +52,18 @@ func UpgradeHTTP(w http.ResponseWriter, r *http.Request) (*websocket.Conn, strin
 	}
 
 	// HTTP接続をWebSocket接続へ昇格
-	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	// 圧縮後のサイズを数えるため、Hijackした接続をwireConnで包む
+	ww := &wireResponseWriter{ResponseWriter: w}
+	conn, err := u.upgrader.Upgrade(ww, r, nil)
 	if err != nil {
 		return nil, "", err
 	}
+	if ww.conn != nil && u.compression.Enabled && offersDeflate(r) {
+		ww.conn.deflate = true
+		ww.conn.threshold = u.compression.Threshold
+		if err := conn.SetCompressionLevel(u.compression.Level); err != nil {
+			slog.WarnContext(r.Context(), "WebSocket: 圧縮レベルの設定に失敗", "level", u.compression.Level, "error", err)
+		}
+	}
 	return conn, userID, err
 }
@@ -1,27 +1,45 @@
 package websocket
 
-// ここではWebSocket経由で受信したリクエストのハンドリングを行う（最終的にJSON形式にしてブロードキャスト関数を呼び出す）
+// ここではWebSocket経由で受信したリクエストのハンドリングを行う（最終的にレスポンスのモデルにしてブロードキャスト関数を呼び出す。エンコードは接続ごとのコーデックで行う）
 // ブロードキャストやルームの取得はHubを参照して行う
 // コンストラクタの引数hubはまずnilを受け取り、その後SetHubメソッドで外部でインスタンス化されたHubを注入させる。
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
 	"github.com/minminseo/tipstar-chat-api/usecase"
 )
 
+var tracer = otel.Tracer("github.com/minminseo/tipstar-chat-api/presentation/websocket")
+
 type OnlyWSMessageHandler struct {
-	uc  usecase.OnlyWSUsecase // usecase.OnlyWSUsecase（インターフェース）を型として持つucフィールドを定義
-	hub *Hub                  // ルーム管理用のHub
+	uc       usecase.OnlyWSUsecase     // usecase.OnlyWSUsecase（インターフェース）を型として持つucフィールドを定義
+	reportUC usecase.ReportUsecase     // 通報のユースケース
+	readUC   usecase.ReadCursorUsecase // 既読位置のユースケース
+	pinUC    usecase.PinUsecase        // ピン留めのユースケース
+	hub      *Hub                      // ルーム管理用のHub
+	version  string                    // welcomeで返すサーバーのバージョン
 }
 
 // ユースケースのインターフェースを満たすメソッドをプレゼンテーション層に注入するコンストラクタ関数（ユースケース内部の処理を隠してここで使えるようにする）
 // この時点ではHubにnilを渡す。まだインスタンス化されていないから。
-func NewOnlyWSMessageHandler(uc usecase.OnlyWSUsecase, hub *Hub) *OnlyWSMessageHandler {
+func NewOnlyWSMessageHandler(uc usecase.OnlyWSUsecase, reportUC usecase.ReportUsecase, readUC usecase.ReadCursorUsecase, pinUC usecase.PinUsecase, hub *Hub, serverVersion string) *OnlyWSMessageHandler {
 	return &OnlyWSMessageHandler{
-		uc:  uc,
-		hub: hub,
+		uc:       uc,
+		reportUC: reportUC,
+		readUC:   readUC,
+		pinUC:    pinUC,
+		hub:      hub,
+		version:  serverVersion,
 	}
 }
 
@@ -34,116 +52,459 @@ func (h *OnlyWSMessageHandler) SetHub(hub *Hub) {
 
 // Websocket経由のリクエストのボディに含まれるTypeフィールドの値毎に処理を分岐。
 // 1個の接続クライアントには基本1個の読み取りループを回すので、読み取りループ実行の関数（このアプリではReadPump）ではこの関数を呼び出してTypeフィールドの値毎に処理を分岐する
+// 受信したフレームごとにスパンを記録する。親は接続時のリクエストのtraceparent（Connection.Ctxに引き継がれている）
 func (h *OnlyWSMessageHandler) HandleWSMessage(rawMsg []byte, conn *Connection) {
+	ctx, span := tracer.Start(conn.Context(), "ws.frame",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("connection_id", conn.ID),
+			attribute.String("user_id", conn.UserID),
+			attribute.Int("frame.size", len(rawMsg)),
+		),
+	)
+	defer span.End()
 	var req WSRequestMessage
-	if err := json.Unmarshal(rawMsg, &req); err != nil {
-		log.Printf("HandleWSMessage: WSリクエストのJSONのデコードに失敗: %v", err)
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		span.SetStatus(codes.Error, "フレームのデコードに失敗")
+		slog.WarnContext(ctx, "HandleWSMessage: WSリクエストのデコードに失敗", "error", err)
 		return
 	}
+	span.SetName("ws." + req.Type)
+	span.SetAttributes(attribute.String("ws.type", req.Type), attribute.String("tip_id", req.TipID))
+	if conn.FixedTipID() == "" && req.TipID != "" {
+		// /wsの接続はフレームごとに対象のTipが変わるので、ログのtip_idをフレームのものにする
+		ctx = logging.WithTipID(ctx, req.TipID)
+	}
 	switch req.Type {
+	case "hello":
+		h.HelloHandler(ctx, &req, conn)
 	case "send":
-		h.SendMessageHandler(rawMsg, conn)
+		h.SendMessageHandler(ctx, rawMsg, conn)
 	case "edit":
-		h.EditMessageHandler(rawMsg, conn)
+		h.EditMessageHandler(ctx, rawMsg, conn)
 	case "delete":
-		h.DeleteMessageHandler(rawMsg, conn)
+		h.DeleteMessageHandler(ctx, rawMsg, conn)
+	case "report":
+		h.ReportMessageHandler(ctx, rawMsg, conn)
+	case "mark_read":
+		h.MarkReadHandler(ctx, rawMsg, conn)
+	case "pin":
+		h.PinMessageHandler(ctx, rawMsg, conn)
+	case "unpin":
+		h.UnpinMessageHandler(ctx, rawMsg, conn)
+	case "subscribe":
+		h.SubscribeHandler(ctx, &req, conn)
+	case "unsubscribe":
+		h.UnsubscribeHandler(ctx, &req, conn)
 	default:
-		log.Printf("HandleWSMessage: 予期しないリクエストのTypeが含まれています: %s", req.Type)
+		span.SetName("ws.unknown") // クライアントが送った任意の値でスパン名が増えないようにする
+		slog.WarnContext(ctx, "HandleWSMessage: 予期しないリクエストのTypeが含まれています", "type", req.Type)
 	}
 }
 
 // メッセージ送信のハンドラー
-func (h *OnlyWSMessageHandler) SendMessageHandler(rawMsg []byte, conn *Connection) {
+func (h *OnlyWSMessageHandler) SendMessageHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
 	var req WSRequestMessage
-	if err := json.Unmarshal(rawMsg, &req); err != nil {
-		log.Printf("SendMessageHandler: WSリクエストのJSONのデコードに失敗: %v", err)
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "SendMessageHandler: WSリクエストのデコードに失敗", "error", err)
 		return
 	}
 	if req.Type != "send" {
-		log.Printf("SendMessageHandler: 予期しないリクエストのTypeが含まれています: %s", req.Type)
+		slog.WarnContext(ctx, "SendMessageHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	if !h.resolveTipID(ctx, &req, conn) {
 		return
 	}
 
 	// ユーザーIDは接続時に取得した conn.UserID を使用する
 	msg, err := ToSendDomainFromWSRequest(&req, conn.UserID)
 	if err != nil {
-		log.Printf("SendMessageHandler: ドメインモデルへの変換に失敗: %v", err)
+		slog.WarnContext(ctx, "SendMessageHandler: ドメインモデルへの変換に失敗", "error", err)
 		return
 	}
-	if err := h.uc.ExecuteSendMessage(conn.Context(), msg); err != nil {
-		log.Printf("SendMessageHandler: メッセージの永続化に失敗: %v", err)
+	ctx = logging.WithMessageID(ctx, string(msg.ID))
+	// 再送の場合は元のメッセージが返るので、ack・ブロードキャストとも元のメッセージを使う
+	saved, duplicate, err := h.uc.ExecuteSendMessage(ctx, msg)
+	if replyRejected(ctx, conn, req.Type, req.TipID, err) {
 		return
 	}
-	wsResp := ToBroadcastMessage(msg)
-	bMsg, err := json.Marshal(wsResp)
 	if err != nil {
-		log.Printf("SendMessageHandler: ブロードキャスト用メッセージのJSONエンコードに失敗: %v", err)
+		slog.ErrorContext(ctx, "SendMessageHandler: メッセージの永続化に失敗", "error", err)
 		return
 	}
-	room := h.hub.GetRoom(req.TipID)
-	room.Broadcast(bMsg)
+	conn.Reply(ToAckMessage(saved, duplicate))
+
+	// 新しく保存した場合のブロードキャストは、アウトボックスのリレー経由で行われる
+	// 再送の場合は新しいイベントが書き込まれないので、元のメッセージをここでブロードキャストし直す
+	if duplicate {
+		h.hub.BroadcastToTip(req.TipID, ToBroadcastMessage(saved))
+		// メンションの通知は元の送信の時点で済んでいる
+		return
+	}
+
+	// Roomに接続していないユーザーへのメンションは、ブロードキャストでは届かないので通知のフックに回す
+	var offline []domain.UserID
+	for _, id := range msg.Mentions {
+		if !h.hub.TipHasUser(req.TipID, string(id)) {
+			offline = append(offline, id)
+		}
+	}
+	if len(offline) > 0 {
+		if err := h.uc.NotifyMentions(ctx, msg, offline); err != nil {
+			slog.ErrorContext(ctx, "SendMessageHandler: メンションの通知に失敗", "error", err)
+		}
+	}
 }
 
 // メッセージ編集のハンドラー
-func (h *OnlyWSMessageHandler) EditMessageHandler(rawMsg []byte, conn *Connection) {
+func (h *OnlyWSMessageHandler) EditMessageHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
 	var req WSRequestMessage
-	if err := json.Unmarshal(rawMsg, &req); err != nil {
-		log.Printf("EditMessageHandler: WSリクエストのJSONのデコードに失敗: %v", err)
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "EditMessageHandler: WSリクエストのデコードに失敗", "error", err)
 		return
 	}
 	if req.Type != "edit" {
-		log.Printf("EditMessageHandler: 予期しないリクエストのTypeが含まれています: %s", req.Type)
+		slog.WarnContext(ctx, "EditMessageHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	ctx = logging.WithMessageID(ctx, req.MessageID)
+	if !h.resolveTipID(ctx, &req, conn) {
 		return
 	}
 
 	// ユーザーIDは接続時に取得した conn.UserID を使用する
 	msg, err := ToEditDomainFromWSRequest(&req, conn.UserID)
 	if err != nil {
-		log.Printf("EditMessageHandler: ドメインモデルへの変換に失敗: %v", err)
+		slog.WarnContext(ctx, "EditMessageHandler: ドメインモデルへの変換に失敗", "error", err)
 		return
 	}
-	if err := h.uc.EditMessage(conn.Context(), msg.ID, msg.UserID, msg.Content); err != nil {
-		log.Printf("EditMessageHandler: メッセージの編集に失敗: %v", err)
+	// ブロードキャストはアウトボックスのリレー経由で行われる（フィルターで伏せ字化された後の内容が流れる）
+	// メッセージが接続中のTipのものかどうかは、保存されているメッセージのTipでユースケース層が検証する
+	_, err = h.uc.EditMessage(ctx, domain.TipID(req.TipID), msg.ID, msg.UserID, msg.Content, req.ExpectedVersion)
+	if replyConflict(ctx, conn, "edit", req.TipID, err) || replyRejected(ctx, conn, "edit", req.TipID, err) {
 		return
 	}
-	wsResp := ToEditBroadcastMessage(msg)
-	bMsg, err := json.Marshal(wsResp)
 	if err != nil {
-		log.Printf("EditMessageHandler: ブロードキャスト用メッセージのJSONエンコードに失敗: %v", err)
-		return
+		slog.ErrorContext(ctx, "EditMessageHandler: メッセージの編集に失敗", "error", err)
 	}
-	room := h.hub.GetRoom(req.TipID)
-	room.Broadcast(bMsg)
 }
 
 // メッセージ削除のハンドラー
-func (h *OnlyWSMessageHandler) DeleteMessageHandler(rawMsg []byte, conn *Connection) {
+func (h *OnlyWSMessageHandler) DeleteMessageHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
 	var req WSRequestMessage
-	if err := json.Unmarshal(rawMsg, &req); err != nil {
-		log.Printf("DeleteMessageHandler: WSリクエストのJSONのデコードに失敗: %v", err)
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "DeleteMessageHandler: WSリクエストのデコードに失敗", "error", err)
 		return
 	}
 	if req.Type != "delete" {
-		log.Printf("DeleteMessageHandler: 予期しないリクエストのTypeが含まれています: %s", req.Type)
+		slog.WarnContext(ctx, "DeleteMessageHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	ctx = logging.WithMessageID(ctx, req.MessageID)
+	if !h.resolveTipID(ctx, &req, conn) {
 		return
 	}
 
 	// ユーザーIDは接続時に取得した conn.UserID を使用する
 	msg, err := ToDeleteDomainFromWSRequest(&req, conn.UserID)
 	if err != nil {
-		log.Printf("DeleteMessageHandler: ドメインモデルへの変換に失敗: %v", err)
+		slog.WarnContext(ctx, "DeleteMessageHandler: ドメインモデルへの変換に失敗", "error", err)
+		return
+	}
+	// ブロードキャストはアウトボックスのリレー経由で行われる
+	err = h.uc.DeleteMessage(ctx, domain.TipID(req.TipID), msg.ID, msg.UserID, req.ExpectedVersion)
+	if replyConflict(ctx, conn, "delete", req.TipID, err) || replyRejected(ctx, conn, "delete", req.TipID, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteMessageHandler: メッセージの削除に失敗", "error", err)
+	}
+}
+
+// errがバージョンの不一致の場合、現在のバージョンと内容を操作したクライアントにだけ返してtrueを返す
+func replyConflict(ctx context.Context, conn *Connection, operation string, tipID string, err error) bool {
+	var conflict *domain.VersionConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	slog.InfoContext(ctx, "replyConflict: バージョンが一致しません", "operation", operation, "error", err)
+	conn.Reply(ToConflictMessage(operation, tipID, conflict))
+	return true
+}
+
+// errが別のTipのメッセージへの操作か、Tipへのアクセス権限が無いことによる拒否の場合、エラーフレームを操作したクライアントにだけ返してtrueを返す
+func replyRejected(ctx context.Context, conn *Connection, operation string, tipID string, err error) bool {
+	var code string
+	switch {
+	case errors.Is(err, domain.ErrTipMismatch):
+		code = errorCodeTipMismatch
+	case errors.Is(err, domain.ErrTipAccessDenied):
+		code = errorCodeForbidden
+	default:
+		return false
+	}
+	slog.WarnContext(ctx, "replyRejected: 操作が拒否されました", "operation", operation, "error", err)
+	replyError(conn, operation, tipID, code, err.Error())
+	return true
+}
+
+// メッセージ通報のハンドラー
+// 通報自体はブロードキャストしない。通報数が閾値に達して自動非表示になった場合の非表示のブロードキャストは、アウトボックスのリレー経由で行われる
+func (h *OnlyWSMessageHandler) ReportMessageHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
+	var req WSRequestMessage
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "ReportMessageHandler: WSリクエストのデコードに失敗", "error", err)
+		return
+	}
+	if req.Type != "report" {
+		slog.WarnContext(ctx, "ReportMessageHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	ctx = logging.WithMessageID(ctx, req.MessageID)
+	if req.MessageID == "" {
+		slog.WarnContext(ctx, "ReportMessageHandler: message_idが通報リクエストに含まれていません")
+		return
+	}
+	if !h.resolveTipID(ctx, &req, conn) {
+		return
+	}
+
+	// ユーザーIDは接続時に取得した conn.UserID を使用する
+	// メッセージが接続中のTipのものかどうかは、保存されているメッセージのTipでユースケース層が検証する
+	err := h.reportUC.ReportMessage(
+		ctx,
+		domain.TipID(req.TipID),
+		domain.ReportID(generateUUID()),
+		domain.MessageID(req.MessageID),
+		domain.UserID(conn.UserID),
+		domain.ReportReason(req.Reason),
+		req.Content,
+	)
+	if replyRejected(ctx, conn, req.Type, req.TipID, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "ReportMessageHandler: メッセージの通報に失敗", "error", err)
+	}
+}
+
+// 既読位置更新のハンドラー
+// 既読位置が進んだ場合のみ、「既読」表示用にRoomにブロードキャストする
+func (h *OnlyWSMessageHandler) MarkReadHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
+	var req WSRequestMessage
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "MarkReadHandler: WSリクエストのデコードに失敗", "error", err)
+		return
+	}
+	if req.Type != "mark_read" {
+		slog.WarnContext(ctx, "MarkReadHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	ctx = logging.WithMessageID(ctx, req.MessageID)
+	if req.MessageID == "" {
+		slog.WarnContext(ctx, "MarkReadHandler: message_idが既読リクエストに含まれていません")
+		return
+	}
+	if !h.resolveTipID(ctx, &req, conn) {
+		return
+	}
+
+	// ユーザーIDは接続時に取得した conn.UserID を使用する
+	cursor, err := h.readUC.MarkRead(ctx, domain.TipID(req.TipID), domain.UserID(conn.UserID), domain.MessageID(req.MessageID))
+	if replyRejected(ctx, conn, req.Type, req.TipID, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkReadHandler: 既読位置の更新に失敗", "error", err)
+		return
+	}
+	if cursor == nil {
+		return
+	}
+	h.hub.BroadcastToTip(req.TipID, ToReadBroadcastMessage(cursor))
+}
+
+// メッセージのピン留めのハンドラー
+func (h *OnlyWSMessageHandler) PinMessageHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
+	var req WSRequestMessage
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "PinMessageHandler: WSリクエストのデコードに失敗", "error", err)
 		return
 	}
-	if err := h.uc.DeleteMessage(conn.Context(), msg.ID, msg.UserID); err != nil {
-		log.Printf("DeleteMessageHandler: メッセージの削除に失敗: %v", err)
+	if req.Type != "pin" {
+		slog.WarnContext(ctx, "PinMessageHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	ctx = logging.WithMessageID(ctx, req.MessageID)
+	if req.MessageID == "" {
+		slog.WarnContext(ctx, "PinMessageHandler: message_idがピン留めリクエストに含まれていません")
+		return
+	}
+	if !h.resolveTipID(ctx, &req, conn) {
+		return
+	}
+
+	// ユーザーIDは接続時に取得した conn.UserID を使用する
+	pin, err := h.pinUC.PinMessage(ctx, domain.TipID(req.TipID), domain.MessageID(req.MessageID), domain.UserID(conn.UserID))
+	if replyRejected(ctx, conn, req.Type, req.TipID, err) {
 		return
 	}
-	wsResp := ToDeleteBroadcastMessage(msg)
-	bMsg, err := json.Marshal(wsResp)
 	if err != nil {
-		log.Printf("DeleteMessageHandler: ブロードキャスト用メッセージのJSONエンコードに失敗: %v", err)
+		slog.ErrorContext(ctx, "PinMessageHandler: メッセージのピン留めに失敗", "error", err)
+		return
+	}
+	h.hub.BroadcastToTip(req.TipID, ToPinBroadcastMessage(pin))
+}
+
+// メッセージのピン留め解除のハンドラー
+func (h *OnlyWSMessageHandler) UnpinMessageHandler(ctx context.Context, rawMsg []byte, conn *Connection) {
+	var req WSRequestMessage
+	if err := conn.codec.Unmarshal(rawMsg, &req); err != nil {
+		slog.WarnContext(ctx, "UnpinMessageHandler: WSリクエストのデコードに失敗", "error", err)
+		return
+	}
+	if req.Type != "unpin" {
+		slog.WarnContext(ctx, "UnpinMessageHandler: 予期しないリクエストのTypeが含まれています", "type", req.Type)
+		return
+	}
+	ctx = logging.WithMessageID(ctx, req.MessageID)
+	if req.MessageID == "" {
+		slog.WarnContext(ctx, "UnpinMessageHandler: message_idがピン留め解除リクエストに含まれていません")
+		return
+	}
+	if !h.resolveTipID(ctx, &req, conn) {
+		return
+	}
+
+	// ユーザーIDは接続時に取得した conn.UserID を使用する
+	tipID, messageID := domain.TipID(req.TipID), domain.MessageID(req.MessageID)
+	if err := h.pinUC.UnpinMessage(ctx, tipID, messageID, domain.UserID(conn.UserID)); err != nil {
+		if replyRejected(ctx, conn, req.Type, req.TipID, err) {
+			return
+		}
+		slog.ErrorContext(ctx, "UnpinMessageHandler: メッセージのピン留め解除に失敗", "error", err)
+		return
+	}
+	h.hub.BroadcastToTip(req.TipID, ToUnpinBroadcastMessage(tipID, messageID))
+}
+
+// プロトコルのバージョンと機能を合意し、welcomeを返すハンドラー
+// helloは接続ごとに1回だけ受け付ける（合意後にフレームの形が変わるとクライアントが解釈できなくなるため）
+func (h *OnlyWSMessageHandler) HelloHandler(ctx context.Context, req *WSRequestMessage, conn *Connection) {
+	version, ok := negotiateProtocolVersion(req.Version)
+	if !ok {
+		replyError(conn, req.Type, "", errorCodeUnsupportedVersion, "対応していないプロトコルのバージョンです")
+		return
+	}
+	capabilities := negotiateCapabilities(req.Capabilities)
+	if !conn.startSession(version, capabilities) {
+		replyError(conn, req.Type, "", errorCodeInvalidRequest, "helloは接続ごとに1回だけ送信できます")
 		return
 	}
-	room := h.hub.GetRoom(req.TipID)
-	room.Broadcast(bMsg)
+	slog.InfoContext(ctx, "HelloHandler: プロトコルを合意", "requested_version", req.Version, "version", version, "capabilities", capabilities)
+	limits := WelcomeLimits{
+		MaxSubscriptions: h.hub.MaxSubscriptions(),
+		SendBufferSize:   h.hub.SendBufferSize(),
+	}
+	conn.Reply(ToWelcomeMessage(conn.ID, version, capabilities, limits, h.version))
+}
+
+// エラーフレームのcode
+const (
+	errorCodeInvalidRequest     = "invalid_request"
+	errorCodeUnsupportedVersion = "unsupported_version"
+	errorCodeFixedTip           = "fixed_tip"
+	errorCodeSubscriptionLimit  = "subscription_limit"
+	errorCodeNotSubscribed      = "not_subscribed"
+	errorCodeTipMismatch        = "tip_mismatch"
+	errorCodeForbidden          = "forbidden"
+)
+
+// ユーザーがTipのRoomに参加できるかどうか。できなければdomain.ErrTipAccessDeniedを返す
+// /ws/{tipID}への接続は昇格前にこのメソッドで確認する
+func (h *OnlyWSMessageHandler) AuthorizeJoin(ctx context.Context, tipID string, userID string) error {
+	return h.uc.AuthorizeJoin(ctx, domain.TipID(tipID), domain.UserID(userID))
+}
+
+// Tipの購読開始のハンドラー（/wsで接続した場合のみ）
+func (h *OnlyWSMessageHandler) SubscribeHandler(ctx context.Context, req *WSRequestMessage, conn *Connection) {
+	if !h.checkSubscriptionRequest(ctx, req, conn) {
+		return
+	}
+	// 購読するとTipのメッセージがブロードキャストで届くので、/ws/{tipID}への接続と同じく参加できるかを確認する
+	if err := h.AuthorizeJoin(ctx, req.TipID, conn.UserID); err != nil {
+		if !replyRejected(ctx, conn, req.Type, req.TipID, err) {
+			slog.ErrorContext(ctx, "SubscribeHandler: Tipへのアクセス権限の確認に失敗", "error", err)
+		}
+		return
+	}
+	if err := h.hub.Subscribe(conn, req.TipID); err != nil {
+		slog.InfoContext(ctx, "SubscribeHandler: 購読できません", "error", err)
+		replyError(conn, req.Type, req.TipID, errorCodeSubscriptionLimit, err.Error())
+		return
+	}
+	slog.DebugContext(ctx, "SubscribeHandler: 購読を開始")
+	replySubscription(conn, "subscribed", req.TipID, h.hub.Subscriptions(conn))
+}
+
+// Tipの購読終了のハンドラー（/wsで接続した場合のみ）
+func (h *OnlyWSMessageHandler) UnsubscribeHandler(ctx context.Context, req *WSRequestMessage, conn *Connection) {
+	if !h.checkSubscriptionRequest(ctx, req, conn) {
+		return
+	}
+	if !h.hub.Unsubscribe(conn, req.TipID) {
+		replyError(conn, req.Type, req.TipID, errorCodeNotSubscribed, "購読していないTipです")
+		return
+	}
+	slog.DebugContext(ctx, "UnsubscribeHandler: 購読を終了")
+	replySubscription(conn, "unsubscribed", req.TipID, h.hub.Subscriptions(conn))
+}
+
+// 購読の開始・終了のリクエストを検証し、受け付けられない場合はエラーフレームを返してfalseを返す
+// /ws/{tipID}の接続は接続時のTipに固定されているので、購読を変更できない
+func (h *OnlyWSMessageHandler) checkSubscriptionRequest(ctx context.Context, req *WSRequestMessage, conn *Connection) bool {
+	if req.TipID == "" {
+		replyError(conn, req.Type, req.TipID, errorCodeInvalidRequest, "tip_idが指定されていません")
+		return false
+	}
+	if conn.FixedTipID() != "" {
+		replyError(conn, req.Type, req.TipID, errorCodeFixedTip, "この接続は"+conn.FixedTipID()+"に固定されています。複数のTipを購読するには/wsで接続してください")
+		return false
+	}
+	return true
+}
+
+// フレームの操作対象のTipを接続に紐づいたTipで確定させ、req.TipIDに入れる。受け付けられない場合はエラーフレームを返してfalseを返す
+// /ws/{tipID}の接続はtip_idを省略でき、指定する場合は接続時のTipと一致しなければならない
+// /wsの接続はtip_idが必須で、購読中のTipでなければならない
+func (h *OnlyWSMessageHandler) resolveTipID(ctx context.Context, req *WSRequestMessage, conn *Connection) bool {
+	if fixed := conn.FixedTipID(); fixed != "" {
+		if req.TipID != "" && req.TipID != fixed {
+			slog.WarnContext(ctx, "resolveTipID: 接続しているTipと異なるtip_idが指定されました", "type", req.Type, "requested_tip_id", req.TipID)
+			replyError(conn, req.Type, req.TipID, errorCodeTipMismatch, "この接続は"+fixed+"に固定されています")
+			return false
+		}
+		req.TipID = fixed
+		return true
+	}
+	if req.TipID == "" {
+		replyError(conn, req.Type, req.TipID, errorCodeInvalidRequest, "tip_idが指定されていません")
+		return false
+	}
+	if !h.hub.IsSubscribed(conn, req.TipID) {
+		slog.WarnContext(ctx, "resolveTipID: 購読していないTipへの操作です", "type", req.Type)
+		replyError(conn, req.Type, req.TipID, errorCodeNotSubscribed, "購読していないTipです。先にsubscribeしてください")
+		return false
+	}
+	return true
+}
+
+func replySubscription(conn *Connection, messageType string, tipID string, subscriptions []string) {
+	conn.Reply(ToSubscriptionMessage(messageType, tipID, subscriptions))
+}
+
+// リクエストを処理できなかったことを送信者本人にだけ返す
+func replyError(conn *Connection, operation string, tipID string, code string, message string) {
+	conn.Reply(ToErrorMessage(operation, tipID, code, message))
 }
@@ -0,0 +1,43 @@
+package websocket
+
+// 昇格時にHijackした下の接続（TCP）を包み、書き込んだバイト数を数える
+// gorilla/websocketは圧縮後のサイズを返さないので、圧縮率はここで数える
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+type wireConn struct {
+	net.Conn
+	written   atomic.Int64 // 制御フレームも含めて、下の接続に書き込んだバイト数
+	deflate   bool         // permessage-deflateを合意したかどうか（Upgradeで設定し、以後は変えない）
+	threshold int          // このバイト数未満のフレームは圧縮しない
+}
+
+func newWireConn(conn net.Conn) *wireConn {
+	return &wireConn{Conn: conn}
+}
+
+func (c *wireConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// Hijackで返す接続をwireConnに差し替えるResponseWriter
+type wireResponseWriter struct {
+	http.ResponseWriter
+	conn *wireConn
+}
+
+func (w *wireResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = newWireConn(conn)
+	return w.conn, brw, nil
+}
@@ -0,0 +1,61 @@
+package router
+
+// REST API向けのCORSと、別サイトからの状態を変えるリクエストの拒否（CSRF対策）
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minminseo/tipstar-chat-api/presentation/origin"
+)
+
+// プリフライトで許可するメソッドとヘッダー、結果をブラウザにキャッシュさせる時間
+var (
+	corsAllowedMethods = strings.Join([]string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}, ", ")
+	corsAllowedHeaders = strings.Join([]string{"Content-Type", "X-User-Id", "X-Request-Id", "traceparent", "tracestate"}, ", ")
+	corsMaxAge         = strconv.Itoa(int((10 * time.Minute).Seconds()))
+)
+
+// 許可リストのOriginにだけCORSのヘッダーを返すミドルウェア
+// 許可されていないOriginからのプリフライトと、GET以外（状態を変える）リクエストは403で拒否する
+// Originヘッダーが無いリクエスト（ブラウザ以外のクライアントや同一オリジン）はそのまま通す
+func corsMiddleware(origins *origin.Allowlist) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o := r.Header.Get("Origin")
+			if o == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !origins.Allowed(o) {
+				if preflight || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+					slog.WarnContext(r.Context(), "CORS: 許可されていないOriginからのリクエストを拒否", "origin", o, "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+					http.Error(w, "許可されていないOriginです", http.StatusForbidden)
+					return
+				}
+				// 読み取りは処理するが、CORSのヘッダーを付けないのでブラウザはレスポンスを読めない
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", o)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package router
+
+// リクエストごとのログの相関用の属性と、アクセスログ
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/minminseo/tipstar-chat-api/infra/logging"
+)
+
+// middleware.RequestIDが払い出したリクエストIDと、ヘッダーのuser_idをContextに入れるミドルウェア
+// 以降のハンドラーやユースケースでslog.InfoContextなどにContextを渡すと、全ての行にこれらが付く
+func correlationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logging.WithRequestID(r.Context(), middleware.GetReqID(r.Context()))
+		if userID := r.Header.Get("X-User-Id"); userID != "" {
+			ctx = logging.WithUserID(ctx, userID)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// middleware.Loggerの代わりに、JSONのアクセスログを出力するミドルウェア
+// WebSocket・SSE・ロングポーリングは切断されるまでハンドラーから戻らないので、接続要求の時点で記録する
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if kind := streamKind(r); kind != "" {
+			slog.InfoContext(r.Context(), "接続要求", "kind", kind, "method", r.Method, "path", r.URL.Path)
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// プローブは数秒ごとに届くので、通常のログレベルでは出さない
+		level := slog.LevelInfo
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "リクエスト処理",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", chi.RouteContext(r.Context()).RoutePattern(),
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
@@ -0,0 +1,45 @@
+package router
+
+// REST APIのレイテンシのメトリクス（/metricsで公開する）
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "tipstar_http_request_duration_seconds",
+	Help:    "REST APIのリクエストの処理時間（ルートのパターンごと）",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// リクエストの処理時間をルートのパターン（/messages/{tipID}など）ごとに記録するミドルウェア
+// WebSocket・SSE・ロングポーリングは接続している間ずっとハンドラーから戻らないので記録しない
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamKind(r) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// ルートのパターンはルーティング後にしか決まらないので、ハンドラーから戻ってから取得する
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched" // 存在しないパスごとに系列が増えないようにまとめる
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
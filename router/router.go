@@ -1,63 +1,140 @@
 package router
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/presentation/origin"
+	"github.com/minminseo/tipstar-chat-api/presentation/rest"
 	"github.com/minminseo/tipstar-chat-api/presentation/websocket"
 )
 
 func NewRouter(
-	restHandler http.Handler, // 一覧取得ハンドラー
+	restHandler *rest.OnlyRestMessageHandler, // 一覧取得ハンドラー
+	reportHandler *rest.ReportHandler, // 通報とモデレーション系のハンドラー
+	readHandler *rest.ReadCursorHandler, // 既読位置と未読数のハンドラー
+	pinHandler *rest.PinHandler, // ピン留め一覧のハンドラー
+	attachmentHandler *rest.AttachmentHandler, // 添付ファイルのハンドラー
+	webhookHandler *rest.WebhookHandler, // Webhook管理のハンドラー
+	healthHandler *rest.HealthHandler, // 死活監視と準備完了の確認のハンドラー
 	wsHandler *websocket.OnlyWSMessageHandler, // Websocket系の処理のハンドラー
+	sseHandler *websocket.SSEHandler, // WebSocketが使えない環境向けのServer-Sent Eventsのハンドラー
+	longPollHandler *websocket.LongPollHandler, // WebSocketもSSEも使えないクライアント向けのロングポーリングのハンドラー
 	hub *websocket.Hub, // WebSocketのハブ（ルーム管理用）
+	upgrader *websocket.Upgrader, // WebSocketへの昇格（オリジンチェック込み）
+	origins *origin.Allowlist, // REST APIのCORSで許可するOrigin（WebSocketと同じ許可リスト）
 ) http.Handler {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(tracingMiddleware)
+	r.Use(correlationMiddleware)
+	r.Use(accessLogMiddleware)
 	r.Use(middleware.Recoverer)
+	r.Use(metricsMiddleware)
+	// WebSocketの昇格はUpgraderでオリジンチェックするので、ここではREST APIのCORSを扱う
+	r.Use(corsMiddleware(origins))
 	// 認証は各リクエストのヘッダーからuser_idを受け取る前提（今後JWT認証に変更する）
 
+	// Prometheusのメトリクス
+	r.Handle("/metrics", promhttp.Handler())
+
+	// Kubernetesのプローブとロードバランサー向けの死活監視と準備完了の確認
+	r.Get("/healthz", healthHandler.Healthz)
+	r.Get("/readyz", healthHandler.Readyz)
+
 	r.Get("/messages/{tipID}", restHandler.ServeHTTP)
+	r.Get("/messages/{tipID}/pins", pinHandler.ListPins)
+	// 新しいイベントが届くかタイムアウトするまで保留する（WebSocketのブロードキャストと同じイベントで起こされる）
+	r.Get("/messages/{tipID}/poll", longPollHandler.Poll)
+	r.Get("/users/me/mentions", restHandler.GetMyMentions)
+
+	// 添付ファイル（アップロードしたIDをメッセージ送信時に指定して添付する）
+	r.Post("/tips/{tipID}/attachments", attachmentHandler.Upload)
+	r.Get("/attachments/{attachmentID}", attachmentHandler.Download)
+
+	// 既読位置と未読数
+	r.Post("/messages/{tipID}/read", readHandler.MarkRead)
+	r.Get("/users/me/unread", readHandler.GetMyUnreadCounts)
+
+	// 通報とモデレーションキュー（/admin配下はモデレーターのみ。権限チェックはユースケース層で行う）
+	r.Post("/reports", reportHandler.CreateReport)
+	r.Get("/admin/reports", reportHandler.ListPendingReports)
+	r.Post("/admin/reports/{reportID}/resolve", reportHandler.ResolveReport)
+	r.Get("/admin/filter-logs", reportHandler.ListFilterLogs)
 
+	// Webhook（送信・編集・削除のイベントを署名付きでPOSTする）の管理と配信状況の確認
+	r.Post("/admin/webhooks", webhookHandler.CreateWebhook)
+	r.Get("/admin/webhooks", webhookHandler.ListWebhooks)
+	r.Delete("/admin/webhooks/{webhookID}", webhookHandler.DeleteWebhook)
+	r.Get("/admin/webhooks/{webhookID}/deliveries", webhookHandler.ListDeliveries)
+
+	// 1つのTipに固定した接続
 	r.Get("/ws/{tipID}", func(w http.ResponseWriter, r *http.Request) {
-		tipID := chi.URLParam(r, "tipID")
+		serveWS(w, r, chi.URLParam(r, "tipID"), hub, upgrader, wsHandler)
+	})
+	// 複数のTipを購読できる接続（subscribe・unsubscribeのフレームで参加するTipを選ぶ）
+	r.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
+		serveWS(w, r, "", hub, upgrader, wsHandler)
+	})
 
-		// Websocketへの昇格処理。Websocketは双方向通信のためのプロトコル。
-		// connは接続情報、userIDはユーザーID
-		conn, userID, err := websocket.UpgradeHTTP(w, r)
-		if err != nil {
+	// WebSocketを通さないプロキシ向けに、同じRoomのイベントをServer-Sent Eventsで受け取る（受信専用）
+	r.Get("/sse/{tipID}", sseHandler.Stream)
+
+	return r
+}
+
+// WebSocketに昇格し、切断されるまでメッセージを受信し続ける
+// tipIDが空でなければそのTipのRoomに固定して参加させ、空なら購読の無い状態で始める
+func serveWS(w http.ResponseWriter, r *http.Request, tipID string, hub *websocket.Hub, upgrader *websocket.Upgrader, wsHandler *websocket.OnlyWSMessageHandler) {
+	// 固定のTipがある場合は、昇格する前にそのTipのRoomに参加できるかを確認する
+	// X-User-Idヘッダーが無い場合はUpgradeで401を返す
+	if userID := r.Header.Get("X-User-Id"); tipID != "" && userID != "" {
+		err := wsHandler.AuthorizeJoin(r.Context(), tipID, userID)
+		if errors.Is(err, domain.ErrTipAccessDenied) {
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
-
-		// Connection構造体をインスタンス化
-		wsConn := &websocket.Connection{
-			Conn:       conn,
-			UserID:     userID,
-			Send:       make(chan []byte, 256),
-			LastActive: time.Now(),
-			Ctx:        r.Context(),
+		if err != nil {
+			slog.ErrorContext(r.Context(), "WebSocket: Tipへのアクセス権限の確認に失敗", "error", err)
+			http.Error(w, "Tipへのアクセス権限の確認に失敗しました", http.StatusInternalServerError)
+			return
 		}
+	}
 
-		//取得したtipIDに紐づくRoom（実質のチャットルーム）を取得
-		room := hub.GetRoom(tipID)
+	// Websocketへの昇格処理。Websocketは双方向通信のためのプロトコル。
+	// connは接続情報、userIDはユーザーID
+	conn, userID, err := upgrader.Upgrade(w, r)
+	if err != nil {
+		return
+	}
 
-		// 取得したRoomに対して、roomのポインタ型をレシーバーとして持つJoinメソッドにインスタンス化したConnectionオブジェクト（wsConn）を引数として渡す
-		// Joinでは該当roomのclientsフィールドにwsConnが追加される
-		room.Join(wsConn)
+	// Connection構造体をインスタンス化
+	wsConn := websocket.NewConnection(r.Context(), conn, userID, tipID, hub.ConnectionConfig())
 
-		// ゴルーチンで非同期でclientsに存在するクライアントにメッセージを送信する（書き込みは復数ユーザーへのブロードキャストという形になるためゴルーチンを使う（排他制御必須））
-		go wsConn.WritePump()
+	// Hubの管理下に登録し、固定のTipがあればそのRoom（実質のチャットルーム）に参加させる
+	// 参加中のRoomにブロードキャストされたメッセージは、wsConnのSendチャネルに流し込まれる
+	hub.Register(wsConn)
+	if tipID != "" {
+		if err := hub.Subscribe(wsConn, tipID); err != nil {
+			slog.ErrorContext(wsConn.Context(), "WebSocket: Roomへの参加に失敗", "error", err)
+			hub.Disconnect(wsConn)
+			return
+		}
+	}
 
-		// クライアントからのメッセージを受信し、ハンドラーに渡す
-		wsConn.ReadPump(wsHandler.HandleWSMessage)
+	// ゴルーチンで非同期でclientsに存在するクライアントにメッセージを送信する（書き込みは復数ユーザーへのブロードキャストという形になるためゴルーチンを使う（排他制御必須））
+	go wsConn.WritePump()
 
-		// 接続クライアントが切断されたら実行
-		room.Leave(wsConn)
-	})
+	// クライアントからのメッセージを受信し、ハンドラーに渡す
+	wsConn.ReadPump(wsHandler.HandleWSMessage)
 
-	return r
+	// 接続クライアントが切断されたら、参加していた全てのRoomから外す
+	hub.Disconnect(wsConn)
 }
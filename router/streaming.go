@@ -0,0 +1,30 @@
+package router
+
+// 接続している間ずっとハンドラーから戻らないリクエストの判定
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 長時間接続の種類
+const (
+	streamWebSocket = "websocket"
+	streamSSE       = "sse"
+	streamLongPoll  = "long_poll" // 新しいイベントが届くかタイムアウトするまで応答を保留する
+)
+
+// リクエストが長時間接続なら種類を返す。通常のリクエストなら空文字
+// ミドルウェアはルーティングの前に動きルートのパターンがまだ決まらないので、ヘッダーとパスで判定する
+// 処理時間が接続時間になってしまうので、メトリクス・トレース・アクセスログでは通常のリクエストと分けて扱う
+func streamKind(r *http.Request) string {
+	switch {
+	case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+		return streamWebSocket
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/sse/"):
+		return streamSSE
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/messages/") && strings.HasSuffix(r.URL.Path, "/poll"):
+		return streamLongPoll
+	}
+	return ""
+}
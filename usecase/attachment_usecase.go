@@ -0,0 +1,133 @@
+package usecase
+
+// 添付ファイルのユースケース
+
+/*
+ここに実装されているメソッドの処理の流れ
+1. Upload: Tipに書き込めるか確認し、中身から形式を判定して制限を確認した上でBlobStoreに保存し、メタデータを永続化する
+2. Open: Tipの履歴を閲覧できるか確認し、添付先のメッセージが論理削除・非表示でなければBlobStoreから実体を読み出す
+
+メッセージへの紐づけはメッセージ送信時（MessageRepository.SaveMessage）に行う。
+
+*/
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 形式の判定に使う先頭のバイト数（http.DetectContentTypeが見るのは先頭512バイトまで）
+const sniffLen = 512
+
+type attachmentUseCase struct {
+	repo       domain.AttachmentRepository
+	authorizer domain.TipAuthorizer
+	store      domain.BlobStore
+	policy     domain.AttachmentPolicy
+}
+
+// 永続化処理とBlobStore、Tipへのアクセスの判定のインターフェースを依存注入するコンストラクタ関数
+func NewAttachmentUseCase(
+	repo domain.AttachmentRepository,
+	authorizer domain.TipAuthorizer,
+	store domain.BlobStore,
+	policy domain.AttachmentPolicy,
+) AttachmentUsecase {
+	uc := &attachmentUseCase{
+		repo:       repo,
+		authorizer: authorizer,
+		store:      store,
+		policy:     policy,
+	}
+	return &tracedAttachmentUsecase{inner: uc}
+}
+
+// 添付ファイルアップロードのユースケース
+func (uc *attachmentUseCase) Upload(ctx context.Context, id domain.AttachmentID, tipID domain.TipID, uploaderID domain.UserID, fileName string, r io.Reader) (*domain.Attachment, error) {
+	if err := uc.authorizer.Authorize(ctx, tipID, uploaderID, domain.TipAccessWrite); err != nil {
+		return nil, err
+	}
+
+	// クライアントが申告したContent-Typeは信用せず、中身の先頭から判定する
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head)
+	if !uc.policy.IsAllowedType(contentType) {
+		return nil, domain.ErrAttachmentTypeNotAllowed
+	}
+
+	// 上限+1バイトまで読んで、上限を超えていたら保存した実体を消してエラーにする
+	attachment := &domain.Attachment{
+		ID:          id,
+		TipID:       tipID,
+		UploaderID:  uploaderID,
+		FileName:    fileName,
+		ContentType: contentType,
+		StorageKey:  string(id),
+		CreatedAt:   time.Now(),
+	}
+	counter := &countingReader{r: io.LimitReader(br, uc.policy.MaxSize+1)}
+	if err := uc.store.Put(ctx, attachment.StorageKey, counter); err != nil {
+		return nil, err
+	}
+	if counter.n > uc.policy.MaxSize {
+		uc.deleteBlob(ctx, attachment.StorageKey)
+		return nil, domain.ErrAttachmentTooLarge
+	}
+	attachment.Size = counter.n
+
+	if err := uc.repo.SaveAttachment(ctx, attachment); err != nil {
+		uc.deleteBlob(ctx, attachment.StorageKey)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// 添付ファイルダウンロードのユースケース
+// 論理削除・非表示になったメッセージの添付ファイルは存在しないものとして扱う
+func (uc *attachmentUseCase) Open(ctx context.Context, id domain.AttachmentID, userID domain.UserID) (*domain.Attachment, io.ReadCloser, error) {
+	attachment, err := uc.repo.FetchAttachmentByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := uc.authorizer.Authorize(ctx, attachment.TipID, userID, domain.TipAccessRead); err != nil {
+		return nil, nil, err
+	}
+	if attachment.Hidden {
+		return nil, nil, domain.ErrAttachmentNotFound
+	}
+	body, err := uc.store.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, body, nil
+}
+
+// 保存に失敗した場合の後始末。失敗してもログだけ残す
+func (uc *attachmentUseCase) deleteBlob(ctx context.Context, key string) {
+	if err := uc.store.Delete(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "添付ファイルの実体の削除に失敗", "storage_key", key, "error", err)
+	}
+}
+
+// 読み出したバイト数を数えるio.Reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,42 @@
+package usecase
+
+// 永続化前にメッセージ内容を検査するフィルターの連鎖
+
+import (
+	"context"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 登録順に適用するフィルターの連鎖
+type ContentFilterChain []domain.ContentFilter
+
+// フィルターを順番に適用し、発動した結果を全て返す
+// rejectが発動した時点で残りのフィルターは適用せず、*domain.ContentRejectedErrorを返す
+func (c ContentFilterChain) Run(ctx context.Context, msg *domain.Message) ([]*domain.FilterResult, error) {
+	var results []*domain.FilterResult
+	for _, f := range c {
+		result, err := f.Apply(ctx, msg)
+		if err != nil {
+			return results, err
+		}
+		if result == nil {
+			continue
+		}
+		results = append(results, result)
+		if result.Action == domain.FilterActionReject {
+			return results, &domain.ContentRejectedError{FilterName: result.FilterName, Reason: result.Reason}
+		}
+	}
+	return results, nil
+}
+
+// 保存に成功したメッセージを、記録が必要なフィルターに渡す
+// 拒否されたメッセージや保存に失敗したメッセージは記録しないよう、永続化の後に呼ぶ
+func (c ContentFilterChain) Record(ctx context.Context, msg *domain.Message) {
+	for _, f := range c {
+		if r, ok := f.(domain.ContentFilterRecorder); ok {
+			r.Record(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package usecase
+
+// WebSocket以外（SSE）でRoomのイベントを受け取る購読者向けのユースケース
+
+/*
+ここに実装されているメソッドの処理の流れ
+1. AuthorizeJoin: WebSocketのRoomへの参加と同じく、Tipの履歴を閲覧できるか確認する
+2. FetchEventsAfter: 再接続した購読者が最後に受け取ったイベントより後のイベントを、アウトボックスから取得する
+
+ライブのイベントはアウトボックスのリレーからHubEventSink経由でRoomにブロードキャストされるので、ここでは扱わない。
+
+*/
+
+import (
+	"context"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type eventStreamUseCase struct {
+	outboxRepo domain.OutboxRepository
+	authorizer domain.TipAuthorizer
+	moderators domain.ModeratorSet
+}
+
+// 永続化処理とTipへのアクセスの判定のインターフェースを依存注入するコンストラクタ関数
+func NewEventStreamUseCase(outboxRepo domain.OutboxRepository, authorizer domain.TipAuthorizer, moderators domain.ModeratorSet) EventStreamUsecase {
+	uc := &eventStreamUseCase{outboxRepo: outboxRepo, authorizer: authorizer, moderators: moderators}
+	return &tracedEventStreamUsecase{inner: uc}
+}
+
+// Roomへの参加の可否の確認（OnlyWSUsecase.AuthorizeJoinと同じ権限を求める）
+func (uc *eventStreamUseCase) AuthorizeJoin(ctx context.Context, tipID domain.TipID, userID domain.UserID) error {
+	return authorizeTip(ctx, uc.authorizer, uc.moderators, tipID, userID, domain.TipAccessRead)
+}
+
+// 取りこぼしたイベントの取得のユースケース
+func (uc *eventStreamUseCase) FetchEventsAfter(ctx context.Context, tipID domain.TipID, afterSeq int64, limit int) ([]*domain.OutboxEvent, error) {
+	return uc.outboxRepo.FetchEventsAfter(ctx, tipID, afterSeq, limit)
+}
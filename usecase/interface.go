@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"io"
 
 	"github.com/minminseo/tipstar-chat-api/domain"
 )
@@ -10,12 +11,87 @@ import (
 
 // HTTP経由（Rest API）のリクエスト用のユースケース
 type OnlyRestUsecase interface {
-	GetAllMessages(ctx context.Context, tipID string) ([]*domain.Message, error)
+	GetAllMessages(ctx context.Context, tipID string, userID string) ([]*domain.Message, error) // 履歴を閲覧できないTipならdomain.ErrTipAccessDeniedを返す
+	GetMentions(ctx context.Context, userID string, limit int) ([]*domain.Mention, error)       // 自分がメンションされたメッセージを全Tip横断で取得（履歴を閲覧できないTipのものは除く）
 }
 
 // Websocket経由のリクエストのユースケース
 type OnlyWSUsecase interface {
-	ExecuteSendMessage(ctx context.Context, msg *domain.Message) error
-	EditMessage(ctx context.Context, messageID domain.MessageID, userID domain.UserID, newContent string) error
-	DeleteMessage(ctx context.Context, messageID domain.MessageID, userID domain.UserID) error
+	ExecuteSendMessage(ctx context.Context, msg *domain.Message) (*domain.Message, bool, error) // 保存した（再送の場合は元の）メッセージと、再送だったかどうかを返す
+	// expectedVersionはクライアントが想定しているバージョン（0なら検証しない）。一致しなければ*domain.VersionConflictErrorを返す
+	// tipIDは操作を受け付けたTip。保存されているメッセージのTipと異なればdomain.ErrTipMismatchを返す
+	EditMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID, newContent string, expectedVersion int64) (*domain.Message, error)
+	DeleteMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID, expectedVersion int64) error
+	NotifyMentions(ctx context.Context, msg *domain.Message, userIDs []domain.UserID) error // msg内のメンションのうちuserIDsに含まれるユーザーに通知する
+	AuthorizeJoin(ctx context.Context, tipID domain.TipID, userID domain.UserID) error      // TipのRoomに参加できるかどうか。できなければdomain.ErrTipAccessDeniedを返す
+}
+
+// 通報（モデレーション）のユースケース。WebSocket経由とHTTP経由の両方から使う
+type ReportUsecase interface {
+	// メッセージを通報する。通報数が閾値に達したら自動非表示にする（非表示のブロードキャストはアウトボックスのリレー経由で行われる）
+	// tipIDは通報を受け付けたTip（WebSocketの接続のTip）。空でなければ、保存されているメッセージのTipと異なる場合にdomain.ErrTipMismatchを返す
+	ReportMessage(ctx context.Context, tipID domain.TipID, reportID domain.ReportID, messageID domain.MessageID, reporterID domain.UserID, reason domain.ReportReason, detail string) error
+	// 未対応の通報を通報対象のメッセージと一緒に一覧取得する（モデレーターのみ）
+	ListPendingReports(ctx context.Context, moderatorID domain.UserID) ([]*domain.Report, error)
+	// 通報を却下または削除で解決する（モデレーターのみ）。メッセージの変更のブロードキャストはアウトボックスのリレー経由で行われる
+	// 他のモデレーターが先に解決していた場合はdomain.ErrReportAlreadyResolvedを返す
+	ResolveReport(ctx context.Context, reportID domain.ReportID, moderatorID domain.UserID, action domain.ReportAction) error
+	// コンテンツフィルターの発動記録を新しい順に取得する（モデレーターのみ）
+	ListFilterLogs(ctx context.Context, moderatorID domain.UserID, limit int) ([]*domain.FilterLog, error)
+}
+
+// 既読位置と未読数のユースケース。WebSocket経由とHTTP経由の両方から使う
+type ReadCursorUsecase interface {
+	// 指定したメッセージまで既読にする。既読位置が進んだ場合のみ更新後の既読位置を返す（進まなければnil）
+	MarkRead(ctx context.Context, tipID domain.TipID, userID domain.UserID, messageID domain.MessageID) (*domain.ReadCursor, error)
+	// 既読位置を持っているTipごとの未読数を取得する
+	GetUnreadCounts(ctx context.Context, userID domain.UserID) ([]*domain.UnreadCount, error)
+}
+
+// ピン留めのユースケース。WebSocket経由とHTTP経由の両方から使う
+type PinUsecase interface {
+	// メッセージをピン留めする（Tipのオーナー・モデレーターのみ）
+	PinMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID) (*domain.Pin, error)
+	// メッセージのピン留めを解除する（Tipのオーナー・モデレーターのみ）
+	UnpinMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID) error
+	// Tip内のピン留めを並び順で取得する
+	ListPins(ctx context.Context, tipID domain.TipID, userID domain.UserID) ([]*domain.Pin, error)
+}
+
+// 添付ファイルのユースケース（HTTP経由のアップロードとダウンロード）
+type AttachmentUsecase interface {
+	// 添付ファイルをアップロードする（Tipに書き込めるユーザーのみ）。形式は中身から判定する
+	Upload(ctx context.Context, id domain.AttachmentID, tipID domain.TipID, uploaderID domain.UserID, fileName string, r io.Reader) (*domain.Attachment, error)
+	// 添付ファイルを読み出す（Tipの履歴を閲覧できるユーザーのみ）。呼び出し側で実体をCloseする
+	Open(ctx context.Context, id domain.AttachmentID, userID domain.UserID) (*domain.Attachment, io.ReadCloser, error)
+}
+
+// WebSocket以外（SSE）でRoomのイベントを受け取る購読者向けのユースケース
+type EventStreamUsecase interface {
+	// TipのRoomに参加できるかどうか。できなければdomain.ErrTipAccessDeniedを返す
+	AuthorizeJoin(ctx context.Context, tipID domain.TipID, userID domain.UserID) error
+	// 再接続時の取りこぼしの再送用に、連番がafterSeqより大きいTipのイベントを連番の順に取得する（AuthorizeJoinで確認した後に呼ぶ）
+	FetchEventsAfter(ctx context.Context, tipID domain.TipID, afterSeq int64, limit int) ([]*domain.OutboxEvent, error)
+}
+
+// アウトボックスのイベントをシンクに配信し続けるリレー
+type OutboxRelay interface {
+	Run(ctx context.Context) // ctxがキャンセルされるまで配信と掃除を繰り返す
+	Wake()                   // 次のポーリングを待たずに配信させる（イベントを書き込んだ直後に呼ぶ）
+}
+
+// Webhookの登録と配信状況の確認のユースケース（モデレーターのみ）
+type WebhookUsecase interface {
+	// tipIDがnilなら全Tipのイベントを受け取るWebhookとして登録する
+	RegisterWebhook(ctx context.Context, id domain.WebhookID, tipID *domain.TipID, url string, secret string, moderatorID domain.UserID) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context, moderatorID domain.UserID) ([]*domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id domain.WebhookID, moderatorID domain.UserID) error
+	// Webhookへの配信を試行の履歴付きで新しい順に取得する
+	ListDeliveries(ctx context.Context, id domain.WebhookID, moderatorID domain.UserID, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+// アウトボックスのシンクとしてイベントをWebhookへの配信として登録し、リトライしながら送信し続ける
+type WebhookDispatcher interface {
+	domain.EventSink
+	Run(ctx context.Context) // ctxがキャンセルされるまで試行日時を過ぎた配信を送信し続ける
 }
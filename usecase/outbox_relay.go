@@ -0,0 +1,203 @@
+package usecase
+
+// アウトボックスのリレー
+
+/*
+処理の流れ
+1. メッセージの保存・編集・論理削除等と同じトランザクションでoutboxに書き込まれたイベントに、コミット順の連番を振る
+   連番を振る処理はインスタンス間で直列化されるので、どのインスタンスから見ても連番の順にイベントが見える
+2. インスタンスごとのシンク（このインスタンスのHub）に、前回届けた連番より後のイベントを連番の順に届ける
+   配信済みかどうかに関係なく全てのインスタンスが届けるので、どのインスタンスに接続しているクライアントにも届く
+3. 永続的なシンク（Webhook等）には、未配信のイベントを確保したインスタンスだけが届ける
+   全ての永続的なシンクに配信できたイベントだけを配信済みにする
+   配信に失敗したイベントは確保の期限が切れた後に配信し直す（少なくとも1回の配信）。失敗しても他のシンクと後続のイベントの配信は止めない
+4. 配信済みになってから保持期間を過ぎたイベントを定期的に削除する
+
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 1回のリレーで取り出すイベントの最大件数
+const outboxBatchSize = 100
+
+// 配信済みのイベントを掃除する間隔
+const outboxCleanupInterval = time.Hour
+
+type outboxRelay struct {
+	repo         domain.OutboxRepository
+	localSinks   []domain.EventSink // インスタンスごとに全てのイベントを届けるシンク（Hub）
+	durableSinks []domain.EventSink // 全インスタンスで1回だけ届けるシンク（Webhook等）
+	pollInterval time.Duration      // Wakeが呼ばれなくてもこの間隔でイベントを確認する（他のインスタンスが書き込んだイベントやリトライ用）
+	retention    time.Duration      // 配信済みのイベントを保持する期間
+	claimLease   time.Duration      // 永続的なシンクへの配信のために確保したイベントを、他のインスタンスに渡さない期間
+	wake         chan struct{}
+
+	// インスタンスごとのシンクに届けた最後の連番（Runのゴルーチンだけが触る）
+	// 起動前のイベントは届けないので、最初のリレーで最新の連番から始める
+	localSeq     int64
+	localStarted bool
+}
+
+// アウトボックスの永続化処理のインターフェースと、配信先のシンクを依存注入するコンストラクタ関数
+func NewOutboxRelay(repo domain.OutboxRepository, localSinks, durableSinks []domain.EventSink, pollInterval, retention, claimLease time.Duration) OutboxRelay {
+	return &outboxRelay{
+		repo:         repo,
+		localSinks:   localSinks,
+		durableSinks: durableSinks,
+		pollInterval: pollInterval,
+		retention:    retention,
+		claimLease:   claimLease,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// 次のポーリングを待たずにリレーさせる（イベントを書き込んだ直後に呼ぶ）
+// すでにリレー待ちの場合は何もしない
+func (r *outboxRelay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ctxがキャンセルされるまでリレーと掃除を繰り返す
+func (r *outboxRelay) Run(ctx context.Context) {
+	poll := time.NewTicker(r.pollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+			r.relay(ctx)
+		case <-poll.C:
+			r.relay(ctx)
+		case <-cleanup.C:
+			if _, err := r.repo.DeletePublishedBefore(ctx, time.Now().Add(-r.retention)); err != nil {
+				slog.ErrorContext(ctx, "OutboxRelay: 配信済みのイベントの削除に失敗", "error", err)
+			}
+		}
+	}
+}
+
+// 連番を振り、インスタンスごとのシンクと永続的なシンクにそれぞれ届ける
+func (r *outboxRelay) relay(ctx context.Context) {
+	r.sequence(ctx)
+	r.relayLocal(ctx)
+	r.relayDurable(ctx)
+}
+
+// 連番のないイベントがなくなるまで連番を振る
+// 失敗しても、すでに連番のあるイベントの配信は続ける
+func (r *outboxRelay) sequence(ctx context.Context) {
+	for {
+		n, err := r.repo.SequenceEvents(ctx, outboxBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "OutboxRelay: イベントへの連番の採番に失敗", "error", err)
+			return
+		}
+		if n < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// 前回届けた連番より後のイベントを、インスタンスごとのシンクに連番の順に届ける
+func (r *outboxRelay) relayLocal(ctx context.Context) {
+	if !r.localStarted {
+		seq, err := r.repo.LatestSeq(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "OutboxRelay: 最新の連番の取得に失敗", "error", err)
+			return
+		}
+		r.localSeq = seq
+		r.localStarted = true
+	}
+	for {
+		events, err := r.repo.FetchSequencedAfter(ctx, r.localSeq, outboxBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "OutboxRelay: 連番より後のイベントの取得に失敗", "seq", r.localSeq, "error", err)
+			return
+		}
+		for _, event := range events {
+			// 届けられなかったクライアントは、再接続時の再送やメッセージの再取得で追いつく
+			if err := r.publish(ctx, event, r.localSinks); err != nil {
+				slog.ErrorContext(ctx, "OutboxRelay: イベントの配信に失敗", eventAttrs(event, "error", err)...)
+			}
+			r.localSeq = event.Seq
+		}
+		if len(events) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// 未配信のイベントを確保し、永続的なシンクに届けて配信済みにする
+// 配信に失敗したイベントは配信済みにせず、確保の期限が切れた後に（どのインスタンスからでも）配信し直す
+func (r *outboxRelay) relayDurable(ctx context.Context) {
+	for {
+		events, err := r.repo.ClaimPendingEvents(ctx, r.claimLease, outboxBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "OutboxRelay: 未配信のイベントの確保に失敗", "error", err)
+			return
+		}
+		for _, event := range events {
+			if err := r.publish(ctx, event, r.durableSinks); err != nil {
+				slog.ErrorContext(ctx, "OutboxRelay: イベントの配信に失敗", eventAttrs(event, "error", err)...)
+				continue
+			}
+			if err := r.repo.MarkPublished(ctx, event.ID); err != nil {
+				slog.ErrorContext(ctx, "OutboxRelay: イベントを配信済みにできません", eventAttrs(event, "error", err)...)
+			}
+		}
+		if len(events) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// 1つのイベントをsinksの全てに配信する。失敗したシンクがあっても残りのシンクには配信する
+// リレーのポーリング自体はスパンにせず、配信したイベントごとにスパンを記録する
+func (r *outboxRelay) publish(ctx context.Context, event *domain.OutboxEvent, sinks []domain.EventSink) error {
+	ctx, span := startSpan(ctx, "OutboxRelay.publish",
+		attribute.Int64("event_id", event.ID),
+		attribute.Int64("event_seq", event.Seq),
+		attribute.String("event_type", string(event.Type)),
+		tipIDAttr(string(event.Message.TipID)),
+		messageIDAttr(string(event.Message.ID)),
+	)
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("シンク%sへの配信に失敗: %w", sink.Name(), err))
+		}
+	}
+	err := errors.Join(errs...)
+	endSpan(span, err)
+	return err
+}
+
+// イベントのログに付ける属性
+func eventAttrs(event *domain.OutboxEvent, args ...any) []any {
+	return append([]any{
+		"event_id", event.ID,
+		"event_seq", event.Seq,
+		"event_type", string(event.Type),
+		"tip_id", string(event.Message.TipID),
+		"user_id", string(event.Message.UserID),
+		"message_id", string(event.Message.ID),
+	}, args...)
+}
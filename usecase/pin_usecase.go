@@ -0,0 +1,103 @@
+package usecase
+
+// ピン留めのユースケース
+
+/*
+ここに実装されているメソッドの処理の流れ
+1. PinMessage / UnpinMessage: Tipに書き込めることと、ユーザーがTipのオーナー・モデレーター（または全体のモデレーター）であることを確認し、ピン留めを追加・削除する
+2. ListPins: Tipの履歴を閲覧できるか確認し、Tip内のピン留めをメッセージと一緒に並び順で取得する
+
+*/
+
+import (
+	"context"
+	"errors"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type pinUseCase struct {
+	msgRepo    domain.MessageRepository
+	pinRepo    domain.PinRepository
+	memberRepo domain.TipMemberRepository
+	moderators domain.ModeratorSet // 全Tipでピン留めができるユーザー
+	authorizer domain.TipAuthorizer
+	maxPins    int // 1つのTipにピン留めできるメッセージ数の上限
+}
+
+// 永続化処理のインターフェースを依存注入するコンストラクタ関数
+func NewPinUseCase(
+	msgRepo domain.MessageRepository,
+	pinRepo domain.PinRepository,
+	memberRepo domain.TipMemberRepository,
+	moderators domain.ModeratorSet,
+	authorizer domain.TipAuthorizer,
+	maxPins int,
+) PinUsecase {
+	uc := &pinUseCase{
+		msgRepo:    msgRepo,
+		pinRepo:    pinRepo,
+		memberRepo: memberRepo,
+		moderators: moderators,
+		authorizer: authorizer,
+		maxPins:    maxPins,
+	}
+	return &tracedPinUsecase{inner: uc}
+}
+
+// ピン留めのユースケース
+func (uc *pinUseCase) PinMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID) (*domain.Pin, error) {
+	if err := uc.checkPermission(ctx, tipID, userID); err != nil {
+		return nil, err
+	}
+	msg, err := uc.msgRepo.FetchMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, errors.New("ピン留めするメッセージが見つかりません")
+	}
+	pin, err := domain.NewPin(tipID, msg, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.pinRepo.SavePin(ctx, pin, uc.maxPins); err != nil {
+		return nil, err
+	}
+	pin.Message = msg
+	return pin, nil
+}
+
+// ピン留め解除のユースケース
+func (uc *pinUseCase) UnpinMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID) error {
+	if err := uc.checkPermission(ctx, tipID, userID); err != nil {
+		return err
+	}
+	return uc.pinRepo.DeletePin(ctx, tipID, messageID)
+}
+
+// ピン留め一覧取得のユースケース
+func (uc *pinUseCase) ListPins(ctx context.Context, tipID domain.TipID, userID domain.UserID) ([]*domain.Pin, error) {
+	if err := authorizeTip(ctx, uc.authorizer, uc.moderators, tipID, userID, domain.TipAccessRead); err != nil {
+		return nil, err
+	}
+	return uc.pinRepo.FetchPinsByTipID(ctx, tipID)
+}
+
+// ピン留めの権限確認。全体のモデレーターか、Tipに書き込めるオーナー・モデレーターであればよい
+func (uc *pinUseCase) checkPermission(ctx context.Context, tipID domain.TipID, userID domain.UserID) error {
+	if uc.moderators.IsModerator(userID) {
+		return nil
+	}
+	if err := uc.authorizer.Authorize(ctx, tipID, userID, domain.TipAccessWrite); err != nil {
+		return err
+	}
+	role, err := uc.memberRepo.FetchTipRole(ctx, tipID, userID)
+	if err != nil {
+		return err
+	}
+	if !role.CanManageMessages() {
+		return domain.ErrPinForbidden
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package usecase
+
+// 既読位置と未読数のユースケース
+
+/*
+ここに実装されているメソッドの処理の流れ
+1. MarkRead: 既読にするメッセージを取得し、ドメイン層のロジックで既読位置を進めて永続化する
+2. GetUnreadCounts: 既読位置を持っているTipごとの未読数を取得する
+
+*/
+
+import (
+	"context"
+	"errors"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type readCursorUseCase struct {
+	msgRepo    domain.MessageRepository
+	cursorRepo domain.ReadCursorRepository
+	moderators domain.ModeratorSet  // Tipへのアクセス制御の対象外になるユーザー
+	authorizer domain.TipAuthorizer // 既読にできるのは履歴を閲覧できるTipだけ
+}
+
+// 永続化処理のインターフェースを依存注入するコンストラクタ関数
+func NewReadCursorUseCase(msgRepo domain.MessageRepository, cursorRepo domain.ReadCursorRepository, moderators domain.ModeratorSet, authorizer domain.TipAuthorizer) ReadCursorUsecase {
+	uc := &readCursorUseCase{msgRepo: msgRepo, cursorRepo: cursorRepo, moderators: moderators, authorizer: authorizer}
+	return &tracedReadCursorUsecase{inner: uc}
+}
+
+// 既読位置更新のユースケース
+func (uc *readCursorUseCase) MarkRead(ctx context.Context, tipID domain.TipID, userID domain.UserID, messageID domain.MessageID) (*domain.ReadCursor, error) {
+	if err := authorizeTip(ctx, uc.authorizer, uc.moderators, tipID, userID, domain.TipAccessRead); err != nil {
+		return nil, err
+	}
+	msg, err := uc.msgRepo.FetchMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, errors.New("既読にするメッセージが見つかりません")
+	}
+	cursor, err := uc.cursorRepo.FetchReadCursor(ctx, tipID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		cursor = &domain.ReadCursor{TipID: tipID, UserID: userID}
+	}
+	advanced, err := cursor.AdvanceTo(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		return nil, nil
+	}
+	// 保存済みの位置の方が新しかった場合も、進まなかったものとして扱う
+	saved, err := uc.cursorRepo.SaveReadCursor(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, nil
+	}
+	return cursor, nil
+}
+
+// 未読数取得のユースケース
+func (uc *readCursorUseCase) GetUnreadCounts(ctx context.Context, userID domain.UserID) ([]*domain.UnreadCount, error) {
+	return uc.cursorRepo.FetchUnreadCounts(ctx, userID)
+}
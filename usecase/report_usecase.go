@@ -0,0 +1,166 @@
+package usecase
+
+// 通報（モデレーション）のユースケース
+
+/*
+ここに実装されているメソッドの処理の流れ
+1. ReportMessage: 通報を保存し、通報者数が閾値に達したらメッセージを自動で非表示にする（非表示のイベントをアウトボックスに書き込む）
+2. ListPendingReports: モデレーター向けに未対応の通報をメッセージの文脈付きで取得する
+3. ResolveReport: モデレーターが通報を却下（非表示解除）または削除で解決する
+   モデレーターによる他人のメッセージの削除はここからだけ行い、通報の解決記録（resolved_by）を監査の記録にする
+4. ListFilterLogs: モデレーター向けにコンテンツフィルターが発動した記録を取得する
+
+*/
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type reportUseCase struct {
+	msgRepo       domain.MessageRepository
+	reportRepo    domain.ReportRepository
+	filterLogRepo domain.FilterLogRepository
+	relay         OutboxRelay          // 削除・非表示・非表示解除のイベントを書き込んだ後に起こす（ブロードキャストはリレー経由で行う）
+	moderators    domain.ModeratorSet  // 通報一覧の取得と解決ができるユーザー
+	authorizer    domain.TipAuthorizer // 通報できるのは履歴を閲覧できるTipのメッセージだけ
+	hideThreshold int                  // この人数以上から通報されたら自動非表示にする（0以下なら自動非表示しない）
+}
+
+// 永続化処理のインターフェースと、アウトボックスのリレーを依存注入するコンストラクタ関数
+func NewReportUseCase(
+	msgRepo domain.MessageRepository,
+	reportRepo domain.ReportRepository,
+	filterLogRepo domain.FilterLogRepository,
+	relay OutboxRelay,
+	moderators domain.ModeratorSet,
+	authorizer domain.TipAuthorizer,
+	hideThreshold int,
+) ReportUsecase {
+	uc := &reportUseCase{
+		msgRepo:       msgRepo,
+		reportRepo:    reportRepo,
+		filterLogRepo: filterLogRepo,
+		relay:         relay,
+		moderators:    moderators,
+		authorizer:    authorizer,
+		hideThreshold: hideThreshold,
+	}
+	return &tracedReportUsecase{inner: uc}
+}
+
+// メッセージ通報のユースケース
+// 対象のTipはクライアントが送ってきた値ではなく、保存されているメッセージのTipで検証する
+func (uc *reportUseCase) ReportMessage(ctx context.Context, tipID domain.TipID, reportID domain.ReportID, messageID domain.MessageID, reporterID domain.UserID, reason domain.ReportReason, detail string) error {
+	msg, err := uc.msgRepo.FetchMessageByID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return errors.New("通報対象のメッセージが見つかりません")
+	}
+	if tipID != "" && msg.TipID != tipID {
+		return domain.ErrTipMismatch
+	}
+	if err := authorizeTip(ctx, uc.authorizer, uc.moderators, msg.TipID, reporterID, domain.TipAccessRead); err != nil {
+		return err
+	}
+	report, err := domain.NewReport(reportID, msg, reporterID, reason, detail)
+	if err != nil {
+		return err
+	}
+	if err := uc.reportRepo.SaveReport(ctx, report); err != nil {
+		return err
+	}
+
+	if uc.hideThreshold <= 0 {
+		return nil
+	}
+	count, err := uc.reportRepo.CountReportsByMessageID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if count < uc.hideThreshold || !msg.Hide() {
+		return nil
+	}
+	slog.InfoContext(ctx, "通報数が閾値に達したためHiddenAtの実体書き換え成功（永続化前）", "message_id", string(msg.ID), "tip_id", string(msg.TipID), "report_count", count)
+	if err := uc.msgRepo.UpdateHiddenAt(ctx, msg); err != nil {
+		return err
+	}
+	uc.relay.Wake()
+	return nil
+}
+
+// 未対応の通報一覧取得のユースケース
+func (uc *reportUseCase) ListPendingReports(ctx context.Context, moderatorID domain.UserID) ([]*domain.Report, error) {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return nil, domain.ErrNotModerator
+	}
+	return uc.reportRepo.FetchPendingReports(ctx)
+}
+
+// 通報解決のユースケース
+// 判断はメッセージ単位で行うので、同じメッセージに対する未対応の通報はまとめて解決する
+// 通報の解決とメッセージの変更は同じトランザクションで行い、他のモデレーターが先に解決していればdomain.ErrReportAlreadyResolvedを返す
+func (uc *reportUseCase) ResolveReport(ctx context.Context, reportID domain.ReportID, moderatorID domain.UserID, action domain.ReportAction) error {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return domain.ErrNotModerator
+	}
+	status, err := action.ResolvedStatus()
+	if err != nil {
+		return err
+	}
+	report, err := uc.reportRepo.FetchReportByID(ctx, reportID)
+	if err != nil {
+		return err
+	}
+	if report.Status != domain.ReportStatusPending {
+		return domain.ErrReportAlreadyResolved
+	}
+	msg, err := uc.msgRepo.FetchMessageByID(ctx, report.MessageID)
+	if err != nil {
+		return err
+	}
+
+	// 通報の解決と一緒に永続化するメッセージの変更。変更が無ければnil
+	var changed *domain.Message
+	switch action {
+	case domain.ReportActionDelete:
+		// 通報後に投稿者自身が削除していた場合は削除処理をスキップして通報だけ解決する
+		// 所有権の検証は行わない（モデレーターかどうかは冒頭で確認済み）
+		if msg.DeletedAt == nil {
+			if err := msg.SetDeletedByModerator(); err != nil {
+				return err
+			}
+			changed = msg
+		}
+	case domain.ReportActionDismiss:
+		// 自動非表示になっていた場合は元に戻す
+		if msg.Unhide() {
+			changed = msg
+		}
+	}
+
+	if err := uc.reportRepo.ResolveReportsByMessageID(ctx, msg.ID, status, moderatorID, changed); err != nil {
+		return err
+	}
+	if changed == nil {
+		return nil
+	}
+	if action == domain.ReportActionDelete {
+		slog.InfoContext(ctx, "通報の解決によるモデレーター削除", "message_id", string(msg.ID), "tip_id", string(msg.TipID), "report_id", string(reportID))
+	}
+	uc.relay.Wake()
+	return nil
+}
+
+// コンテンツフィルターの発動記録取得のユースケース
+func (uc *reportUseCase) ListFilterLogs(ctx context.Context, moderatorID domain.UserID, limit int) ([]*domain.FilterLog, error) {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return nil, domain.ErrNotModerator
+	}
+	return uc.filterLogRepo.FetchRecentFilterLogs(ctx, limit)
+}
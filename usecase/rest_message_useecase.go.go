@@ -12,20 +12,56 @@ package usecase
 */
 import (
 	"context"
+	"errors"
 
 	"github.com/minminseo/tipstar-chat-api/domain"
 )
 
 type onlyRestMessageUseCase struct {
-	repo domain.MessageRepository
+	repo        domain.MessageRepository
+	mentionRepo domain.MentionRepository
+	moderators  domain.ModeratorSet  // Tipへのアクセス制御の対象外になるユーザー
+	authorizer  domain.TipAuthorizer // 履歴を閲覧できるかどうかの判定
 }
 
 // 永続化処理のインターフェースのメソッドをユースケース層に依存注入するコンストラクタ関数
-func NewOnlyRestMessageUseCase(repo domain.MessageRepository) OnlyRestUsecase {
-	return &onlyRestMessageUseCase{repo: repo}
+func NewOnlyRestMessageUseCase(repo domain.MessageRepository, mentionRepo domain.MentionRepository, moderators domain.ModeratorSet, authorizer domain.TipAuthorizer) OnlyRestUsecase {
+	uc := &onlyRestMessageUseCase{repo: repo, mentionRepo: mentionRepo, moderators: moderators, authorizer: authorizer}
+	return &tracedOnlyRestUsecase{inner: uc}
 }
 
 // メッセージ一覧取得のユースケース
-func (uc *onlyRestMessageUseCase) GetAllMessages(ctx context.Context, tipID string) ([]*domain.Message, error) {
-	return uc.repo.GetAllMessages(domain.TipID(tipID))
+func (uc *onlyRestMessageUseCase) GetAllMessages(ctx context.Context, tipID string, userID string) ([]*domain.Message, error) {
+	if err := uc.authorizer.Authorize(ctx, domain.TipID(tipID), domain.UserID(userID), domain.TipAccessRead); err != nil {
+		return nil, err
+	}
+	return uc.repo.GetAllMessages(ctx, domain.TipID(tipID))
+}
+
+// 自分がメンションされたメッセージ一覧取得のユースケース
+// メンションされていても履歴を閲覧できないTip（メンバーから外れた等）のメッセージは返さない
+// 取得したlimit件から除くので、返す件数がlimitより少なくなる場合がある
+func (uc *onlyRestMessageUseCase) GetMentions(ctx context.Context, userID string, limit int) ([]*domain.Mention, error) {
+	mentions, err := uc.mentionRepo.FetchMentionsByUserID(ctx, domain.UserID(userID), limit)
+	if err != nil {
+		return nil, err
+	}
+	// 同じTipのメンションが続くことが多いので、Tipごとに1回だけ判定する
+	readable := make(map[domain.TipID]bool)
+	visible := make([]*domain.Mention, 0, len(mentions))
+	for _, mention := range mentions {
+		ok, checked := readable[mention.TipID]
+		if !checked {
+			err := authorizeTip(ctx, uc.authorizer, uc.moderators, mention.TipID, domain.UserID(userID), domain.TipAccessRead)
+			if err != nil && !errors.Is(err, domain.ErrTipAccessDenied) {
+				return nil, err
+			}
+			ok = err == nil
+			readable[mention.TipID] = ok
+		}
+		if ok {
+			visible = append(visible, mention)
+		}
+	}
+	return visible, nil
 }
@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// Tipへのアクセスの確認。全体のモデレーターは参加していないTipも読み書きできる（通報の解決で他人のメッセージを削除する等）
+func authorizeTip(ctx context.Context, authorizer domain.TipAuthorizer, moderators domain.ModeratorSet, tipID domain.TipID, userID domain.UserID, access domain.TipAccess) error {
+	if moderators.IsModerator(userID) {
+		return nil
+	}
+	return authorizer.Authorize(ctx, tipID, userID, access)
+}
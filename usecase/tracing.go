@@ -0,0 +1,250 @@
+package usecase
+
+// ユースケースのメソッドごとにOpenTelemetryのスパンを記録するラッパー
+// 各コンストラクタが実装をラップして返すので、プレゼンテーション層からの呼び出しは全てスパンの内側で行われる
+// 属性にはIDだけを付け、メッセージの内容は付けない
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+var tracer = otel.Tracer("github.com/minminseo/tipstar-chat-api/usecase")
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// エラーがあればスパンに記録してから終了する
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func tipIDAttr(tipID string) attribute.KeyValue {
+	return attribute.String("tip_id", tipID)
+}
+
+func userIDAttr(userID string) attribute.KeyValue {
+	return attribute.String("user_id", userID)
+}
+
+func messageIDAttr(messageID string) attribute.KeyValue {
+	return attribute.String("message_id", messageID)
+}
+
+type tracedOnlyRestUsecase struct {
+	inner OnlyRestUsecase
+}
+
+func (t *tracedOnlyRestUsecase) GetAllMessages(ctx context.Context, tipID string, userID string) ([]*domain.Message, error) {
+	ctx, span := startSpan(ctx, "OnlyRestUsecase.GetAllMessages", tipIDAttr(tipID), userIDAttr(userID))
+	msgs, err := t.inner.GetAllMessages(ctx, tipID, userID)
+	endSpan(span, err)
+	return msgs, err
+}
+
+func (t *tracedOnlyRestUsecase) GetMentions(ctx context.Context, userID string, limit int) ([]*domain.Mention, error) {
+	ctx, span := startSpan(ctx, "OnlyRestUsecase.GetMentions", userIDAttr(userID))
+	mentions, err := t.inner.GetMentions(ctx, userID, limit)
+	endSpan(span, err)
+	return mentions, err
+}
+
+type tracedOnlyWSUsecase struct {
+	inner OnlyWSUsecase
+}
+
+func (t *tracedOnlyWSUsecase) ExecuteSendMessage(ctx context.Context, msg *domain.Message) (*domain.Message, bool, error) {
+	ctx, span := startSpan(ctx, "OnlyWSUsecase.ExecuteSendMessage", tipIDAttr(string(msg.TipID)), userIDAttr(string(msg.UserID)), messageIDAttr(string(msg.ID)))
+	saved, duplicate, err := t.inner.ExecuteSendMessage(ctx, msg)
+	span.SetAttributes(attribute.Bool("duplicate", duplicate))
+	endSpan(span, err)
+	return saved, duplicate, err
+}
+
+func (t *tracedOnlyWSUsecase) EditMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID, newContent string, expectedVersion int64) (*domain.Message, error) {
+	ctx, span := startSpan(ctx, "OnlyWSUsecase.EditMessage", tipIDAttr(string(tipID)), messageIDAttr(string(messageID)), userIDAttr(string(userID)), attribute.Int64("expected_version", expectedVersion))
+	msg, err := t.inner.EditMessage(ctx, tipID, messageID, userID, newContent, expectedVersion)
+	endSpan(span, err)
+	return msg, err
+}
+
+func (t *tracedOnlyWSUsecase) DeleteMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID, expectedVersion int64) error {
+	ctx, span := startSpan(ctx, "OnlyWSUsecase.DeleteMessage", tipIDAttr(string(tipID)), messageIDAttr(string(messageID)), userIDAttr(string(userID)), attribute.Int64("expected_version", expectedVersion))
+	err := t.inner.DeleteMessage(ctx, tipID, messageID, userID, expectedVersion)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedOnlyWSUsecase) AuthorizeJoin(ctx context.Context, tipID domain.TipID, userID domain.UserID) error {
+	ctx, span := startSpan(ctx, "OnlyWSUsecase.AuthorizeJoin", tipIDAttr(string(tipID)), userIDAttr(string(userID)))
+	err := t.inner.AuthorizeJoin(ctx, tipID, userID)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedOnlyWSUsecase) NotifyMentions(ctx context.Context, msg *domain.Message, userIDs []domain.UserID) error {
+	ctx, span := startSpan(ctx, "OnlyWSUsecase.NotifyMentions", messageIDAttr(string(msg.ID)), attribute.Int("targets", len(userIDs)))
+	err := t.inner.NotifyMentions(ctx, msg, userIDs)
+	endSpan(span, err)
+	return err
+}
+
+type tracedReportUsecase struct {
+	inner ReportUsecase
+}
+
+func (t *tracedReportUsecase) ReportMessage(ctx context.Context, tipID domain.TipID, reportID domain.ReportID, messageID domain.MessageID, reporterID domain.UserID, reason domain.ReportReason, detail string) error {
+	ctx, span := startSpan(ctx, "ReportUsecase.ReportMessage", tipIDAttr(string(tipID)), messageIDAttr(string(messageID)), userIDAttr(string(reporterID)), attribute.String("reason", string(reason)))
+	err := t.inner.ReportMessage(ctx, tipID, reportID, messageID, reporterID, reason, detail)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedReportUsecase) ListPendingReports(ctx context.Context, moderatorID domain.UserID) ([]*domain.Report, error) {
+	ctx, span := startSpan(ctx, "ReportUsecase.ListPendingReports", userIDAttr(string(moderatorID)))
+	reports, err := t.inner.ListPendingReports(ctx, moderatorID)
+	endSpan(span, err)
+	return reports, err
+}
+
+func (t *tracedReportUsecase) ResolveReport(ctx context.Context, reportID domain.ReportID, moderatorID domain.UserID, action domain.ReportAction) error {
+	ctx, span := startSpan(ctx, "ReportUsecase.ResolveReport", attribute.String("report_id", string(reportID)), userIDAttr(string(moderatorID)), attribute.String("action", string(action)))
+	err := t.inner.ResolveReport(ctx, reportID, moderatorID, action)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedReportUsecase) ListFilterLogs(ctx context.Context, moderatorID domain.UserID, limit int) ([]*domain.FilterLog, error) {
+	ctx, span := startSpan(ctx, "ReportUsecase.ListFilterLogs", userIDAttr(string(moderatorID)))
+	logs, err := t.inner.ListFilterLogs(ctx, moderatorID, limit)
+	endSpan(span, err)
+	return logs, err
+}
+
+type tracedReadCursorUsecase struct {
+	inner ReadCursorUsecase
+}
+
+func (t *tracedReadCursorUsecase) MarkRead(ctx context.Context, tipID domain.TipID, userID domain.UserID, messageID domain.MessageID) (*domain.ReadCursor, error) {
+	ctx, span := startSpan(ctx, "ReadCursorUsecase.MarkRead", tipIDAttr(string(tipID)), userIDAttr(string(userID)), messageIDAttr(string(messageID)))
+	cursor, err := t.inner.MarkRead(ctx, tipID, userID, messageID)
+	endSpan(span, err)
+	return cursor, err
+}
+
+func (t *tracedReadCursorUsecase) GetUnreadCounts(ctx context.Context, userID domain.UserID) ([]*domain.UnreadCount, error) {
+	ctx, span := startSpan(ctx, "ReadCursorUsecase.GetUnreadCounts", userIDAttr(string(userID)))
+	counts, err := t.inner.GetUnreadCounts(ctx, userID)
+	endSpan(span, err)
+	return counts, err
+}
+
+type tracedPinUsecase struct {
+	inner PinUsecase
+}
+
+func (t *tracedPinUsecase) PinMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID) (*domain.Pin, error) {
+	ctx, span := startSpan(ctx, "PinUsecase.PinMessage", tipIDAttr(string(tipID)), messageIDAttr(string(messageID)), userIDAttr(string(userID)))
+	pin, err := t.inner.PinMessage(ctx, tipID, messageID, userID)
+	endSpan(span, err)
+	return pin, err
+}
+
+func (t *tracedPinUsecase) UnpinMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID) error {
+	ctx, span := startSpan(ctx, "PinUsecase.UnpinMessage", tipIDAttr(string(tipID)), messageIDAttr(string(messageID)), userIDAttr(string(userID)))
+	err := t.inner.UnpinMessage(ctx, tipID, messageID, userID)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedPinUsecase) ListPins(ctx context.Context, tipID domain.TipID, userID domain.UserID) ([]*domain.Pin, error) {
+	ctx, span := startSpan(ctx, "PinUsecase.ListPins", tipIDAttr(string(tipID)), userIDAttr(string(userID)))
+	pins, err := t.inner.ListPins(ctx, tipID, userID)
+	endSpan(span, err)
+	return pins, err
+}
+
+type tracedAttachmentUsecase struct {
+	inner AttachmentUsecase
+}
+
+func (t *tracedAttachmentUsecase) Upload(ctx context.Context, id domain.AttachmentID, tipID domain.TipID, uploaderID domain.UserID, fileName string, r io.Reader) (*domain.Attachment, error) {
+	ctx, span := startSpan(ctx, "AttachmentUsecase.Upload", attribute.String("attachment_id", string(id)), tipIDAttr(string(tipID)), userIDAttr(string(uploaderID)))
+	attachment, err := t.inner.Upload(ctx, id, tipID, uploaderID, fileName, r)
+	if attachment != nil {
+		span.SetAttributes(attribute.Int64("size", attachment.Size), attribute.String("content_type", attachment.ContentType))
+	}
+	endSpan(span, err)
+	return attachment, err
+}
+
+// 実体の読み出しは呼び出し側で行うので、スパンには読み出しの開始までが含まれる
+func (t *tracedAttachmentUsecase) Open(ctx context.Context, id domain.AttachmentID, userID domain.UserID) (*domain.Attachment, io.ReadCloser, error) {
+	ctx, span := startSpan(ctx, "AttachmentUsecase.Open", attribute.String("attachment_id", string(id)), userIDAttr(string(userID)))
+	attachment, body, err := t.inner.Open(ctx, id, userID)
+	endSpan(span, err)
+	return attachment, body, err
+}
+
+type tracedEventStreamUsecase struct {
+	inner EventStreamUsecase
+}
+
+func (t *tracedEventStreamUsecase) AuthorizeJoin(ctx context.Context, tipID domain.TipID, userID domain.UserID) error {
+	ctx, span := startSpan(ctx, "EventStreamUsecase.AuthorizeJoin", tipIDAttr(string(tipID)), userIDAttr(string(userID)))
+	err := t.inner.AuthorizeJoin(ctx, tipID, userID)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedEventStreamUsecase) FetchEventsAfter(ctx context.Context, tipID domain.TipID, afterSeq int64, limit int) ([]*domain.OutboxEvent, error) {
+	ctx, span := startSpan(ctx, "EventStreamUsecase.FetchEventsAfter", tipIDAttr(string(tipID)), attribute.Int64("after_seq", afterSeq))
+	events, err := t.inner.FetchEventsAfter(ctx, tipID, afterSeq, limit)
+	span.SetAttributes(attribute.Int("events", len(events)))
+	endSpan(span, err)
+	return events, err
+}
+
+type tracedWebhookUsecase struct {
+	inner WebhookUsecase
+}
+
+func (t *tracedWebhookUsecase) RegisterWebhook(ctx context.Context, id domain.WebhookID, tipID *domain.TipID, url string, secret string, moderatorID domain.UserID) (*domain.Webhook, error) {
+	ctx, span := startSpan(ctx, "WebhookUsecase.RegisterWebhook", attribute.String("webhook_id", string(id)), userIDAttr(string(moderatorID)))
+	webhook, err := t.inner.RegisterWebhook(ctx, id, tipID, url, secret, moderatorID)
+	endSpan(span, err)
+	return webhook, err
+}
+
+func (t *tracedWebhookUsecase) ListWebhooks(ctx context.Context, moderatorID domain.UserID) ([]*domain.Webhook, error) {
+	ctx, span := startSpan(ctx, "WebhookUsecase.ListWebhooks", userIDAttr(string(moderatorID)))
+	webhooks, err := t.inner.ListWebhooks(ctx, moderatorID)
+	endSpan(span, err)
+	return webhooks, err
+}
+
+func (t *tracedWebhookUsecase) DeleteWebhook(ctx context.Context, id domain.WebhookID, moderatorID domain.UserID) error {
+	ctx, span := startSpan(ctx, "WebhookUsecase.DeleteWebhook", attribute.String("webhook_id", string(id)), userIDAttr(string(moderatorID)))
+	err := t.inner.DeleteWebhook(ctx, id, moderatorID)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedWebhookUsecase) ListDeliveries(ctx context.Context, id domain.WebhookID, moderatorID domain.UserID, limit int) ([]*domain.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookUsecase.ListDeliveries", attribute.String("webhook_id", string(id)), userIDAttr(string(moderatorID)))
+	deliveries, err := t.inner.ListDeliveries(ctx, id, moderatorID, limit)
+	endSpan(span, err)
+	return deliveries, err
+}
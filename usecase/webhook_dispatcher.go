@@ -0,0 +1,222 @@
+package usecase
+
+// Webhookへのイベントの配信
+
+/*
+処理の流れ
+1. アウトボックスのリレーからシンクとしてイベントを受け取り、対象のWebhook（Tip単位と全Tip対象）ごとに配信を登録する
+   ここでは送信せず登録だけを行うので、Webhookの受信側が遅くても他のシンクへの配信は止まらない
+2. 別のゴルーチンで、試行日時を過ぎた配信を署名付きでPOSTする
+3. 2xxが返れば成功、それ以外は指数バックオフで次の試行日時を決め、最大試行回数に達したらdeadにする
+
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+// 1回で取り出す配信の最大件数
+const webhookBatchSize = 50
+
+// Webhookの受信側に送るJSON
+type webhookPayload struct {
+	EventID    int64          `json:"event_id"`
+	Type       string         `json:"type"`        // "message.sent", "message.edited", "message.deleted", "message.hidden", "message.unhidden", "message.unpinned"
+	OccurredAt int64          `json:"occurred_at"` // Unixタイムスタンプ（イベントの発生時刻）
+	Message    webhookMessage `json:"message"`
+}
+
+type webhookMessage struct {
+	ID            string   `json:"id"`
+	TipID         string   `json:"tip_id"`
+	UserID        string   `json:"user_id"`
+	Content       string   `json:"content"`
+	CreatedAt     int64    `json:"created_at"`
+	UpdatedAt     int64    `json:"updated_at"`
+	DeletedAt     *int64   `json:"deleted_at,omitempty"`
+	IsHidden      bool     `json:"is_hidden"`
+	Version       int64    `json:"version"`
+	Mentions      []string `json:"mentions,omitempty"`
+	AttachmentIDs []string `json:"attachment_ids,omitempty"`
+}
+
+type webhookDispatcher struct {
+	webhookRepo  domain.WebhookRepository
+	deliveryRepo domain.WebhookDeliveryRepository
+	sender       domain.WebhookSender
+	policy       domain.WebhookRetryPolicy
+	pollInterval time.Duration // Wakeが呼ばれなくてもこの間隔でリトライ待ちの配信を確認する
+	claimLease   time.Duration // 送信のために確保した配信を、他のインスタンスに渡さない期間（送信のタイムアウトより長くする）
+	wake         chan struct{}
+}
+
+// 永続化処理のインターフェースと送信の実装を依存注入するコンストラクタ関数
+func NewWebhookDispatcher(
+	webhookRepo domain.WebhookRepository,
+	deliveryRepo domain.WebhookDeliveryRepository,
+	sender domain.WebhookSender,
+	policy domain.WebhookRetryPolicy,
+	pollInterval time.Duration,
+	claimLease time.Duration,
+) WebhookDispatcher {
+	return &webhookDispatcher{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		sender:       sender,
+		policy:       policy,
+		pollInterval: pollInterval,
+		claimLease:   claimLease,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func (d *webhookDispatcher) Name() string {
+	return "webhook"
+}
+
+// イベントを対象のWebhookへの配信として登録する（アウトボックスのリレーから呼ばれる）
+func (d *webhookDispatcher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	webhooks, err := d.webhookRepo.FetchWebhooksForTip(ctx, event.Message.TipID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(toWebhookPayload(event))
+	if err != nil {
+		return err
+	}
+	deliveries := make([]*domain.WebhookDelivery, 0, len(webhooks))
+	for _, w := range webhooks {
+		deliveries = append(deliveries, domain.NewWebhookDelivery(w.ID, event, payload))
+	}
+	if err := d.deliveryRepo.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// ctxがキャンセルされるまで、試行日時を過ぎた配信を送信し続ける
+func (d *webhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+		d.deliverDue(ctx)
+	}
+}
+
+// 試行日時を過ぎた配信がなくなるまで、確保して送信する
+// 複数のインスタンスで動かしても、確保した配信は確保したインスタンスだけが送信する
+func (d *webhookDispatcher) deliverDue(ctx context.Context) {
+	for {
+		deliveries, err := d.deliveryRepo.ClaimDueDeliveries(ctx, time.Now(), d.claimLease, webhookBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "WebhookDispatcher: 配信待ちの配信の確保に失敗", "error", err)
+			return
+		}
+		webhooks := make(map[domain.WebhookID]*domain.Webhook)
+		for _, delivery := range deliveries {
+			webhook, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				webhook, err = d.webhookRepo.FetchWebhookByID(ctx, delivery.WebhookID)
+				if errors.Is(err, domain.ErrWebhookNotFound) {
+					continue // 取得後にWebhookが削除された（配信もCASCADEで削除されている）
+				}
+				if err != nil {
+					slog.ErrorContext(ctx, "WebhookDispatcher: Webhookの取得に失敗", "webhook_id", string(delivery.WebhookID), "error", err)
+					return
+				}
+				webhooks[delivery.WebhookID] = webhook
+			}
+			d.deliver(ctx, webhook, delivery)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// 1つの配信を送信し、結果を記録する
+func (d *webhookDispatcher) deliver(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) {
+	ctx, span := startSpan(ctx, "WebhookDispatcher.deliver",
+		attribute.String("webhook_id", string(webhook.ID)),
+		attribute.Int64("delivery_id", int64(delivery.ID)),
+		attribute.String("event_type", string(delivery.EventType)),
+	)
+	defer span.End()
+	timestamp := time.Now().Unix()
+	req := &domain.WebhookRequest{
+		URL: webhook.URL,
+		Headers: map[string]string{
+			"X-Tipstar-Event":     string(delivery.EventType),
+			"X-Tipstar-Delivery":  strconv.FormatInt(int64(delivery.ID), 10),
+			"X-Tipstar-Timestamp": strconv.FormatInt(timestamp, 10),
+			"X-Tipstar-Signature": domain.SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload),
+		},
+		Body: delivery.Payload,
+	}
+	statusCode, sendErr := d.sender.Send(ctx, req)
+	attempt := delivery.RecordAttempt(statusCode, sendErr, d.policy)
+	span.SetAttributes(attribute.Int("attempt", attempt.AttemptNo), attribute.Int("status_code", statusCode))
+	if delivery.Status != domain.WebhookDeliverySucceeded {
+		span.SetStatus(codes.Error, attempt.Error)
+		slog.WarnContext(ctx, "WebhookDispatcher: 配信に失敗",
+			"webhook_id", string(webhook.ID),
+			"delivery_id", int64(delivery.ID),
+			"attempt", attempt.AttemptNo,
+			"status", string(delivery.Status),
+			"error", attempt.Error,
+		)
+	}
+	if err := d.deliveryRepo.RecordAttempt(ctx, delivery, attempt); err != nil {
+		slog.ErrorContext(ctx, "WebhookDispatcher: 試行結果の記録に失敗", "delivery_id", int64(delivery.ID), "error", err)
+	}
+}
+
+func toWebhookPayload(event *domain.OutboxEvent) *webhookPayload {
+	msg := event.Message
+	p := &webhookPayload{
+		EventID:    event.ID,
+		Type:       string(event.Type),
+		OccurredAt: event.CreatedAt.Unix(),
+		Message: webhookMessage{
+			ID:        string(msg.ID),
+			TipID:     string(msg.TipID),
+			UserID:    string(msg.UserID),
+			Content:   msg.Content,
+			CreatedAt: msg.CreatedAt.Unix(),
+			UpdatedAt: msg.UpdatedAt.Unix(),
+			IsHidden:  msg.HiddenAt != nil,
+			Version:   msg.Version,
+		},
+	}
+	if msg.DeletedAt != nil {
+		deletedAt := msg.DeletedAt.Unix()
+		p.Message.DeletedAt = &deletedAt
+	}
+	for _, id := range msg.Mentions {
+		p.Message.Mentions = append(p.Message.Mentions, string(id))
+	}
+	for _, id := range msg.AttachmentIDs {
+		p.Message.AttachmentIDs = append(p.Message.AttachmentIDs, string(id))
+	}
+	return p
+}
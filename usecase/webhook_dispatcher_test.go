@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+	"github.com/minminseo/tipstar-chat-api/infra/webhook"
+)
+
+// テスト用のインメモリのWebhookの永続化処理
+type memoryWebhookRepository struct {
+	webhooks map[domain.WebhookID]*domain.Webhook
+}
+
+func (r *memoryWebhookRepository) SaveWebhook(ctx context.Context, w *domain.Webhook) error {
+	r.webhooks[w.ID] = w
+	return nil
+}
+
+func (r *memoryWebhookRepository) FetchWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	webhooks := make([]*domain.Webhook, 0, len(r.webhooks))
+	for _, w := range r.webhooks {
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) FetchWebhookByID(ctx context.Context, id domain.WebhookID) (*domain.Webhook, error) {
+	w, ok := r.webhooks[id]
+	if !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+func (r *memoryWebhookRepository) FetchWebhooksForTip(ctx context.Context, tipID domain.TipID) ([]*domain.Webhook, error) {
+	var webhooks []*domain.Webhook
+	for _, w := range r.webhooks {
+		if w.TipID == nil || *w.TipID == tipID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) DeleteWebhook(ctx context.Context, id domain.WebhookID) error {
+	if _, ok := r.webhooks[id]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+// テスト用のインメモリの配信の永続化処理
+type memoryWebhookDeliveryRepository struct {
+	deliveries []*domain.WebhookDelivery
+}
+
+func (r *memoryWebhookDeliveryRepository) EnqueueDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	for _, d := range deliveries {
+		d.ID = domain.WebhookDeliveryID(len(r.deliveries) + 1)
+		r.deliveries = append(r.deliveries, d)
+	}
+	return nil
+}
+
+func (r *memoryWebhookDeliveryRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	var due []*domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == domain.WebhookDeliveryPending && !d.NextAttemptAt.After(now) && len(due) < limit {
+			d.NextAttemptAt = now.Add(lease)
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) RecordAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	delivery.History = append(delivery.History, attempt)
+	return nil
+}
+
+func (r *memoryWebhookDeliveryRepository) FetchDeliveriesByWebhookID(ctx context.Context, webhookID domain.WebhookID, limit int) ([]*domain.WebhookDelivery, error) {
+	var deliveries []*domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+// リトライ待ちの配信の次の試行日時を過去にして、待ち時間が過ぎたことにする
+func (r *memoryWebhookDeliveryRepository) fastForward() {
+	for _, d := range r.deliveries {
+		d.NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+// 受信側が受け取ったリクエスト
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// statusesの順にステータスを返す受信側（使い切った後は最後のステータスを返し続ける）
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	received []receivedWebhook
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, receivedWebhook{header: r.Header.Clone(), body: body})
+	status := rc.statuses[min(len(rc.received), len(rc.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func (rc *webhookReceiver) requests() []receivedWebhook {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedWebhook(nil), rc.received...)
+}
+
+type webhookDispatcherFixture struct {
+	dispatcher *webhookDispatcher
+	receiver   *webhookReceiver
+	deliveries *memoryWebhookDeliveryRepository
+	secret     string
+}
+
+func newWebhookDispatcherFixture(t *testing.T, policy domain.WebhookRetryPolicy, statuses ...int) *webhookDispatcherFixture {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	const secret = "test-secret"
+	w, err := domain.NewWebhook("wh-1", nil, server.URL, secret, "moderator-1")
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+	webhooks := &memoryWebhookRepository{webhooks: map[domain.WebhookID]*domain.Webhook{w.ID: w}}
+	deliveries := &memoryWebhookDeliveryRepository{}
+	d := NewWebhookDispatcher(webhooks, deliveries, webhook.NewHTTPWebhookSender(5*time.Second), policy, time.Minute, time.Minute).(*webhookDispatcher)
+	return &webhookDispatcherFixture{dispatcher: d, receiver: receiver, deliveries: deliveries, secret: secret}
+}
+
+// メッセージの送信イベントを配信として登録する
+func (f *webhookDispatcherFixture) publish(t *testing.T) *domain.WebhookDelivery {
+	t.Helper()
+	now := time.Now()
+	event := &domain.OutboxEvent{
+		ID:   1,
+		Type: domain.OutboxEventMessageSent,
+		Message: &domain.Message{
+			ID:        "msg-1",
+			TipID:     "tip-1",
+			UserID:    "user-1",
+			Content:   "こんにちは",
+			CreatedAt: now,
+			UpdatedAt: now,
+			Version:   1,
+		},
+		CreatedAt: now,
+	}
+	if err := f.dispatcher.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(f.deliveries.deliveries) != 1 {
+		t.Fatalf("登録された配信の数 = %d, want 1", len(f.deliveries.deliveries))
+	}
+	return f.deliveries.deliveries[0]
+}
+
+var testWebhookRetryPolicy = domain.WebhookRetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Minute,
+	MaxDelay:    90 * time.Second,
+}
+
+func TestWebhookDispatcher_SignsPayload(t *testing.T) {
+	f := newWebhookDispatcherFixture(t, testWebhookRetryPolicy, http.StatusNoContent)
+	delivery := f.publish(t)
+
+	f.dispatcher.deliverDue(context.Background())
+
+	reqs := f.receiver.requests()
+	if len(reqs) != 1 {
+		t.Fatalf("受信したリクエストの数 = %d, want 1", len(reqs))
+	}
+	req := reqs[0]
+	timestamp, err := strconv.ParseInt(req.header.Get("X-Tipstar-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Tipstar-Timestampが整数ではありません: %q", req.header.Get("X-Tipstar-Timestamp"))
+	}
+	if got, want := req.header.Get("X-Tipstar-Signature"), domain.SignWebhookPayload(f.secret, timestamp, req.body); got != want {
+		t.Errorf("X-Tipstar-Signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get("X-Tipstar-Event"); got != string(domain.OutboxEventMessageSent) {
+		t.Errorf("X-Tipstar-Event = %q, want %q", got, domain.OutboxEventMessageSent)
+	}
+	if got, want := req.header.Get("X-Tipstar-Delivery"), strconv.FormatInt(int64(delivery.ID), 10); got != want {
+		t.Errorf("X-Tipstar-Delivery = %q, want %q", got, want)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if string(req.body) != string(delivery.Payload) {
+		t.Errorf("本文 = %s, want %s", req.body, delivery.Payload)
+	}
+	if delivery.Status != domain.WebhookDeliverySucceeded {
+		t.Errorf("Status = %q, want %q", delivery.Status, domain.WebhookDeliverySucceeded)
+	}
+}
+
+func TestWebhookDispatcher_RetriesWithBackoff(t *testing.T) {
+	f := newWebhookDispatcherFixture(t, testWebhookRetryPolicy, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK)
+	delivery := f.publish(t)
+	ctx := context.Background()
+
+	for attempts := 1; attempts <= 2; attempts++ {
+		f.dispatcher.deliverDue(ctx)
+		if delivery.Status != domain.WebhookDeliveryPending {
+			t.Fatalf("%d回目の失敗後のStatus = %q, want %q", attempts, delivery.Status, domain.WebhookDeliveryPending)
+		}
+		attempt := delivery.History[len(delivery.History)-1]
+		if attempt.AttemptNo != attempts || attempt.Error == "" {
+			t.Fatalf("%d回目の試行結果 = %+v", attempts, attempt)
+		}
+		if got, want := delivery.NextAttemptAt.Sub(attempt.AttemptedAt), testWebhookRetryPolicy.Backoff(attempts); got != want {
+			t.Errorf("%d回目の失敗後の待ち時間 = %v, want %v", attempts, got, want)
+		}
+
+		// 待ち時間が過ぎるまでは送信しない
+		f.dispatcher.deliverDue(ctx)
+		if got := len(f.receiver.requests()); got != attempts {
+			t.Fatalf("待ち時間中に送信されました: 受信したリクエストの数 = %d, want %d", got, attempts)
+		}
+		f.deliveries.fastForward()
+	}
+
+	f.dispatcher.deliverDue(ctx)
+	if delivery.Status != domain.WebhookDeliverySucceeded {
+		t.Errorf("Status = %q, want %q", delivery.Status, domain.WebhookDeliverySucceeded)
+	}
+	if delivery.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", delivery.Attempts)
+	}
+
+	// リトライでも同じ本文を送り、試行ごとのタイムスタンプで署名し直す
+	reqs := f.receiver.requests()
+	for i, req := range reqs {
+		if string(req.body) != string(reqs[0].body) {
+			t.Errorf("%d回目の本文が1回目と異なります", i+1)
+		}
+		timestamp, _ := strconv.ParseInt(req.header.Get("X-Tipstar-Timestamp"), 10, 64)
+		if got, want := req.header.Get("X-Tipstar-Signature"), domain.SignWebhookPayload(f.secret, timestamp, req.body); got != want {
+			t.Errorf("%d回目のX-Tipstar-Signature = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestWebhookDispatcher_DeadAfterMaxAttempts(t *testing.T) {
+	f := newWebhookDispatcherFixture(t, testWebhookRetryPolicy, http.StatusBadGateway)
+	delivery := f.publish(t)
+	ctx := context.Background()
+
+	for i := 0; i < testWebhookRetryPolicy.MaxAttempts; i++ {
+		f.dispatcher.deliverDue(ctx)
+		f.deliveries.fastForward()
+	}
+	if delivery.Status != domain.WebhookDeliveryDead {
+		t.Fatalf("Status = %q, want %q", delivery.Status, domain.WebhookDeliveryDead)
+	}
+	if len(delivery.History) != testWebhookRetryPolicy.MaxAttempts {
+		t.Errorf("試行の履歴の数 = %d, want %d", len(delivery.History), testWebhookRetryPolicy.MaxAttempts)
+	}
+	last := delivery.History[len(delivery.History)-1]
+	if last.StatusCode != http.StatusBadGateway || last.Error == "" {
+		t.Errorf("最後の試行結果 = %+v", last)
+	}
+
+	// deadになった配信はリトライしない
+	f.dispatcher.deliverDue(ctx)
+	if got := len(f.receiver.requests()); got != testWebhookRetryPolicy.MaxAttempts {
+		t.Errorf("受信したリクエストの数 = %d, want %d", got, testWebhookRetryPolicy.MaxAttempts)
+	}
+}
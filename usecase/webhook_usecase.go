@@ -0,0 +1,74 @@
+package usecase
+
+// Webhookの登録と配信状況の確認のユースケース（モデレーターのみ）
+
+/*
+ここに実装されているメソッドの処理の流れ
+1. RegisterWebhook: Tip単位または全Tip対象のWebhookを登録する
+2. ListWebhooks: 登録済みのWebhookを一覧取得する
+3. DeleteWebhook: Webhookを削除する（配信と試行の履歴も削除される）
+4. ListDeliveries: Webhookへの配信を試行の履歴付きで新しい順に取得する
+
+実際の配信はWebhookDispatcherが行う。
+
+*/
+
+import (
+	"context"
+
+	"github.com/minminseo/tipstar-chat-api/domain"
+)
+
+type webhookUseCase struct {
+	webhookRepo  domain.WebhookRepository
+	deliveryRepo domain.WebhookDeliveryRepository
+	moderators   domain.ModeratorSet // Webhookを管理できるユーザー
+}
+
+// 永続化処理のインターフェースを依存注入するコンストラクタ関数
+func NewWebhookUseCase(webhookRepo domain.WebhookRepository, deliveryRepo domain.WebhookDeliveryRepository, moderators domain.ModeratorSet) WebhookUsecase {
+	uc := &webhookUseCase{webhookRepo: webhookRepo, deliveryRepo: deliveryRepo, moderators: moderators}
+	return &tracedWebhookUsecase{inner: uc}
+}
+
+// Webhook登録のユースケース
+func (uc *webhookUseCase) RegisterWebhook(ctx context.Context, id domain.WebhookID, tipID *domain.TipID, url string, secret string, moderatorID domain.UserID) (*domain.Webhook, error) {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return nil, domain.ErrNotModerator
+	}
+	webhook, err := domain.NewWebhook(id, tipID, url, secret, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.webhookRepo.SaveWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Webhook一覧取得のユースケース
+func (uc *webhookUseCase) ListWebhooks(ctx context.Context, moderatorID domain.UserID) ([]*domain.Webhook, error) {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return nil, domain.ErrNotModerator
+	}
+	return uc.webhookRepo.FetchWebhooks(ctx)
+}
+
+// Webhook削除のユースケース
+func (uc *webhookUseCase) DeleteWebhook(ctx context.Context, id domain.WebhookID, moderatorID domain.UserID) error {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return domain.ErrNotModerator
+	}
+	return uc.webhookRepo.DeleteWebhook(ctx, id)
+}
+
+// Webhookへの配信一覧取得のユースケース
+func (uc *webhookUseCase) ListDeliveries(ctx context.Context, id domain.WebhookID, moderatorID domain.UserID, limit int) ([]*domain.WebhookDelivery, error) {
+	if !uc.moderators.IsModerator(moderatorID) {
+		return nil, domain.ErrNotModerator
+	}
+	if _, err := uc.webhookRepo.FetchWebhookByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return uc.deliveryRepo.FetchDeliveriesByWebhookID(ctx, id, limit)
+}
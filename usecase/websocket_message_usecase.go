@@ -6,7 +6,9 @@ package usecase
 ここに実装されている3つのメソッドの処理の流れ
 1. プレゼンテーション層の/websocketのパッケージでwebsocket経由で受信したメッセージを引数として受け取る
 2. 必要な処理（ドメイン層で定義されているビジネスロジック）を施す
-3. ドメイン層にある永続化処理系のインターフェースに定義されているメソッドを呼び出す
+3. 送信と編集の場合は、コンテンツフィルターの連鎖を適用する（拒否されたら永続化しない）
+4. ドメイン層にある永続化処理系のインターフェースに定義されているメソッドを呼び出す（同じトランザクションでアウトボックスにイベントが書き込まれる）
+5. アウトボックスのリレーを起こし、イベントをRoomへのブロードキャストや外部のシンクに配信させる
 
 このユースケース層の依存先であるドメイン層の「永続化処理メソッドが定義されているインターフェース」の具体的な実装はインフラ層で行う。
 
@@ -15,47 +17,126 @@ package usecase
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 
 	"github.com/minminseo/tipstar-chat-api/domain"
 )
 
 type onlyWSMessageUseCase struct {
-	repo domain.MessageRepository
+	repo          domain.MessageRepository
+	filterLogRepo domain.FilterLogRepository // フィルターの発動記録の保存先
+	filters       ContentFilterChain         // 送信・編集時に永続化前に適用するフィルター
+	moderators    domain.ModeratorSet        // Tipへのアクセス制御の対象外になるユーザー
+	authorizer    domain.TipAuthorizer       // Roomへの参加と書き込みができるかどうかの判定
+	notifier      domain.MentionNotifier     // Roomに接続していないユーザーへのメンション通知
+	relay         OutboxRelay                // 永続化と同時に書き込んだイベントの配信（ブロードキャストはリレー経由で行う）
 }
 
 // 永続化処理のインターフェースのメソッドをユースケース層に依存注入するコンストラクタ関数
-func NewOnlyWSMessageUseCase(repo domain.MessageRepository) OnlyWSUsecase {
+func NewOnlyWSMessageUseCase(
+	repo domain.MessageRepository,
+	filterLogRepo domain.FilterLogRepository,
+	filters ContentFilterChain,
+	moderators domain.ModeratorSet,
+	authorizer domain.TipAuthorizer,
+	notifier domain.MentionNotifier,
+	relay OutboxRelay,
+) OnlyWSUsecase {
 	//明示的にフィールドrepoに引数repo（インターフェース）を代入して依存注入（ドメイン層の永続化処理専門のインターフェースのメソッドを渡す）
-	return &onlyWSMessageUseCase{repo: repo}
+	uc := &onlyWSMessageUseCase{
+		repo:          repo,
+		filterLogRepo: filterLogRepo,
+		filters:       filters,
+		moderators:    moderators,
+		authorizer:    authorizer,
+		notifier:      notifier,
+		relay:         relay,
+	}
+	return &tracedOnlyWSUsecase{inner: uc}
 }
 
 // メッセージ送信のユースケース
-func (uc *onlyWSMessageUseCase) ExecuteSendMessage(ctx context.Context, msg *domain.Message) error {
+// 伏せ字化された場合はmsg.Contentが書き換わるので、呼び出し側はそのままブロードキャストに使える
+// ClientMsgIDが付いた送信が再送だった場合は新しく保存せず、元のメッセージとtrueを返す
+func (uc *onlyWSMessageUseCase) ExecuteSendMessage(ctx context.Context, msg *domain.Message) (*domain.Message, bool, error) {
+	if err := authorizeTip(ctx, uc.authorizer, uc.moderators, msg.TipID, msg.UserID, domain.TipAccessWrite); err != nil {
+		return nil, false, err
+	}
+	if original, err := uc.fetchOriginal(ctx, msg); err != nil || original != nil {
+		return original, original != nil, err
+	}
+	if err := uc.applyFilters(ctx, msg); err != nil {
+		return nil, false, err
+	}
+	err := uc.repo.SaveMessage(ctx, msg)
+	if errors.Is(err, domain.ErrDuplicateClientMsgID) {
+		// 同じ送信が並行して届き、先に保存された場合
+		original, err := uc.fetchOriginal(ctx, msg)
+		if err != nil {
+			return nil, false, err
+		}
+		if original == nil {
+			return nil, false, domain.ErrDuplicateClientMsgID
+		}
+		return original, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	uc.filters.Record(ctx, msg)
+	uc.relay.Wake()
+	return msg, false, nil
+}
 
-	return uc.repo.SaveMessage(msg)
+// ClientMsgIDが同じ送信済みのメッセージを取得する。ClientMsgIDが無いか、まだ送信されていなければnilを返す
+func (uc *onlyWSMessageUseCase) fetchOriginal(ctx context.Context, msg *domain.Message) (*domain.Message, error) {
+	if msg.ClientMsgID == "" {
+		return nil, nil
+	}
+	return uc.repo.FetchMessageByClientMsgID(ctx, msg.UserID, msg.TipID, msg.ClientMsgID)
 }
 
 // メッセージ編集のユースケース
-func (uc *onlyWSMessageUseCase) EditMessage(ctx context.Context, messageID domain.MessageID, userID domain.UserID, newContent string) error {
+// ブロードキャストに使えるように、編集（とフィルター適用）後のメッセージを返す
+// 対象のTipはクライアントが送ってきた値ではなく、保存されているメッセージのTipで検証する
+func (uc *onlyWSMessageUseCase) EditMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID, newContent string, expectedVersion int64) (*domain.Message, error) {
 	msg, err := uc.repo.FetchMessageByID(ctx, messageID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if msg == nil {
-		return errors.New("メッセージが見つかりません")
+		return nil, errors.New("メッセージが見つかりません")
+	}
+	if msg.TipID != tipID {
+		return nil, domain.ErrTipMismatch
+	}
+	if err := authorizeTip(ctx, uc.authorizer, uc.moderators, msg.TipID, userID, domain.TipAccessWrite); err != nil {
+		return nil, err
+	}
+	// 取得から更新までの間に他の操作が割り込んだ場合は、リポジトリの条件付き更新で検出する
+	if err := msg.CheckVersion(expectedVersion); err != nil {
+		return nil, err
 	}
 	if err := msg.SetEditedContent(userID, newContent); err != nil {
-		return err
+		return nil, err
+	}
+	slog.DebugContext(ctx, "ContentとUpdatedAtの実体書き換え成功（永続化前）", "message_id", string(msg.ID), "content", msg.Content)
+
+	if err := uc.applyFilters(ctx, msg); err != nil {
+		return nil, err
 	}
-	log.Printf("ContentとUpdatedAtの実体書き換え成功（永続化前）")
 
 	// ドメイン層の永続化処理系のインターフェースに定義されている編集系のメソッドを呼び出す。具体的な実装はインフラ層で行う。
-	return uc.repo.Update(ctx, msg)
+	if err := uc.repo.Update(ctx, msg); err != nil {
+		return nil, err
+	}
+	uc.filters.Record(ctx, msg)
+	uc.relay.Wake()
+	return msg, nil
 }
 
 // メッセージ論理削除のユースケース
-func (uc *onlyWSMessageUseCase) DeleteMessage(ctx context.Context, messageID domain.MessageID, userID domain.UserID) error {
+func (uc *onlyWSMessageUseCase) DeleteMessage(ctx context.Context, tipID domain.TipID, messageID domain.MessageID, userID domain.UserID, expectedVersion int64) error {
 	msg, err := uc.repo.FetchMessageByID(ctx, messageID)
 	if err != nil {
 		return err
@@ -63,11 +144,68 @@ func (uc *onlyWSMessageUseCase) DeleteMessage(ctx context.Context, messageID dom
 	if msg == nil {
 		return errors.New("削除対象のメッセージが見つかりません")
 	}
+	if msg.TipID != tipID {
+		return domain.ErrTipMismatch
+	}
+	if err := authorizeTip(ctx, uc.authorizer, uc.moderators, msg.TipID, userID, domain.TipAccessWrite); err != nil {
+		return err
+	}
+	if err := msg.CheckVersion(expectedVersion); err != nil {
+		return err
+	}
+	// 削除できるのは投稿者だけ。モデレーターによる削除は通報の解決（ReportUsecase.ResolveReport）からだけ行う
 	if err := msg.SetDeletedMessage(userID); err != nil {
 		return err
 	}
-	log.Printf("DeletedAtの実体書き換え成功（永続化前）")
+	slog.DebugContext(ctx, "DeletedAtの実体書き換え成功（永続化前）", "message_id", string(msg.ID))
 
 	// ドメイン層の永続化処理系のインターフェースに定義されている論理削除メソッドを呼び出す。具体的な実装はインフラ層で行う。
-	return uc.repo.SoftDelete(ctx, msg)
+	if err := uc.repo.SoftDelete(ctx, msg); err != nil {
+		return err
+	}
+	uc.relay.Wake()
+	return nil
+}
+
+// Roomへの参加（/ws/{tipID}への接続と/wsでの購読）の可否の確認
+// 参加するとTipのメッセージがブロードキャストで届くので、履歴の閲覧と同じ権限を求める
+func (uc *onlyWSMessageUseCase) AuthorizeJoin(ctx context.Context, tipID domain.TipID, userID domain.UserID) error {
+	return authorizeTip(ctx, uc.authorizer, uc.moderators, tipID, userID, domain.TipAccessRead)
+}
+
+// メンション通知のユースケース
+// 通知対象（Roomに接続していないユーザー）の判定はRoomを管理しているプレゼンテーション層で行い、ここでは通知だけを行う
+func (uc *onlyWSMessageUseCase) NotifyMentions(ctx context.Context, msg *domain.Message, userIDs []domain.UserID) error {
+	targets := make(map[domain.UserID]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		targets[id] = struct{}{}
+	}
+	var errs []error
+	for _, mention := range msg.MentionList() {
+		if _, ok := targets[mention.MentionedUserID]; !ok {
+			continue
+		}
+		if err := uc.notifier.NotifyMention(ctx, mention); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// フィルターの連鎖を適用し、発動したものをモデレーター向けに記録する
+// 拒否された場合も記録は残す。記録の保存に失敗してもメッセージの送信自体は止めない
+// 伏せ字化でメンションが消える場合があるので、適用後にメンションを再計算する
+func (uc *onlyWSMessageUseCase) applyFilters(ctx context.Context, msg *domain.Message) error {
+	results, err := uc.filters.Run(ctx, msg)
+	msg.RefreshMentions()
+	if len(results) > 0 {
+		logs := make([]*domain.FilterLog, 0, len(results))
+		for _, result := range results {
+			logs = append(logs, domain.NewFilterLog(msg, result))
+		}
+		if saveErr := uc.filterLogRepo.SaveFilterLogs(ctx, logs); saveErr != nil {
+			slog.ErrorContext(ctx, "フィルターの発動記録の保存に失敗", "message_id", string(msg.ID), "error", saveErr)
+		}
+	}
+	return err
 }